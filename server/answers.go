@@ -0,0 +1,261 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/mattermost/mattermost-server/v5/plugin"
+	"github.com/pkg/errors"
+)
+
+const (
+	//pendingAnswersKeyPrefix is the prefix of the keys storing the questions a user has been asked but not answered yet
+	pendingAnswersKeyPrefix = "IceBreakerPending_"
+
+	//answersKeyPrefix is the prefix of the keys storing the answers of a user
+	answersKeyPrefix = "IceBreakerAnswers_"
+
+	//answerWindow sets how long the next message of the asked user in the channel counts as answer
+	answerWindow = 30 * time.Minute
+
+	//pendingAnswerExpiry sets how long a reply in the thread of an icebreaker post counts as answer
+	pendingAnswerExpiry = 7 * 24 * time.Hour
+
+	//maxAnswersPerUser limits the number of answers stored per user, the oldest ones are removed first
+	maxAnswersPerUser = 100
+
+	//maxAnswersShown limits the number of answers shown by `/icebreaker answers`
+	maxAnswersShown = 20
+)
+
+// PendingAnswer is an icebreaker question that has been asked to a user who did not answer yet
+type PendingAnswer struct {
+	PostID     string `json:"post_id"`
+	ThreadID   string `json:"thread_id"`
+	ChannelID  string `json:"channel_id"`
+	QuestionID string `json:"question_id,omitempty"`
+	Question   string `json:"question"`
+	AskedAt    int64  `json:"asked_at"`
+}
+
+// Answer is the reply of a user to an icebreaker question
+type Answer struct {
+	PostID       string `json:"post_id"`
+	AnswerPostID string `json:"answer_post_id"`
+	ChannelID    string `json:"channel_id"`
+	Question     string `json:"question"`
+	Answer       string `json:"answer"`
+	AnsweredAt   int64  `json:"answered_at"`
+}
+
+// trackPendingAnswer remembers that the given user has been asked a question in the given post
+func (p *Plugin) trackPendingAnswer(userID string, post *model.Post, question *Question) error {
+	threadID := post.RootId
+	if threadID == "" {
+		threadID = post.Id
+	}
+	pending := PendingAnswer{
+		PostID:     post.Id,
+		ThreadID:   threadID,
+		ChannelID:  post.ChannelId,
+		QuestionID: question.ID,
+		Question:   question.Question,
+		AskedAt:    model.GetMillis(),
+	}
+	return p.updatePendingAnswers(userID, func(pendingAnswers []PendingAnswer) ([]PendingAnswer, error) {
+		return append(pendingAnswers, pending), nil
+	})
+}
+
+// removePendingAnswer forgets about the question asked in the given post, e.g. because the user passed on it
+func (p *Plugin) removePendingAnswer(userID string, postID string) error {
+	return p.updatePendingAnswers(userID, func(pendingAnswers []PendingAnswer) ([]PendingAnswer, error) {
+		for index, pending := range pendingAnswers {
+			if pending.PostID == postID {
+				return append(pendingAnswers[:index], pendingAnswers[index+1:]...), nil
+			}
+		}
+		return nil, errSkipUpdate
+	})
+}
+
+// updatePendingAnswers changes the pending answers of the given user, expired ones are removed on the way
+func (p *Plugin) updatePendingAnswers(userID string, update func(pendingAnswers []PendingAnswer) ([]PendingAnswer, error)) error {
+	return p.updateKey(pendingAnswersKeyPrefix+userID, func(oldValue []byte) ([]byte, error) {
+		pendingAnswers, err := decodePendingAnswers(oldValue)
+		if err != nil {
+			return nil, err
+		}
+		pendingAnswers, err = update(removeExpiredAnswers(pendingAnswers, model.GetMillis()))
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(pendingAnswers)
+	})
+}
+
+// MessageHasBeenPosted records the answers to icebreaker questions. A reply of the asked user in the thread
+// of the icebreaker post is an answer, as well as their next message in the channel within the answerWindow
+func (p *Plugin) MessageHasBeenPosted(c *plugin.Context, post *model.Post) {
+	if post.UserId == p.botID || post.IsSystemMessage() {
+		return
+	}
+
+	//most messages are written by users without pending questions, so check for them before doing any update
+	kvData, appErr := p.API.KVGet(pendingAnswersKeyPrefix + post.UserId)
+	if appErr != nil {
+		return
+	}
+	if pendingAnswers, err := decodePendingAnswers(kvData); err == nil && len(pendingAnswers) == 0 {
+		return
+	}
+
+	var answered *PendingAnswer
+	err := p.updatePendingAnswers(post.UserId, func(pendingAnswers []PendingAnswer) ([]PendingAnswer, error) {
+		answered = nil //the update might be retried
+		for index := range pendingAnswers {
+			pending := pendingAnswers[index]
+			if !isAnswerTo(post, &pending) {
+				continue
+			}
+			answered = &pending
+			return append(pendingAnswers[:index], pendingAnswers[index+1:]...), nil
+		}
+		return nil, errSkipUpdate
+	})
+	if err != nil {
+		p.API.LogError("Failed to update pending icebreaker answers", "user", post.UserId, "err", err.Error())
+		return
+	}
+	if answered == nil {
+		return
+	}
+	p.countQuestionAnswered(answered.QuestionID)
+
+	answer := Answer{
+		PostID:       answered.PostID,
+		AnswerPostID: post.Id,
+		ChannelID:    post.ChannelId,
+		Question:     answered.Question,
+		Answer:       post.Message,
+		AnsweredAt:   post.CreateAt,
+	}
+	err = p.updateKey(answersKeyPrefix+post.UserId, func(oldValue []byte) ([]byte, error) {
+		answers, err := decodeAnswers(oldValue)
+		if err != nil {
+			return nil, err
+		}
+		answers = append(answers, answer)
+		if len(answers) > maxAnswersPerUser {
+			answers = answers[len(answers)-maxAnswersPerUser:]
+		}
+		return json.Marshal(answers)
+	})
+	if err != nil {
+		p.API.LogError("Failed to store icebreaker answer", "user", post.UserId, "err", err.Error())
+	}
+}
+
+// isAnswerTo checks whether the given post answers the pending question
+func isAnswerTo(post *model.Post, pending *PendingAnswer) bool {
+	if post.ChannelId != pending.ChannelID {
+		return false
+	}
+	if post.RootId != "" {
+		return post.RootId == pending.ThreadID
+	}
+	//only questions asked outside of a thread can be answered by a message in the channel
+	return pending.ThreadID == pending.PostID && post.CreateAt-pending.AskedAt <= int64(answerWindow/time.Millisecond)
+}
+
+// removeExpiredAnswers removes all pending answers that cannot be answered anymore
+func removeExpiredAnswers(pendingAnswers []PendingAnswer, now int64) []PendingAnswer {
+	result := []PendingAnswer{}
+	for _, pending := range pendingAnswers {
+		if now-pending.AskedAt <= int64(pendingAnswerExpiry/time.Millisecond) {
+			result = append(result, pending)
+		}
+	}
+	return result
+}
+
+func decodePendingAnswers(kvData []byte) ([]PendingAnswer, error) {
+	pendingAnswers := []PendingAnswer{}
+	if len(kvData) == 0 {
+		return pendingAnswers, nil
+	}
+	if err := json.Unmarshal(kvData, &pendingAnswers); err != nil {
+		return nil, errors.Wrap(err, "failed to decode pending answers")
+	}
+	return pendingAnswers, nil
+}
+
+func decodeAnswers(kvData []byte) ([]Answer, error) {
+	answers := []Answer{}
+	if len(kvData) == 0 {
+		return answers, nil
+	}
+	if err := json.Unmarshal(kvData, &answers); err != nil {
+		return nil, errors.Wrap(err, "failed to decode answers")
+	}
+	return answers, nil
+}
+
+func (p *Plugin) executeCommandIcebreakerAnswers(args *model.CommandArgs) *model.CommandResponse {
+	username := strings.TrimSpace(strings.TrimPrefix(args.Command, fmt.Sprintf("/%s", commandIcebreakerAnswers)))
+	username = strings.TrimPrefix(username, "@")
+	if len(username) == 0 {
+		return &model.CommandResponse{
+			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+			Text:         "Error: Please tell me whose answers you want to see, e.g. `/icebreaker answers @user`",
+		}
+	}
+
+	user, appErr := p.API.GetUserByUsername(username)
+	if appErr != nil {
+		return &model.CommandResponse{
+			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+			Text:         fmt.Sprintf("Error: Cannot find user @%s", username),
+		}
+	}
+
+	kvData, appErr := p.API.KVGet(answersKeyPrefix + user.Id)
+	if appErr != nil {
+		return p.getStorageErrorResponse(appErr)
+	}
+	allAnswers, err := decodeAnswers(kvData)
+	if err != nil {
+		return p.getStorageErrorResponse(err)
+	}
+
+	//answers given in channels the requesting user cannot read, e.g. private channels or direct messages, stay private
+	answers := []Answer{}
+	for _, answer := range allAnswers {
+		if p.API.HasPermissionToChannel(args.UserId, answer.ChannelID, model.PERMISSION_READ_CHANNEL) {
+			answers = append(answers, answer)
+		}
+	}
+
+	if len(answers) == 0 {
+		return &model.CommandResponse{
+			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+			Text:         fmt.Sprintf("@%s has not answered any questions yet...", username),
+		}
+	}
+
+	//show the latest answers first
+	message := fmt.Sprintf("Answers of @%s:\n", username)
+	for index := len(answers) - 1; index >= 0 && index >= len(answers)-maxAnswersShown; index-- {
+		answer := answers[index]
+		message = message + fmt.Sprintf("* **%s**\n  %s\n", answer.Question, strings.Replace(answer.Answer, "\n", " ", -1))
+	}
+
+	return &model.CommandResponse{
+		ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+		Username:     "icebreaker",
+		Text:         message,
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/mattermost/mattermost-server/v5/plugin/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func readAnswers(t *testing.T, store *fakeKVStore, userID string) []Answer {
+	answers, err := decodeAnswers(store.get(answersKeyPrefix + userID))
+	require.NoError(t, err)
+	return answers
+}
+
+func TestMessageHasBeenPosted(t *testing.T) {
+	now := model.GetMillis()
+	setup := func(t *testing.T, pendingAnswers []PendingAnswer) (*Plugin, *fakeKVStore) {
+		pendingBytes, err := json.Marshal(pendingAnswers)
+		require.NoError(t, err)
+
+		api, store := newFakeKVStore(map[string][]byte{pendingAnswersKeyPrefix + "AskedUser": pendingBytes})
+		plugin := &Plugin{botID: "BotUser"}
+		plugin.SetAPI(api)
+		return plugin, store
+	}
+
+	t.Run("Reply in the thread is an answer", func(t *testing.T) {
+		plugin, store := setup(t, []PendingAnswer{
+			PendingAnswer{PostID: "QuestionPost", ThreadID: "QuestionPost", ChannelID: "TestChannel", Question: "How do you do?", AskedAt: now - 60*60*1000},
+		})
+
+		plugin.MessageHasBeenPosted(nil, &model.Post{Id: "AnswerPost", UserId: "AskedUser", ChannelId: "TestChannel", RootId: "QuestionPost", Message: "Fine, thanks!", CreateAt: now})
+
+		answers := readAnswers(t, store, "AskedUser")
+		require.Len(t, answers, 1)
+		assert.Equal(t, Answer{PostID: "QuestionPost", AnswerPostID: "AnswerPost", ChannelID: "TestChannel", Question: "How do you do?", Answer: "Fine, thanks!", AnsweredAt: now}, answers[0])
+
+		pendingAnswers, err := decodePendingAnswers(store.get(pendingAnswersKeyPrefix + "AskedUser"))
+		require.NoError(t, err)
+		assert.Empty(t, pendingAnswers)
+	})
+	t.Run("Next message in the channel within the window is an answer", func(t *testing.T) {
+		plugin, store := setup(t, []PendingAnswer{
+			PendingAnswer{PostID: "QuestionPost", ThreadID: "QuestionPost", ChannelID: "TestChannel", Question: "How do you do?", AskedAt: now - 60*1000},
+		})
+
+		plugin.MessageHasBeenPosted(nil, &model.Post{Id: "AnswerPost", UserId: "AskedUser", ChannelId: "TestChannel", Message: "Fine!", CreateAt: now})
+		assert.Len(t, readAnswers(t, store, "AskedUser"), 1)
+	})
+	t.Run("Messages that are no answers are ignored", func(t *testing.T) {
+		plugin, store := setup(t, []PendingAnswer{
+			PendingAnswer{PostID: "QuestionPost", ThreadID: "QuestionPost", ChannelID: "TestChannel", Question: "How do you do?", AskedAt: now - 60*60*1000},
+		})
+
+		plugin.MessageHasBeenPosted(nil, &model.Post{Id: "Late", UserId: "AskedUser", ChannelId: "TestChannel", Message: "Too late", CreateAt: now})
+		plugin.MessageHasBeenPosted(nil, &model.Post{Id: "OtherChannel", UserId: "AskedUser", ChannelId: "OtherChannel", RootId: "QuestionPost", CreateAt: now})
+		plugin.MessageHasBeenPosted(nil, &model.Post{Id: "OtherThread", UserId: "AskedUser", ChannelId: "TestChannel", RootId: "OtherPost", CreateAt: now})
+		plugin.MessageHasBeenPosted(nil, &model.Post{Id: "OtherUser", UserId: "OtherUser", ChannelId: "TestChannel", RootId: "QuestionPost", CreateAt: now})
+		assert.Empty(t, readAnswers(t, store, "AskedUser"))
+		assert.Empty(t, readAnswers(t, store, "OtherUser"))
+	})
+	t.Run("Users without pending questions are not updated", func(t *testing.T) {
+		plugin, store := setup(t, []PendingAnswer{})
+		api := plugin.API.(*plugintest.API)
+
+		plugin.MessageHasBeenPosted(nil, &model.Post{Id: "Message", UserId: "AskedUser", ChannelId: "TestChannel", CreateAt: now})
+		api.AssertNumberOfCalls(t, "KVGet", 1)
+		api.AssertNotCalled(t, "KVCompareAndSet", mock.Anything, mock.Anything, mock.Anything)
+		assert.Empty(t, readAnswers(t, store, "AskedUser"))
+	})
+}
+
+func TestRemoveExpiredAnswers(t *testing.T) {
+	now := model.GetMillis()
+	pendingAnswers := []PendingAnswer{
+		PendingAnswer{PostID: "Expired", AskedAt: now - 8*24*60*60*1000},
+		PendingAnswer{PostID: "Recent", AskedAt: now - 60*1000},
+	}
+	assert.Equal(t, []PendingAnswer{pendingAnswers[1]}, removeExpiredAnswers(pendingAnswers, now))
+}
+
+func TestAnswersCommand(t *testing.T) {
+	answers := []Answer{
+		Answer{ChannelID: "PublicChannel", Question: "First question", Answer: "First answer"},
+		Answer{ChannelID: "PrivateChannel", Question: "Private question", Answer: "Private answer"},
+		Answer{ChannelID: "PublicChannel", Question: "Second question", Answer: "Second\nanswer"},
+	}
+	answersBytes, err := json.Marshal(answers)
+	require.NoError(t, err)
+
+	api, _ := newFakeKVStore(map[string][]byte{answersKeyPrefix + "AskedUser": answersBytes})
+	api.On("GetUserByUsername", "asked_user").Return(&model.User{Id: "AskedUser", Username: "asked_user"}, nil)
+	api.On("GetUserByUsername", "unknown").Return(nil, &model.AppError{})
+	api.On("HasPermissionToChannel", mock.AnythingOfType("string"), "PublicChannel", model.PERMISSION_READ_CHANNEL).Return(true)
+	api.On("HasPermissionToChannel", "AskedUser", "PrivateChannel", model.PERMISSION_READ_CHANNEL).Return(true)
+	api.On("HasPermissionToChannel", mock.AnythingOfType("string"), "PrivateChannel", model.PERMISSION_READ_CHANNEL).Return(false)
+	plugin := &Plugin{}
+	plugin.SetAPI(api)
+
+	//answers in channels the user cannot read are not shown
+	response, _ := plugin.ExecuteCommand(nil, &model.CommandArgs{Command: "/icebreaker answers @asked_user", UserId: "OtherUser"})
+	assert.Equal(t, "Answers of @asked_user:\n* **Second question**\n  Second answer\n* **First question**\n  First answer\n", response.Text)
+
+	response, _ = plugin.ExecuteCommand(nil, &model.CommandArgs{Command: "/icebreaker answers @asked_user", UserId: "AskedUser"})
+	assert.Equal(t, "Answers of @asked_user:\n* **Second question**\n  Second answer\n* **Private question**\n  Private answer\n* **First question**\n  First answer\n", response.Text)
+
+	response, _ = plugin.ExecuteCommand(nil, &model.CommandArgs{Command: "/icebreaker answers @unknown"})
+	assert.Equal(t, "Error: Cannot find user @unknown", response.Text)
+}
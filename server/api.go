@@ -0,0 +1,278 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/mattermost/mattermost-server/v5/plugin"
+)
+
+const (
+	//actionsPath is the path of the HTTP endpoints handling the buttons of the icebreaker posts
+	actionsPath = "/api/v1/actions"
+
+	actionPass    = "pass"
+	actionAnother = "another"
+
+	//actionLockPrefix is the prefix for the keys used to make sure that the buttons of a post are only used once
+	actionLockPrefix = "IceBreakerAction_"
+
+	//actionLockExpiry sets how long the lock for the buttons of a post is kept in the KVStore
+	actionLockExpiry = int64(7 * 24 * 60 * 60)
+
+	//askedUserProp is the post property storing the ID of the user asked in an icebreaker post
+	askedUserProp = "icebreaker_user_id"
+)
+
+// icebreakerContext is stored in the buttons of an icebreaker post and describes the question that has been asked
+type icebreakerContext struct {
+	TeamID     string
+	ChannelID  string
+	UserID     string
+	QuestionID string
+	Question   string
+	Category   string
+	Tag        string
+}
+
+func (c *icebreakerContext) toMap() map[string]interface{} {
+	return map[string]interface{}{
+		"team_id":     c.TeamID,
+		"channel_id":  c.ChannelID,
+		"user_id":     c.UserID,
+		"question_id": c.QuestionID,
+		"question":    c.Question,
+		"category":    c.Category,
+		"tag":         c.Tag,
+	}
+}
+
+func icebreakerContextFromMap(context map[string]interface{}) *icebreakerContext {
+	getString := func(key string) string {
+		value, _ := context[key].(string)
+		return value
+	}
+	return &icebreakerContext{
+		TeamID:     getString("team_id"),
+		ChannelID:  getString("channel_id"),
+		UserID:     getString("user_id"),
+		QuestionID: getString("question_id"),
+		Question:   getString("question"),
+		Category:   getString("category"),
+		Tag:        getString("tag"),
+	}
+}
+
+// getIcebreakerActions returns the buttons that are added to every icebreaker post
+func getIcebreakerActions(context *icebreakerContext) []*model.PostAction {
+	return []*model.PostAction{
+		&model.PostAction{
+			Id:   actionPass,
+			Type: model.POST_ACTION_TYPE_BUTTON,
+			Name: "Pass",
+			Integration: &model.PostActionIntegration{
+				URL:     fmt.Sprintf("/plugins/%s%s/%s", manifest.Id, actionsPath, actionPass),
+				Context: context.toMap(),
+			},
+		},
+		&model.PostAction{
+			Id:   actionAnother,
+			Type: model.POST_ACTION_TYPE_BUTTON,
+			Name: "Another question",
+			Integration: &model.PostActionIntegration{
+				URL:     fmt.Sprintf("/plugins/%s%s/%s", manifest.Id, actionsPath, actionAnother),
+				Context: context.toMap(),
+			},
+		},
+	}
+}
+
+// ServeHTTP handles the HTTP requests sent to the plugin, which are the buttons of the posts created by the bot
+func (p *Plugin) ServeHTTP(c *plugin.Context, w http.ResponseWriter, r *http.Request) {
+	//the header is set by the Mattermost server for authenticated users only
+	userID := r.Header.Get("Mattermost-User-Id")
+	if userID == "" {
+		http.Error(w, "Not authorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	handlers := map[string]func(userID string, request *model.PostActionIntegrationRequest) *model.PostActionIntegrationResponse{
+		actionsPath + "/" + actionPass:    p.handlePass,
+		actionsPath + "/" + actionAnother: p.handleAnother,
+		actionsPath + "/" + actionApprove: p.handleApprove,
+		actionsPath + "/" + actionReject:  p.handleReject,
+	}
+	handler, ok := handlers[r.URL.Path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	request := model.PostActionIntegrationRequestFromJson(r.Body)
+	if request == nil || request.PostId == "" {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(handler(userID, request).ToJson())
+}
+
+// handlePass lets the asked user pass on the question, the question is then asked to someone else
+func (p *Plugin) handlePass(userID string, request *model.PostActionIntegrationRequest) *model.PostActionIntegrationResponse {
+	post, context, question, errResponse := p.getAskedIcebreaker(userID, request)
+	if errResponse != nil {
+		return errResponse
+	}
+	if context.UserID != userID {
+		return &model.PostActionIntegrationResponse{EphemeralText: "Only the asked user can pass on this question."}
+	}
+	if errResponse = p.lockPost(post.Id); errResponse != nil {
+		return errResponse
+	}
+
+	user, appErr := p.GetRandomUser(context.ChannelID, userID)
+	if appErr != nil {
+		p.unlockIcebreakerPost(request.PostId)
+		if appErr.Id == getUsersErrorID {
+			p.API.LogError("Failed to pass on icebreaker", "err", appErr.Error())
+			return &model.PostActionIntegrationResponse{EphemeralText: "Error: Failed to get the users of this channel, please try again."}
+		}
+		return &model.PostActionIntegrationResponse{EphemeralText: "There is no one else I can ask this question right now."}
+	}
+	filter := questionFilter{Category: context.Category, Tag: context.Tag}
+	if err := p.postIcebreaker(context.TeamID, context.ChannelID, post.RootId, user, question, filter); err != nil {
+		p.unlockIcebreakerPost(request.PostId)
+		return &model.PostActionIntegrationResponse{EphemeralText: "Error: Failed to ask someone else, please try again."}
+	}
+
+	if err := p.removePendingAnswer(context.UserID, post.Id); err != nil {
+		p.API.LogError("Failed to update pending icebreaker answers", "user", context.UserID, "err", err.Error())
+	}
+	p.countQuestionPassed(context.QuestionID)
+	p.closeIcebreakerPost(post, fmt.Sprintf("@%s passed on this question.", p.getDisplayName(userID)))
+	return &model.PostActionIntegrationResponse{}
+}
+
+// handleAnother lets anyone ask the same user another question
+func (p *Plugin) handleAnother(userID string, request *model.PostActionIntegrationRequest) *model.PostActionIntegrationResponse {
+	post, context, _, errResponse := p.getAskedIcebreaker(userID, request)
+	if errResponse != nil {
+		return errResponse
+	}
+	if errResponse = p.lockPost(post.Id); errResponse != nil {
+		return errResponse
+	}
+
+	user, appErr := p.API.GetUser(context.UserID)
+	if appErr != nil {
+		p.unlockIcebreakerPost(request.PostId)
+		return &model.PostActionIntegrationResponse{EphemeralText: "Error: The asked user cannot be found."}
+	}
+	filter := questionFilter{Category: context.Category, Tag: context.Tag}
+	question, appErr := p.GetRandomQuestion(context.TeamID, context.ChannelID, filter)
+	if appErr != nil {
+		p.unlockIcebreakerPost(request.PostId)
+		return &model.PostActionIntegrationResponse{EphemeralText: "There is no other question I can ask right now."}
+	}
+	if err := p.postIcebreaker(context.TeamID, context.ChannelID, post.RootId, user, question, filter); err != nil {
+		p.unlockIcebreakerPost(request.PostId)
+		return &model.PostActionIntegrationResponse{EphemeralText: "Error: Failed to ask another question, please try again."}
+	}
+
+	if err := p.removePendingAnswer(context.UserID, post.Id); err != nil {
+		p.API.LogError("Failed to update pending icebreaker answers", "user", context.UserID, "err", err.Error())
+	}
+	p.countQuestionPassed(context.QuestionID)
+	p.closeIcebreakerPost(post, fmt.Sprintf("@%s asked for another question.", p.getDisplayName(userID)))
+	return &model.PostActionIntegrationResponse{}
+}
+
+// getAskedIcebreaker returns the icebreaker post whose buttons have been clicked, the context of the click and the asked
+// question as stored. The context is sent by the client, so it has to match the post and the user has to be able
+// to read its channel. Returns a response for the user if that is not the case
+func (p *Plugin) getAskedIcebreaker(userID string, request *model.PostActionIntegrationRequest) (*model.Post, *icebreakerContext, *Question, *model.PostActionIntegrationResponse) {
+	notAnIcebreaker := &model.PostActionIntegrationResponse{EphemeralText: "Error: This is not an icebreaker question."}
+	context := icebreakerContextFromMap(request.Context)
+	post, appErr := p.API.GetPost(request.PostId)
+	if appErr != nil || post.UserId != p.botID || post.ChannelId != context.ChannelID {
+		return nil, nil, nil, notAnIcebreaker
+	}
+	questionID, _ := post.GetProp(questionIDProp).(string)
+	askedUserID, _ := post.GetProp(askedUserProp).(string)
+	if questionID == "" || questionID != context.QuestionID || askedUserID != context.UserID {
+		return nil, nil, nil, notAnIcebreaker
+	}
+	if !p.API.HasPermissionToChannel(userID, post.ChannelId, model.PERMISSION_READ_CHANNEL) {
+		return nil, nil, nil, &model.PostActionIntegrationResponse{EphemeralText: "Error: You cannot access this channel."}
+	}
+
+	//the team decides which questions can be asked, so it is taken from the channel instead of the context
+	channel, appErr := p.API.GetChannel(post.ChannelId)
+	if appErr != nil {
+		return nil, nil, nil, &model.PostActionIntegrationResponse{EphemeralText: "Error: The channel of this question cannot be found."}
+	}
+	context.TeamID = channel.TeamId
+
+	data, err := p.ReadFromStorage()
+	if err != nil {
+		p.API.LogError("Failed to read the icebreaker data", "err", err.Error())
+		return nil, nil, nil, &model.PostActionIntegrationResponse{EphemeralText: "Error: Failed to access the icebreaker data, please try again."}
+	}
+	index := findQuestionByID(data.Questions, questionID)
+	if index < 0 {
+		return nil, nil, nil, &model.PostActionIntegrationResponse{EphemeralText: "This question does not exist anymore."}
+	}
+	return post, context, &data.Questions[index], nil
+}
+
+// lockIcebreakerPost makes sure that the buttons of a post created by the bot are only used once, even if they are
+// clicked concurrently. Returns the post if the lock has been acquired, else a response for the user
+func (p *Plugin) lockIcebreakerPost(postID string) (*model.Post, *model.PostActionIntegrationResponse) {
+	post, appErr := p.API.GetPost(postID)
+	if appErr != nil || post.UserId != p.botID {
+		return nil, &model.PostActionIntegrationResponse{EphemeralText: "Error: This is not an icebreaker question."}
+	}
+	if errResponse := p.lockPost(postID); errResponse != nil {
+		return nil, errResponse
+	}
+	return post, nil
+}
+
+// lockPost acquires the lock for the buttons of the given post. Returns a response for the user if that fails
+func (p *Plugin) lockPost(postID string) *model.PostActionIntegrationResponse {
+	acquired, appErr := p.API.KVSetWithOptions(actionLockPrefix+postID, []byte(postID), model.PluginKVSetOptions{
+		Atomic:          true,
+		OldValue:        nil,
+		ExpireInSeconds: actionLockExpiry,
+	})
+	if appErr != nil {
+		p.API.LogError("Failed to lock icebreaker post", "post", postID, "err", appErr.Error())
+		return &model.PostActionIntegrationResponse{EphemeralText: "Error: Failed to access the icebreaker data, please try again."}
+	}
+	if !acquired {
+		return &model.PostActionIntegrationResponse{EphemeralText: "Someone else has already been faster."}
+	}
+	return nil
+}
+
+// unlockIcebreakerPost makes the buttons of an icebreaker post usable again after an action failed
+func (p *Plugin) unlockIcebreakerPost(postID string) {
+	if appErr := p.API.KVDelete(actionLockPrefix + postID); appErr != nil {
+		p.API.LogError("Failed to unlock icebreaker post", "post", postID, "err", appErr.Error())
+	}
+}
+
+// closeIcebreakerPost removes the buttons from the post and notes what happened to it
+func (p *Plugin) closeIcebreakerPost(post *model.Post, note string) {
+	post.DelProp("attachments")
+	post.Message = fmt.Sprintf("%s\n*%s*", post.Message, note)
+	if _, appErr := p.API.UpdatePost(post); appErr != nil {
+		p.API.LogError("Failed to update icebreaker post", "post", post.Id, "err", appErr.Error())
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/mattermost/mattermost-server/v5/plugin/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// sendAction sends a button click of the given user to the plugin and returns the decoded response
+func sendAction(t *testing.T, plugin *Plugin, userID string, action string, context *icebreakerContext) *model.PostActionIntegrationResponse {
+	request := &model.PostActionIntegrationRequest{PostId: "IcebreakerPost", Context: context.toMap()}
+	r := httptest.NewRequest(http.MethodPost, actionsPath+"/"+action, bytes.NewReader(request.ToJson()))
+	r.Header.Set("Mattermost-User-Id", userID)
+	w := httptest.NewRecorder()
+	plugin.ServeHTTP(nil, w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	response := &model.PostActionIntegrationResponse{}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(response))
+	return response
+}
+
+func setupActionTest(t *testing.T) (*Plugin, *plugintest.API) {
+	icebreakerData := &IceBreakerData{
+		Questions: []Question{
+			Question{ID: "q1", Creator: "TestUser", Question: "How do you do?"},
+			Question{ID: "q2", Creator: "TestUser", Question: "Second question"},
+		},
+	}
+	reqBodyBytes := new(bytes.Buffer)
+	json.NewEncoder(reqBodyBytes).Encode(icebreakerData)
+
+	users := []*model.User{
+		&model.User{Id: "AskedUser", Username: "asked_user"},
+		&model.User{Id: "OtherUser", Username: "other_user"},
+	}
+
+	api, _ := newFakeKVStore(map[string][]byte{KVKEY: reqBodyBytes.Bytes()})
+	api.On("GetPost", "IcebreakerPost").Return(&model.Post{
+		Id:        "IcebreakerPost",
+		UserId:    "BotUser",
+		ChannelId: "TestChannel",
+		Message:   "Hey @asked_user! How do you do?",
+		Props:     model.StringInterface{askedUserProp: "AskedUser", questionIDProp: "q1"},
+	}, nil)
+	api.On("GetChannel", "TestChannel").Return(&model.Channel{Id: "TestChannel", TeamId: "TestTeam"}, nil)
+	api.On("HasPermissionToChannel", "AskedUser", "TestChannel", model.PERMISSION_READ_CHANNEL).Return(true)
+	api.On("HasPermissionToChannel", "OtherUser", "TestChannel", model.PERMISSION_READ_CHANNEL).Return(true)
+	api.On("GetUser", "AskedUser").Return(users[0], nil)
+	api.On("GetUser", "OtherUser").Return(users[1], nil)
+	api.On("GetUsersInChannel", "TestChannel", mock.AnythingOfType("string"), mock.AnythingOfType("int"), mock.AnythingOfType("int")).Return(users, nil)
+	api.On("GetUserStatus", mock.AnythingOfType("string")).Return(&model.Status{Status: "online"}, nil)
+
+	plugin := &Plugin{botID: "BotUser"}
+	plugin.SetAPI(api)
+	return plugin, api
+}
+
+func TestServeHTTP_unauthorized(t *testing.T) {
+	plugin := &Plugin{}
+	plugin.SetAPI(&plugintest.API{})
+
+	r := httptest.NewRequest(http.MethodPost, actionsPath+"/"+actionPass, strings.NewReader("{}"))
+	w := httptest.NewRecorder()
+	plugin.ServeHTTP(nil, w, r)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestPassAction(t *testing.T) {
+	context := &icebreakerContext{ChannelID: "TestChannel", UserID: "AskedUser", QuestionID: "q1", Question: "How do you do?"}
+
+	t.Run("Only the asked user can pass", func(t *testing.T) {
+		plugin, api := setupActionTest(t)
+
+		response := sendAction(t, plugin, "OtherUser", actionPass, context)
+		assert.Equal(t, "Only the asked user can pass on this question.", response.EphemeralText)
+		api.AssertNotCalled(t, "CreatePost", mock.Anything)
+	})
+	t.Run("Question is asked to someone else", func(t *testing.T) {
+		plugin, api := setupActionTest(t)
+		api.On("CreatePost", matchIcebreakerPost("TestChannel", "", "Hey @other_user! How do you do?")).Return(nil, nil)
+		api.On("UpdatePost", mock.MatchedBy(func(post *model.Post) bool {
+			return post.Message == "Hey @asked_user! How do you do?\n*@asked_user passed on this question.*" && post.GetProp("attachments") == nil
+		})).Return(nil, nil)
+
+		response := sendAction(t, plugin, "AskedUser", actionPass, context)
+		assert.Equal(t, "", response.EphemeralText)
+		api.AssertNumberOfCalls(t, "CreatePost", 1)
+		api.AssertNumberOfCalls(t, "UpdatePost", 1)
+
+		response = sendAction(t, plugin, "AskedUser", actionPass, context)
+		assert.Equal(t, "Someone else has already been faster.", response.EphemeralText)
+		api.AssertNumberOfCalls(t, "CreatePost", 1)
+	})
+}
+
+func TestAnotherAction(t *testing.T) {
+	context := &icebreakerContext{ChannelID: "TestChannel", UserID: "AskedUser", QuestionID: "q1", Question: "How do you do?"}
+
+	plugin, api := setupActionTest(t)
+	api.On("CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+		return strings.HasPrefix(post.Message, "Hey @asked_user! ")
+	})).Return(nil, nil)
+	api.On("UpdatePost", mock.MatchedBy(func(post *model.Post) bool {
+		return post.Message == "Hey @asked_user! How do you do?\n*@other_user asked for another question.*"
+	})).Return(nil, nil)
+
+	response := sendAction(t, plugin, "OtherUser", actionAnother, context)
+	assert.Equal(t, "", response.EphemeralText)
+	api.AssertNumberOfCalls(t, "CreatePost", 1)
+	api.AssertNumberOfCalls(t, "UpdatePost", 1)
+}
+
+func TestActions_forgedContext(t *testing.T) {
+	forged := map[string]*icebreakerContext{
+		"other channel":  &icebreakerContext{ChannelID: "PrivateChannel", UserID: "AskedUser", QuestionID: "q1"},
+		"other user":     &icebreakerContext{ChannelID: "TestChannel", UserID: "OtherUser", QuestionID: "q1"},
+		"other question": &icebreakerContext{ChannelID: "TestChannel", UserID: "AskedUser", QuestionID: "q2"},
+	}
+	for name, context := range forged {
+		t.Run(name, func(t *testing.T) {
+			plugin, api := setupActionTest(t)
+			context.Question = "Posted in any channel"
+
+			assert.Equal(t, "Error: This is not an icebreaker question.", sendAction(t, plugin, context.UserID, actionPass, context).EphemeralText)
+			assert.Equal(t, "Error: This is not an icebreaker question.", sendAction(t, plugin, "OtherUser", actionAnother, context).EphemeralText)
+			api.AssertNotCalled(t, "CreatePost", mock.Anything)
+			api.AssertNotCalled(t, "UpdatePost", mock.Anything)
+		})
+	}
+
+	t.Run("Posts without a question", func(t *testing.T) {
+		plugin, api := setupActionTest(t)
+		api.On("GetPost", "ModerationPost").Return(&model.Post{Id: "ModerationPost", UserId: "BotUser", ChannelId: "TestChannel"}, nil)
+		request := &model.PostActionIntegrationRequest{PostId: "ModerationPost", Context: (&icebreakerContext{ChannelID: "TestChannel"}).toMap()}
+
+		assert.Equal(t, "Error: This is not an icebreaker question.", plugin.handleAnother("OtherUser", request).EphemeralText)
+		api.AssertNotCalled(t, "UpdatePost", mock.Anything)
+	})
+	t.Run("Users who cannot read the channel", func(t *testing.T) {
+		plugin, api := setupActionTest(t)
+		api.On("HasPermissionToChannel", "Outsider", "TestChannel", model.PERMISSION_READ_CHANNEL).Return(false)
+		context := &icebreakerContext{ChannelID: "TestChannel", UserID: "AskedUser", QuestionID: "q1"}
+
+		assert.Equal(t, "Error: You cannot access this channel.", sendAction(t, plugin, "Outsider", actionAnother, context).EphemeralText)
+		api.AssertNotCalled(t, "CreatePost", mock.Anything)
+	})
+}
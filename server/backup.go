@@ -0,0 +1,248 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	//backupKeyPrefix is the prefix of the rotating keys the backups are stored under, followed by the slot number
+	backupKeyPrefix = "IceBreakerBackup_"
+
+	//backupIndexKey is the key of the list of all stored backups
+	backupIndexKey = "IceBreakerBackups"
+
+	//maxBackups limits how many backups are kept, the oldest one is replaced when a new backup is made
+	maxBackups = 10
+
+	//maxBackupSize is the size in bytes up to which data is backed up
+	maxBackupSize = 1024 * 1024
+)
+
+// Backup describes a snapshot of the IceBreakerData taken before a destructive change
+type Backup struct {
+	Slot      int    `json:"slot"`
+	CreatedAt int64  `json:"created_at"`
+	Reason    string `json:"reason"`
+	UserID    string `json:"user_id"`
+	Questions int    `json:"questions"`
+
+	//Pending is set while the data of the backup is written, until then the slot still holds an older backup
+	Pending bool `json:"pending,omitempty"`
+}
+
+// updateDataWithBackup works like updateData, but keeps a backup of the replaced data so the change can be undone
+func (p *Plugin) updateDataWithBackup(reason string, userID string, update func(data *IceBreakerData) error) error {
+	return p.modifyDataWithBackup(reason, userID, update, false)
+}
+
+// resetDataWithBackup works like resetData, but keeps a backup of the replaced data so the change can be undone
+func (p *Plugin) resetDataWithBackup(reason string, userID string, update func(data *IceBreakerData) error) error {
+	return p.modifyDataWithBackup(reason, userID, update, true)
+}
+
+func (p *Plugin) modifyDataWithBackup(reason string, userID string, update func(data *IceBreakerData) error, ignoreCorruptedData bool) error {
+	replacedValue, err := p.modifyData(update, ignoreCorruptedData)
+	if err != nil {
+		return err
+	}
+
+	//the change has already been made, so a failing backup must not fail the command
+	if err := p.storeBackup(replacedValue, reason, userID); err != nil {
+		p.API.LogError("Failed to back up icebreaker data", "reason", reason, "err", err.Error())
+	}
+	return nil
+}
+
+// storeBackup stores the given data in the next backup slot, replacing the oldest backup if all slots are used
+func (p *Plugin) storeBackup(kvData []byte, reason string, userID string) error {
+	if len(kvData) == 0 {
+		return nil
+	}
+	if len(kvData) > maxBackupSize {
+		p.API.LogWarn("Icebreaker data is too large to be backed up", "size", len(kvData), "max_size", maxBackupSize)
+		return nil
+	}
+
+	//corrupted data has already been quarantined, there is nothing that could be restored
+	data := IceBreakerData{}
+	if err := json.Unmarshal(kvData, &data); err != nil {
+		return nil
+	}
+
+	//reserve the slot first, so concurrent backups never use the same slot. The backup is pending
+	//until its data has been written, so it cannot be restored with the data of the replaced backup
+	backup := Backup{CreatedAt: model.GetMillis(), Reason: reason, UserID: userID, Questions: len(data.Questions), Pending: true}
+	err := p.updateKey(backupIndexKey, func(oldValue []byte) ([]byte, error) {
+		backups, err := decodeBackups(oldValue)
+		if err != nil {
+			return nil, err
+		}
+		backup.Slot = 0
+		if len(backups) > 0 {
+			backup.Slot = (backups[len(backups)-1].Slot + 1) % maxBackups
+		}
+		backups = append(backups, backup)
+		if len(backups) > maxBackups {
+			backups = backups[len(backups)-maxBackups:]
+		}
+		return json.Marshal(backups)
+	})
+	if err != nil {
+		return err
+	}
+
+	if appErr := p.API.KVSet(backupKeyPrefix+strconv.Itoa(backup.Slot), kvData); appErr != nil {
+		if err := p.finishBackup(backup.Slot, false); err != nil {
+			p.API.LogError("Failed to remove the unwritten icebreaker backup", "slot", backup.Slot, "err", err.Error())
+		}
+		return errors.Wrap(appErr, "failed to store backup")
+	}
+	return p.finishBackup(backup.Slot, true)
+}
+
+// finishBackup marks the pending backup in the given slot as written, or removes it if its data could not be written
+func (p *Plugin) finishBackup(slot int, written bool) error {
+	return p.updateKey(backupIndexKey, func(oldValue []byte) ([]byte, error) {
+		backups, err := decodeBackups(oldValue)
+		if err != nil {
+			return nil, err
+		}
+		for index := range backups {
+			if backups[index].Slot != slot || !backups[index].Pending {
+				continue
+			}
+			if written {
+				backups[index].Pending = false
+			} else {
+				backups = append(backups[:index], backups[index+1:]...)
+			}
+			return json.Marshal(backups)
+		}
+		return nil, errSkipUpdate
+	})
+}
+
+// getBackups returns all stored backups that can be restored, the newest one first
+func (p *Plugin) getBackups() ([]Backup, error) {
+	kvData, appErr := p.API.KVGet(backupIndexKey)
+	if appErr != nil {
+		return nil, errors.Wrapf(appErr, "failed to read %s", backupIndexKey)
+	}
+	backups, err := decodeBackups(kvData)
+	if err != nil {
+		return nil, err
+	}
+
+	newestFirst := []Backup{}
+	for index := len(backups) - 1; index >= 0; index-- {
+		if !backups[index].Pending {
+			newestFirst = append(newestFirst, backups[index])
+		}
+	}
+	return newestFirst, nil
+}
+
+func decodeBackups(kvData []byte) ([]Backup, error) {
+	backups := []Backup{}
+	if len(kvData) == 0 {
+		return backups, nil
+	}
+	if err := json.Unmarshal(kvData, &backups); err != nil {
+		return nil, errors.Wrap(err, "failed to decode backups")
+	}
+	return backups, nil
+}
+
+func formatTime(millis int64) string {
+	return time.Unix(0, millis*int64(time.Millisecond)).UTC().Format("2006-01-02 15:04 MST")
+}
+
+func (p *Plugin) executeCommandIcebreakerBackups(args *model.CommandArgs) *model.CommandResponse {
+	backups, err := p.getBackups()
+	if err != nil {
+		return p.getStorageErrorResponse(err)
+	}
+	if len(backups) == 0 {
+		return &model.CommandResponse{
+			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+			Text:         "There are no backups yet...",
+		}
+	}
+
+	message := "Backups, newest first:\n"
+	for index, backup := range backups {
+		message = message + fmt.Sprintf("%d.\t%s:\tbefore `%s` by @%s, %d questions\n", index+1, formatTime(backup.CreatedAt), backup.Reason, p.getDisplayName(backup.UserID), backup.Questions)
+	}
+	return &model.CommandResponse{
+		ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+		Username:     "icebreaker",
+		Text:         message,
+	}
+}
+
+func (p *Plugin) executeCommandIcebreakerRestore(args *model.CommandArgs) *model.CommandResponse {
+	givenIndex := strings.TrimSpace(strings.TrimPrefix(args.Command, fmt.Sprintf("/%s", commandIcebreakerRestore)))
+	index, err := strconv.Atoi(givenIndex)
+	if err != nil || index < 1 {
+		return &model.CommandResponse{
+			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+			Text:         "Error: Please give the number of the backup, as per `/icebreaker admin backups`",
+		}
+	}
+	return p.restoreBackup(args, index, subcommandRestore+" "+givenIndex)
+}
+
+// executeCommandIcebreakerUndo restores the latest backup. As the restore itself is backed up as well,
+// calling it twice reverts the undo
+func (p *Plugin) executeCommandIcebreakerUndo(args *model.CommandArgs) *model.CommandResponse {
+	return p.restoreBackup(args, 1, subcommandUndo)
+}
+
+// restoreBackup replaces the stored questions with the ones of the backup of the given number, 1 being the newest backup.
+// Everything else, e.g. the preferences of the users or the schedules, is kept as it is
+func (p *Plugin) restoreBackup(args *model.CommandArgs, index int, reason string) *model.CommandResponse {
+	backups, err := p.getBackups()
+	if err != nil {
+		return p.getStorageErrorResponse(err)
+	}
+	if index > len(backups) {
+		return &model.CommandResponse{
+			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+			Text:         fmt.Sprintf("Error: There is no backup number %d, there are %d backups. Use `/icebreaker admin backups` to see them", index, len(backups)),
+		}
+	}
+	backup := backups[index-1]
+
+	kvData, appErr := p.API.KVGet(backupKeyPrefix + strconv.Itoa(backup.Slot))
+	if appErr != nil {
+		return p.getStorageErrorResponse(appErr)
+	}
+	restoredData := IceBreakerData{}
+	if len(kvData) == 0 || json.Unmarshal(kvData, &restoredData) != nil {
+		return &model.CommandResponse{
+			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+			Text:         fmt.Sprintf("Error: The backup number %d is not available anymore", index),
+		}
+	}
+
+	err = p.resetDataWithBackup(reason, args.UserId, func(data *IceBreakerData) error {
+		data.Questions = restoredData.Questions
+		assignQuestionIDs(data)
+		return nil
+	})
+	if err != nil {
+		return p.getStorageErrorResponse(err)
+	}
+
+	return &model.CommandResponse{
+		ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+		Text:         fmt.Sprintf("Restored the backup from %s with %d questions. Use `/icebreaker admin undo` to revert this.", formatTime(backup.CreatedAt), len(restoredData.Questions)),
+	}
+}
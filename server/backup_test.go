@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackups_clearAllAndUndo(t *testing.T) {
+	icebreakerData := &IceBreakerData{
+		Questions: []Question{
+			Question{ID: "q1", Creator: "TestUser", Question: "How do you do?"},
+			Question{ID: "q2", Creator: "TestUser", Question: "What do you do?"},
+		},
+	}
+	reqBodyBytes := new(bytes.Buffer)
+	json.NewEncoder(reqBodyBytes).Encode(icebreakerData)
+
+	api, store := newFakeKVStore(map[string][]byte{KVKEY: reqBodyBytes.Bytes()})
+	api.On("GetUser", "TestUser").Return(&model.User{Id: "TestUser", Username: "test_user", Roles: model.SYSTEM_ADMIN_ROLE_ID}, nil)
+	plugin := &Plugin{}
+	plugin.SetAPI(api)
+	execute := func(command string) string {
+		response, _ := plugin.ExecuteCommand(nil, &model.CommandArgs{Command: command, UserId: "TestUser"})
+		return response.Text
+	}
+
+	assert.Equal(t, "There are no backups yet...", execute("/icebreaker admin backups"))
+
+	execute("/icebreaker admin clearall")
+	assert.Empty(t, readData(t, plugin).Questions)
+	assert.Equal(t, reqBodyBytes.Bytes(), store.get(backupKeyPrefix+"0"))
+
+	//changes that are no questions are not reverted by restoring a backup
+	execute("/icebreaker optout")
+	execute("/icebreaker schedule add 0 9 * * 1-5")
+
+	backups := execute("/icebreaker admin backups")
+	assert.True(t, strings.HasPrefix(backups, "Backups, newest first:\n1.\t"), backups)
+	assert.True(t, strings.HasSuffix(backups, ":\tbefore `admin clearall` by @test_user, 2 questions\n"), backups)
+
+	assert.True(t, strings.HasPrefix(execute("/icebreaker admin undo"), "Restored the backup from "))
+	data := readData(t, plugin)
+	assert.Equal(t, icebreakerData.Questions, data.Questions)
+	assert.False(t, data.isUserAskable("TestUser", "TestChannel"))
+	assert.Len(t, data.Schedules, 1)
+
+	//the undo has been backed up as well, so it can be reverted
+	execute("/icebreaker admin undo")
+	assert.Empty(t, readData(t, plugin).Questions)
+
+	execute("/icebreaker admin restore 3")
+	assert.Equal(t, icebreakerData.Questions, readData(t, plugin).Questions)
+
+	assert.Equal(t, "Error: There is no backup number 10, there are 4 backups. Use `/icebreaker admin backups` to see them", execute("/icebreaker admin restore 10"))
+	assert.Equal(t, "Error: Please give the number of the backup, as per `/icebreaker admin backups`", execute("/icebreaker admin restore first"))
+}
+
+func TestBackups_bounded(t *testing.T) {
+	api, store := newFakeKVStore(nil)
+	api.On("LogWarn", "Icebreaker data is too large to be backed up", "size", maxBackupSize+1, "max_size", maxBackupSize).Return()
+	plugin := &Plugin{}
+	plugin.SetAPI(api)
+
+	for i := 0; i < maxBackups+3; i++ {
+		kvData, err := json.Marshal(&IceBreakerData{Questions: make([]Question, i)})
+		require.NoError(t, err)
+		require.NoError(t, plugin.storeBackup(kvData, "test", "TestUser"))
+	}
+
+	backups, err := plugin.getBackups()
+	require.NoError(t, err)
+	require.Len(t, backups, maxBackups)
+	assert.Equal(t, maxBackups+2, backups[0].Questions)
+	assert.Equal(t, 2, backups[0].Slot)
+	assert.Equal(t, 3, backups[maxBackups-1].Questions)
+	assert.Nil(t, store.get(backupKeyPrefix+"10"))
+
+	//data that is too large is not backed up
+	require.NoError(t, plugin.storeBackup(make([]byte, maxBackupSize+1), "test", "TestUser"))
+	backups, err = plugin.getBackups()
+	require.NoError(t, err)
+	assert.Equal(t, maxBackups+2, backups[0].Questions)
+}
+
+func TestBackups_unwritten(t *testing.T) {
+	api, store := newFakeKVStore(nil)
+	api.On("LogError", "Failed to remove the unwritten icebreaker backup", "slot", 1, "err", mock.AnythingOfType("string")).Return()
+	plugin := &Plugin{}
+	plugin.SetAPI(api)
+
+	//replace the KVSet of the fake store, so writing the data of the second backup fails
+	for index, call := range api.ExpectedCalls {
+		if call.Method == "KVSet" {
+			api.ExpectedCalls = append(api.ExpectedCalls[:index], api.ExpectedCalls[index+1:]...)
+			break
+		}
+	}
+	listedWhileWriting := []Backup{}
+	api.On("KVSet", mock.AnythingOfType("string"), mock.AnythingOfType("[]uint8")).Return(
+		func(key string, value []byte) *model.AppError {
+			backups, err := plugin.getBackups()
+			require.NoError(t, err)
+			listedWhileWriting = backups
+			if key == backupKeyPrefix+"1" {
+				return &model.AppError{Message: "database is gone"}
+			}
+			store.Lock()
+			defer store.Unlock()
+			store.data[key] = value
+			return nil
+		})
+
+	first, err := json.Marshal(&IceBreakerData{Questions: make([]Question, 1)})
+	require.NoError(t, err)
+	require.NoError(t, plugin.storeBackup(first, "first", "TestUser"))
+	assert.Empty(t, listedWhileWriting, "a backup must not be listed before its data has been written")
+
+	second, err := json.Marshal(&IceBreakerData{Questions: make([]Question, 2)})
+	require.NoError(t, err)
+	assert.Error(t, plugin.storeBackup(second, "second", "TestUser"))
+	require.Len(t, listedWhileWriting, 1)
+	assert.Equal(t, "first", listedWhileWriting[0].Reason)
+
+	//the failed backup is removed, only the first one can be restored
+	backups, err := plugin.getBackups()
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+	assert.Equal(t, Backup{Slot: 0, CreatedAt: backups[0].CreatedAt, Reason: "first", UserID: "TestUser", Questions: 1}, backups[0])
+	assert.NotContains(t, string(store.get(backupIndexKey)), "second")
+}
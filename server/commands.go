@@ -14,49 +14,243 @@ const (
 	subcommandAsk                   = "ask"
 	subcommandAdd                   = "add"
 	subcommandList                  = "list"
+	subcommandSearch                = "search"
+	subcommandPair                  = "pair"
+	subcommandEdit                  = "edit"
+	subcommandStats                 = "stats"
+	subcommandAnswers               = "answers"
+	subcommandOptOut                = "optout"
+	subcommandOptIn                 = "optin"
+	subcommandWelcome               = "welcome"
+	subcommandOptInOnly             = "admin optinonly"
+	subcommandOptOuts               = "admin optouts"
+	subcommandWorkingHours          = "admin workinghours"
+	subcommandStrategy              = "admin strategy"
+	subcommandPending               = "admin pending"
+	subcommandFlagged               = "admin flagged"
+	subcommandUnflag                = "admin unflag"
+	subcommandExport                = "admin export"
+	subcommandImport                = "admin import"
+	subcommandBackups               = "admin backups"
+	subcommandRestore               = "admin restore"
+	subcommandUndo                  = "admin undo"
 	subcommandRemove                = "admin remove"
 	subcommandClearAll              = "admin clearall"
 	subcommandResetToDefault        = "admin reset questions"
+	subcommandScheduleAdd           = "schedule add"
+	subcommandScheduleList          = "schedule list"
+	subcommandScheduleRemove        = "schedule remove"
 	commandIcebreakerAsk            = commandIcebreaker + " " + subcommandAsk
 	commandIcebreakerAdd            = commandIcebreaker + " " + subcommandAdd
 	commandIcebreakerList           = commandIcebreaker + " " + subcommandList
+	commandIcebreakerSearch         = commandIcebreaker + " " + subcommandSearch
+	commandIcebreakerPair           = commandIcebreaker + " " + subcommandPair
+	commandIcebreakerEdit           = commandIcebreaker + " " + subcommandEdit
+	commandIcebreakerStats          = commandIcebreaker + " " + subcommandStats
+	commandIcebreakerAnswers        = commandIcebreaker + " " + subcommandAnswers
+	commandIcebreakerOptOut         = commandIcebreaker + " " + subcommandOptOut
+	commandIcebreakerOptIn          = commandIcebreaker + " " + subcommandOptIn
+	commandIcebreakerWelcome        = commandIcebreaker + " " + subcommandWelcome
+	commandIcebreakerOptInOnly      = commandIcebreaker + " " + subcommandOptInOnly
+	commandIcebreakerOptOuts        = commandIcebreaker + " " + subcommandOptOuts
+	commandIcebreakerWorkingHours   = commandIcebreaker + " " + subcommandWorkingHours
+	commandIcebreakerStrategy       = commandIcebreaker + " " + subcommandStrategy
+	commandIcebreakerPending        = commandIcebreaker + " " + subcommandPending
+	commandIcebreakerFlagged        = commandIcebreaker + " " + subcommandFlagged
+	commandIcebreakerUnflag         = commandIcebreaker + " " + subcommandUnflag
+	commandIcebreakerExport         = commandIcebreaker + " " + subcommandExport
+	commandIcebreakerImport         = commandIcebreaker + " " + subcommandImport
+	commandIcebreakerBackups        = commandIcebreaker + " " + subcommandBackups
+	commandIcebreakerRestore        = commandIcebreaker + " " + subcommandRestore
+	commandIcebreakerUndo           = commandIcebreaker + " " + subcommandUndo
 	commandIcebreakerRemove         = commandIcebreaker + " " + subcommandRemove
 	commandIcebreakerClearAll       = commandIcebreaker + " " + subcommandClearAll
 	commandIcebreakerResetToDefault = commandIcebreaker + " " + subcommandResetToDefault
+	commandIcebreakerScheduleAdd    = commandIcebreaker + " " + subcommandScheduleAdd
+	commandIcebreakerScheduleList   = commandIcebreaker + " " + subcommandScheduleList
+	commandIcebreakerScheduleRemove = commandIcebreaker + " " + subcommandScheduleRemove
+
+	//scopes of the question pools
+	scopeGlobal  = "global"
+	scopeTeam    = "team"
+	scopeChannel = "channel"
+
+	//maxAskedUsers limits how many users can be mentioned in `/icebreaker ask @user1 @user2`
+	maxAskedUsers = 10
+
+	//maxQuestionEdits limits how many previous versions of a question are kept
+	maxQuestionEdits = 10
+
+	//maxSchedulesPerChannel limits how many recurring icebreakers can be scheduled for a single channel
+	maxSchedulesPerChannel = 10
 )
 
 func getAutocompleteData() *model.AutocompleteData {
-	icebreakerCommand := model.NewAutocompleteData(commandIcebreaker, "[command]", "Ask an icebreaker, available subcommands: [ask], [add], [list], [admin remove], [admin clearall], [admin reset questions]")
+	icebreakerCommand := model.NewAutocompleteData(commandIcebreaker, "[command]", "Ask an icebreaker, available subcommands: [ask], [pair], [add], [list], [search], [edit], [stats], [answers], [optout], [optin], [schedule add], [schedule list], [schedule remove], [welcome], [admin optinonly], [admin optouts], [admin workinghours], [admin strategy], [admin pending], [admin flagged], [admin unflag], [admin export], [admin import], [admin backups], [admin restore], [admin undo], [admin remove], [admin clearall], [admin reset questions]")
 
-	ask := model.NewAutocompleteData("ask", "", "This will randomly select an available user from the channel and ask a random icebreaker question")
+	ask := model.NewAutocompleteData("ask", "[@user...] [category|#tag]", "This will randomly select an available user from the channel, or ask the mentioned users, a random icebreaker question")
+	ask.AddTextArgument("Filter: Users to ask instead of a random user, and only ask questions of the given category (e.g. `work`) or with the given tag (e.g. `#food`)", "[@user...] [category|#tag]", "")
 	icebreakerCommand.AddCommand(ask)
 
-	add := model.NewAutocompleteData(subcommandAdd, "[question]", "Add as new icebreaker question to the list")
-	add.AddTextArgument("Question: Question you'd like to add. Max 200 characters long.", "[question]", "")
+	pair := model.NewAutocompleteData(subcommandPair, "", "Pair the users of this channel for a chat and send every pair a group message with a starter question. Admin only")
+	icebreakerCommand.AddCommand(pair)
+
+	add := model.NewAutocompleteData(subcommandAdd, "[--scope global|team|channel] [--category category] [--tag tag] [question]", "Add as new icebreaker question to the list")
+	add.AddNamedStaticListArgument("scope", "Pool the question is added to, defaults to the global pool", false, getScopeListItems())
+	add.AddNamedTextArgument("category", "Category of the question, e.g. mild, medium, work or holiday", "[category]", "", false)
+	add.AddNamedTextArgument("tag", "Tag of the question, can be given multiple times", "[tag]", "", false)
+	add.AddTextArgument("Question: Question you'd like to add. The maximum length is configured by your System Admin.", "[question]", "")
 	icebreakerCommand.AddCommand(add)
 
-	list := model.NewAutocompleteData(subcommandList, "", "Show a list of available questions")
+	list := model.NewAutocompleteData(subcommandList, "[--scope global|team|channel] [--mine|--by @user] [--sort popular] [category|#tag] [page]", "Show a list of available questions")
+	list.AddNamedStaticListArgument("scope", "Only show the questions of the given pool", false, getScopeListItems())
+	list.AddNamedStaticListArgument("sort", "Order of the questions, defaults to the order they were added in", false, getSortListItems())
+	list.AddNamedTextArgument("by", "Only show the questions added by the given user, use --mine for your own questions", "[@user]", "", false)
+	list.AddTextArgument("Filter: Only show questions of the given category (e.g. `work`) or with the given tag (e.g. `#food`), followed by the page", "[category|#tag] [page]", "")
 	icebreakerCommand.AddCommand(list)
 
+	search := model.NewAutocompleteData(subcommandSearch, "[--scope global|team|channel] [--mine|--by @user] [--sort popular] [text] [page]", "Search the available questions")
+	search.AddNamedStaticListArgument("scope", "Only search the questions of the given pool", false, getScopeListItems())
+	search.AddNamedStaticListArgument("sort", "Order of the questions, defaults to the order they were added in", false, getSortListItems())
+	search.AddNamedTextArgument("by", "Only search the questions added by the given user, use --mine for your own questions", "[@user]", "", false)
+	search.AddTextArgument("Text: Text the questions need to contain, followed by the page", "[text] [page]", "")
+	icebreakerCommand.AddCommand(search)
+
+	edit := model.NewAutocompleteData(subcommandEdit, "[id] [question]", "Change the text of a question you added. Admins can edit all questions")
+	edit.AddTextArgument("Question: ID of the question, as per `/icebreaker list`, followed by the new text", "[id] [question]", "")
+	icebreakerCommand.AddCommand(edit)
+
+	stats := model.NewAutocompleteData(subcommandStats, "[id]", "Show how often the questions have been asked, answered and passed")
+	stats.AddTextArgument("Question: ID of a question to only see its statistics, as per `/icebreaker list`", "[id]", "")
+	icebreakerCommand.AddCommand(stats)
+
+	answers := model.NewAutocompleteData(subcommandAnswers, "[@user]", "Show the questions a user has answered before")
+	answers.AddTextArgument("User: User whose answers you'd like to see", "[@user]", "")
+	icebreakerCommand.AddCommand(answers)
+
+	optout := model.NewAutocompleteData(subcommandOptOut, "[global|channel]", "Do not ask me any icebreaker questions, either at all or in this channel only")
+	optout.AddStaticListArgument("Scope of the preference, defaults to global", false, getPreferenceScopeListItems())
+	icebreakerCommand.AddCommand(optout)
+
+	optin := model.NewAutocompleteData(subcommandOptIn, "[global|channel]", "Ask me icebreaker questions again, either everywhere or in this channel only")
+	optin.AddStaticListArgument("Scope of the preference, defaults to global", false, getPreferenceScopeListItems())
+	icebreakerCommand.AddCommand(optin)
+
+	scheduleAdd := model.NewAutocompleteData(subcommandScheduleAdd, "[--pair] [minute] [hour] [day-of-month] [month] [day-of-week]", "Schedule a recurring icebreaker for this channel, times are in UTC. Admin only")
+	scheduleAdd.AddTextArgument("Schedule: Cron-like expression, e.g. `0 9 * * 1-5` for 9:00 UTC on every weekday", "[minute] [hour] [day-of-month] [month] [day-of-week]", "")
+	icebreakerCommand.AddCommand(scheduleAdd)
+
+	scheduleList := model.NewAutocompleteData(subcommandScheduleList, "", "Show the recurring icebreakers of this channel")
+	icebreakerCommand.AddCommand(scheduleList)
+
+	scheduleRemove := model.NewAutocompleteData(subcommandScheduleRemove, "[id]", "Remove a recurring icebreaker. Admin only")
+	scheduleRemove.AddTextArgument("Id: Id of the schedule, as per `/icebreaker schedule list`", "[id]", "")
+	icebreakerCommand.AddCommand(scheduleRemove)
+
+	welcome := model.NewAutocompleteData(subcommandWelcome, "[on|off]", "Ask new members of this channel an icebreaker shortly after they joined. Admin only")
+	welcome.AddStaticListArgument("Mode", true, []model.AutocompleteListItem{
+		model.AutocompleteListItem{Item: "on", HelpText: "Ask everyone who joins this channel an icebreaker"},
+		model.AutocompleteListItem{Item: "off", HelpText: "Do not ask new members of this channel"},
+	})
+	icebreakerCommand.AddCommand(welcome)
+
+	optInOnly := model.NewAutocompleteData(subcommandOptInOnly, "[on|off]", "Only ask users who opted in for this channel. Admin only")
+	optInOnly.AddStaticListArgument("Mode", true, []model.AutocompleteListItem{
+		model.AutocompleteListItem{Item: "on", HelpText: "Only ask users who used `/icebreaker optin channel`"},
+		model.AutocompleteListItem{Item: "off", HelpText: "Ask everyone who did not opt out"},
+	})
+	icebreakerCommand.AddCommand(optInOnly)
+
+	workingHours := model.NewAutocompleteData(subcommandWorkingHours, "[on|off]", "Only ask users of this channel during their working hours, in their own timezone. Admin only")
+	workingHours.AddStaticListArgument("Mode", true, []model.AutocompleteListItem{
+		model.AutocompleteListItem{Item: "on", HelpText: "Only ask users during their working hours, scheduled icebreakers included"},
+		model.AutocompleteListItem{Item: "off", HelpText: "Ask users regardless of their working hours"},
+	})
+	icebreakerCommand.AddCommand(workingHours)
+
+	strategy := model.NewAutocompleteData(subcommandStrategy, "[weighted|uniform|roundrobin|leastrecent]", "Show or change how users and questions are chosen in this channel. Admin only")
+	strategy.AddStaticListArgument("Strategy, shows the current one if omitted", false, getStrategyListItems())
+	icebreakerCommand.AddCommand(strategy)
+
+	optOuts := model.NewAutocompleteData(subcommandOptOuts, "", "Show how many users opted out. Admin only")
+	icebreakerCommand.AddCommand(optOuts)
+
+	pending := model.NewAutocompleteData(subcommandPending, "", "Show the questions waiting for approval. Admin only")
+	icebreakerCommand.AddCommand(pending)
+
+	flagged := model.NewAutocompleteData(subcommandFlagged, "", "Show the questions that have been flagged because of their down votes. Admin only")
+	icebreakerCommand.AddCommand(flagged)
+
+	unflag := model.NewAutocompleteData(subcommandUnflag, "[id]", "Keep a flagged question and reset its votes. Admin only")
+	unflag.AddTextArgument("Id: ID of the question, as per `/icebreaker admin flagged`", "[id]", "")
+	icebreakerCommand.AddCommand(unflag)
+
+	export := model.NewAutocompleteData(subcommandExport, "", "Send yourself all questions as JSON and CSV file. Admin only")
+	icebreakerCommand.AddCommand(export)
+
+	importCommand := model.NewAutocompleteData(subcommandImport, "[--dry-run] [file id]", "Import questions from an uploaded JSON or CSV file. Admin only")
+	importCommand.AddTextArgument("File: ID of the uploaded file or of the post it is attached to. Add --dry-run to see what would be imported", "[--dry-run] [file id]", "")
+	icebreakerCommand.AddCommand(importCommand)
+
+	backups := model.NewAutocompleteData(subcommandBackups, "", "Show the backups made before questions were removed, reset or imported. Admin only")
+	icebreakerCommand.AddCommand(backups)
+
+	restore := model.NewAutocompleteData(subcommandRestore, "[number]", "Restore a backup. Admin only")
+	restore.AddTextArgument("Number: Number of the backup, as per `/icebreaker admin backups`", "[number]", "")
+	icebreakerCommand.AddCommand(restore)
+
+	undo := model.NewAutocompleteData(subcommandUndo, "", "Revert the latest change to the questions by restoring the latest backup. Admin only")
+	icebreakerCommand.AddCommand(undo)
+
 	remove := model.NewAutocompleteData(subcommandRemove, "[id]", "Remove a question. Admin only")
-	remove.AddTextArgument("Id: Index of the question, as per `/icebreaker list`", "[id]", "")
+	remove.AddTextArgument("Id: ID of the question, as per `/icebreaker list`", "[id]", "")
 	icebreakerCommand.AddCommand(remove)
 
-	clearall := model.NewAutocompleteData(subcommandClearAll, "", "Remove ALL questions. *WARNING: No backup is being made.* Admin only")
+	clearall := model.NewAutocompleteData(subcommandClearAll, "", "Remove ALL questions. A backup is made, use `/icebreaker admin undo` to revert. Admin only")
 	icebreakerCommand.AddCommand(clearall)
 
-	reset := model.NewAutocompleteData(subcommandResetToDefault, "", "Resets the questions to the default ones from this plugin. *WARNING: No backup is being made.* Admin only")
+	reset := model.NewAutocompleteData(subcommandResetToDefault, "", "Resets the questions to the default ones from this plugin. A backup is made, use `/icebreaker admin undo` to revert. Admin only")
 	icebreakerCommand.AddCommand(reset)
 
 	return icebreakerCommand
 }
 
+func getScopeListItems() []model.AutocompleteListItem {
+	return []model.AutocompleteListItem{
+		model.AutocompleteListItem{Item: scopeGlobal, HelpText: "Questions that can be asked everywhere"},
+		model.AutocompleteListItem{Item: scopeTeam, HelpText: "Questions that are only asked in this team"},
+		model.AutocompleteListItem{Item: scopeChannel, HelpText: "Questions that are only asked in this channel"},
+	}
+}
+
+func getSortListItems() []model.AutocompleteListItem {
+	return []model.AutocompleteListItem{
+		model.AutocompleteListItem{Item: sortPopular, HelpText: "Most answered and least passed questions first"},
+	}
+}
+
+func getStrategyListItems() []model.AutocompleteListItem {
+	return []model.AutocompleteListItem{
+		model.AutocompleteListItem{Item: strategyWeighted, HelpText: "Prefer users and questions that have not been asked lately"},
+		model.AutocompleteListItem{Item: strategyUniform, HelpText: "Choose every user and question with the same probability"},
+		model.AutocompleteListItem{Item: strategyRoundRobin, HelpText: "Ask everyone and every question once before asking anyone twice"},
+		model.AutocompleteListItem{Item: strategyLeastRecent, HelpText: "Ask the users and questions that have been asked the longest time ago"},
+	}
+}
+
+func getPreferenceScopeListItems() []model.AutocompleteListItem {
+	return []model.AutocompleteListItem{
+		model.AutocompleteListItem{Item: scopeGlobal, HelpText: "Applies to all channels"},
+		model.AutocompleteListItem{Item: scopeChannel, HelpText: "Applies to this channel only"},
+	}
+}
+
 func (p *Plugin) registerCommands() error {
 	commands := [...]model.Command{
 		model.Command{
 			Trigger:          commandIcebreaker,
 			AutoComplete:     true,
-			AutoCompleteDesc: "Ask an icebreaker, available subcommands: [add], [list], [admin remove], [admin clearall], [admin reset questions]",
+			AutoCompleteDesc: "Ask an icebreaker, available subcommands: [pair], [add], [list], [search], [edit], [stats], [answers], [optout], [optin], [schedule add], [schedule list], [schedule remove], [welcome], [admin optinonly], [admin optouts], [admin workinghours], [admin strategy], [admin pending], [admin flagged], [admin unflag], [admin export], [admin import], [admin backups], [admin restore], [admin undo], [admin remove], [admin clearall], [admin reset questions]",
 			AutocompleteData: getAutocompleteData(),
 		},
 	}
@@ -83,6 +277,54 @@ func (p *Plugin) ExecuteCommand(c *plugin.Context, args *model.CommandArgs) (*mo
 		commandIcebreakerResetToDefault: func(args *model.CommandArgs) (*model.CommandResponse, *model.AppError) {
 			return p.executeCommandIcebreakerResetToDefault(args), nil
 		},
+		commandIcebreakerPair: func(args *model.CommandArgs) (*model.CommandResponse, *model.AppError) {
+			return p.executeCommandIcebreakerPair(args), nil
+		},
+		commandIcebreakerScheduleAdd: func(args *model.CommandArgs) (*model.CommandResponse, *model.AppError) {
+			return p.executeCommandIcebreakerScheduleAdd(args), nil
+		},
+		commandIcebreakerScheduleRemove: func(args *model.CommandArgs) (*model.CommandResponse, *model.AppError) {
+			return p.executeCommandIcebreakerScheduleRemove(args), nil
+		},
+		commandIcebreakerBackups: func(args *model.CommandArgs) (*model.CommandResponse, *model.AppError) {
+			return p.executeCommandIcebreakerBackups(args), nil
+		},
+		commandIcebreakerRestore: func(args *model.CommandArgs) (*model.CommandResponse, *model.AppError) {
+			return p.executeCommandIcebreakerRestore(args), nil
+		},
+		commandIcebreakerUndo: func(args *model.CommandArgs) (*model.CommandResponse, *model.AppError) {
+			return p.executeCommandIcebreakerUndo(args), nil
+		},
+		commandIcebreakerExport: func(args *model.CommandArgs) (*model.CommandResponse, *model.AppError) {
+			return p.executeCommandIcebreakerExport(args), nil
+		},
+		commandIcebreakerImport: func(args *model.CommandArgs) (*model.CommandResponse, *model.AppError) {
+			return p.executeCommandIcebreakerImport(args), nil
+		},
+		commandIcebreakerPending: func(args *model.CommandArgs) (*model.CommandResponse, *model.AppError) {
+			return p.executeCommandIcebreakerPending(args), nil
+		},
+		commandIcebreakerFlagged: func(args *model.CommandArgs) (*model.CommandResponse, *model.AppError) {
+			return p.executeCommandIcebreakerFlagged(args), nil
+		},
+		commandIcebreakerUnflag: func(args *model.CommandArgs) (*model.CommandResponse, *model.AppError) {
+			return p.executeCommandIcebreakerUnflag(args), nil
+		},
+		commandIcebreakerWelcome: func(args *model.CommandArgs) (*model.CommandResponse, *model.AppError) {
+			return p.executeCommandIcebreakerWelcome(args), nil
+		},
+		commandIcebreakerOptInOnly: func(args *model.CommandArgs) (*model.CommandResponse, *model.AppError) {
+			return p.executeCommandIcebreakerOptInOnly(args), nil
+		},
+		commandIcebreakerOptOuts: func(args *model.CommandArgs) (*model.CommandResponse, *model.AppError) {
+			return p.executeCommandIcebreakerOptOuts(args), nil
+		},
+		commandIcebreakerWorkingHours: func(args *model.CommandArgs) (*model.CommandResponse, *model.AppError) {
+			return p.executeCommandIcebreakerWorkingHours(args), nil
+		},
+		commandIcebreakerStrategy: func(args *model.CommandArgs) (*model.CommandResponse, *model.AppError) {
+			return p.executeCommandIcebreakerStrategy(args), nil
+		},
 	}
 
 	userCommands := map[string]func(args *model.CommandArgs) (*model.CommandResponse, *model.AppError){
@@ -95,6 +337,27 @@ func (p *Plugin) ExecuteCommand(c *plugin.Context, args *model.CommandArgs) (*mo
 		commandIcebreakerList: func(args *model.CommandArgs) (*model.CommandResponse, *model.AppError) {
 			return p.executeCommandIcebreakerList(args), nil
 		},
+		commandIcebreakerSearch: func(args *model.CommandArgs) (*model.CommandResponse, *model.AppError) {
+			return p.executeCommandIcebreakerSearch(args), nil
+		},
+		commandIcebreakerEdit: func(args *model.CommandArgs) (*model.CommandResponse, *model.AppError) {
+			return p.executeCommandIcebreakerEdit(args), nil
+		},
+		commandIcebreakerStats: func(args *model.CommandArgs) (*model.CommandResponse, *model.AppError) {
+			return p.executeCommandIcebreakerStats(args), nil
+		},
+		commandIcebreakerOptOut: func(args *model.CommandArgs) (*model.CommandResponse, *model.AppError) {
+			return p.executeCommandIcebreakerOptOut(args), nil
+		},
+		commandIcebreakerOptIn: func(args *model.CommandArgs) (*model.CommandResponse, *model.AppError) {
+			return p.executeCommandIcebreakerOptIn(args), nil
+		},
+		commandIcebreakerAnswers: func(args *model.CommandArgs) (*model.CommandResponse, *model.AppError) {
+			return p.executeCommandIcebreakerAnswers(args), nil
+		},
+		commandIcebreakerScheduleList: func(args *model.CommandArgs) (*model.CommandResponse, *model.AppError) {
+			return p.executeCommandIcebreakerScheduleList(args), nil
+		},
 	}
 
 	//this needs to be last, as prefix `/icebreaker` is also part of the above commands
@@ -108,8 +371,7 @@ func (p *Plugin) ExecuteCommand(c *plugin.Context, args *model.CommandArgs) (*mo
 	//first check for admin commands, make sure the user has the right permission
 	for key, value := range adminCommands {
 		if strings.HasPrefix(trigger, key) {
-			sourceUser, _ := p.API.GetUser(args.UserId)
-			if response := requireAdminUser(sourceUser); response != nil {
+			if response := p.requirePermission(args, adminCommandPermissions[key]); response != nil {
 				return response, nil
 			}
 			return value(args)
@@ -136,7 +398,14 @@ func (p *Plugin) ExecuteCommand(c *plugin.Context, args *model.CommandArgs) (*mo
 }
 
 func (p *Plugin) executeCommandIcebreakerResetToDefault(args *model.CommandArgs) *model.CommandResponse {
-	p.FillDefaultQuestions()
+	err := p.resetDataWithBackup(subcommandResetToDefault, args.UserId, func(data *IceBreakerData) error {
+		data.Questions = getDefaultQuestions()
+		assignQuestionIDs(data)
+		return nil
+	})
+	if err != nil {
+		return p.getStorageErrorResponse(err)
+	}
 
 	return &model.CommandResponse{
 		ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
@@ -145,10 +414,15 @@ func (p *Plugin) executeCommandIcebreakerResetToDefault(args *model.CommandArgs)
 }
 
 func (p *Plugin) executeCommandIcebreakerClearAll(args *model.CommandArgs) *model.CommandResponse {
-	data := p.ReadFromStorage()
-	lenBefore := len(data.Questions)
-	data.Questions = []Question{}
-	p.WriteToStorage(&data)
+	lenBefore := 0
+	err := p.resetDataWithBackup(subcommandClearAll, args.UserId, func(data *IceBreakerData) error {
+		lenBefore = len(data.Questions)
+		data.Questions = []Question{}
+		return nil
+	})
+	if err != nil {
+		return p.getStorageErrorResponse(err)
+	}
 
 	return &model.CommandResponse{
 		ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
@@ -157,161 +431,475 @@ func (p *Plugin) executeCommandIcebreakerClearAll(args *model.CommandArgs) *mode
 }
 
 func (p *Plugin) executeCommandIcebreakerRemove(args *model.CommandArgs) *model.CommandResponse {
-	data := p.ReadFromStorage()
+	var errResponse *model.CommandResponse
+	err := p.updateDataWithBackup(subcommandRemove, args.UserId, func(data *IceBreakerData) error {
+		var index int
+		index, errResponse = getQuestionIndex(strings.TrimPrefix(args.Command, fmt.Sprintf("/%s", commandIcebreakerRemove)), data.Questions)
+		if errResponse != nil {
+			return errSkipUpdate
+		}
 
-	index, errResponse := getIndex(args.Command, data.Questions)
+		//from https://stackoverflow.com/a/37335777/199513
+		data.Questions = append(data.Questions[:index], data.Questions[index+1:]...)
+		return nil
+	})
+	if err != nil {
+		return p.getStorageErrorResponse(err)
+	}
 	if errResponse != nil {
 		return errResponse
 	}
 
-	//from https://stackoverflow.com/a/37335777/199513
-	data.Questions = append(data.Questions[:index], data.Questions[index+1:]...)
-	p.WriteToStorage(&data)
-
 	return &model.CommandResponse{
 		ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
 		Text:         "Question removed",
 	}
 }
 
-func (p *Plugin) executeCommandIcebreakerList(args *model.CommandArgs) *model.CommandResponse {
-	data := p.ReadFromStorage()
+func (p *Plugin) executeCommandIcebreaker(args *model.CommandArgs) *model.CommandResponse {
+	filter := questionFilter{}
+	usernames := []string{}
+	if strings.HasPrefix(args.Command, fmt.Sprintf("/%s", commandIcebreakerAsk)) {
+		//mentioned users are asked directly, everything else is the filter
+		givenFilter := []string{}
+		for _, field := range strings.Fields(strings.TrimPrefix(args.Command, fmt.Sprintf("/%s", commandIcebreakerAsk))) {
+			if strings.HasPrefix(field, "@") {
+				usernames = append(usernames, strings.TrimPrefix(field, "@"))
+				continue
+			}
+			givenFilter = append(givenFilter, field)
+		}
+		filter = parseQuestionFilter(strings.Join(givenFilter, " "))
+	}
+	if len(usernames) > 0 {
+		return p.executeCommandIcebreakerAskUsers(args, usernames, filter)
+	}
 
-	if len(data.Questions) == 0 {
+	err := p.askIcebreaker(args.TeamId, args.ChannelId, args.RootId, args.UserId, filter)
+	if err != nil {
+		return p.getAskErrorResponse(err, filter)
+	}
+	return &model.CommandResponse{}
+}
+
+// executeCommandIcebreakerAskUsers asks the mentioned users the same question
+func (p *Plugin) executeCommandIcebreakerAskUsers(args *model.CommandArgs, usernames []string, filter questionFilter) *model.CommandResponse {
+	if len(usernames) > maxAskedUsers {
 		return &model.CommandResponse{
 			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
-			Text:         "There are no questions...",
+			Text:         fmt.Sprintf("Error: You can ask up to %d users at once", maxAskedUsers),
 		}
 	}
 
-	message := "Questions:\n"
-	for index, question := range data.Questions {
-		creator := question.Creator
-		user, err := p.API.GetUser(creator)
-		if err == nil {
-			creator = user.GetDisplayName("")
+	skipped, err := p.askUsers(args.TeamId, args.ChannelId, args.RootId, usernames, filter)
+	if errors.Cause(err) == errNoUser {
+		return &model.CommandResponse{
+			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+			Text:         "Error: I cannot ask anyone of them:\n* " + strings.Join(skipped, "\n* "),
 		}
-		message = message + fmt.Sprintf("%d.\t@%s:\t%s\n", index+1, creator, question.Question)
 	}
-
-	return &model.CommandResponse{
-		ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
-		Username:     "icebreaker",
-		Text:         message,
+	if err != nil {
+		return p.getAskErrorResponse(err, filter)
 	}
+	if len(skipped) > 0 {
+		return &model.CommandResponse{
+			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+			Text:         "I did not ask everyone:\n* " + strings.Join(skipped, "\n* "),
+		}
+	}
+	return &model.CommandResponse{}
 }
 
-func (p *Plugin) executeCommandIcebreaker(args *model.CommandArgs) *model.CommandResponse {
-	data := p.ReadFromStorage()
-
-	//check if there are any questions yet
-	if len(data.Questions) == 0 {
+// getAskErrorResponse returns the response informing the user why no icebreaker has been asked
+func (p *Plugin) getAskErrorResponse(err error, filter questionFilter) *model.CommandResponse {
+	switch errors.Cause(err) {
+	case errNoMatchingQuestions:
+		return &model.CommandResponse{
+			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+			Text:         fmt.Sprintf("Error: There are no questions matching '%s'. Use `/icebreaker list` to see the available questions.", filter.String()),
+		}
+	case errNoQuestions:
 		return &model.CommandResponse{
 			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
 			Text:         "Error: There are no questions that I can ask. Be the first one to propose a question by using `/icebreaker add <question>`",
 		}
+	case errNoUser:
+		return &model.CommandResponse{
+			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+			Text:         "Error: Cannot get a user to ask a question for. Note: This plugin will not ask questions to offline or DND users.",
+		}
+	case errCreatePost:
+		return &model.CommandResponse{
+			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+			Text:         "Error: Failed to create post",
+		}
+	case errGetUsers:
+		p.API.LogError("Failed to get the users of the channel", "err", err.Error())
+		return &model.CommandResponse{
+			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+			Text:         "Error: Failed to get the users of this channel, please try again",
+		}
+	default:
+		return p.getStorageErrorResponse(err)
 	}
+}
 
-	//get a random user that is not a bot
-	user, err := p.GetRandomUser(args.ChannelId, args.UserId)
-	if err != nil {
+func (p *Plugin) executeCommandIcebreakerAdd(args *model.CommandArgs) *model.CommandResponse {
+	//check the user input and extract the question from it
+	givenQuestion := strings.TrimPrefix(args.Command, fmt.Sprintf("/%s", commandIcebreakerAdd))
+	givenQuestion, scope, hasScope := extractFlag(givenQuestion, "scope")
+	givenQuestion, category, hasCategory := extractFlag(givenQuestion, "category")
+	givenQuestion, tags := extractFlags(givenQuestion, "tag")
+	if !hasCategory {
+		category = defaultCategory
+	}
+	if !hasScope {
+		scope = scopeGlobal
+	}
+	if !isValidScope(scope) {
 		return &model.CommandResponse{
 			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
-			Text:         "Error: Cannot get a user to ask a question for. Note: This plugin will not ask questions to offline or DND users.",
+			Text:         fmt.Sprintf("Error: Unknown scope '%s', use one of: global, team, channel", scope),
+		}
+	}
+	givenQuestion = strings.TrimPrefix(givenQuestion, " ")
+	if len(givenQuestion) <= 0 {
+		return &model.CommandResponse{
+			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+			Text:         "Error: Please enter a question",
 		}
 	}
 
-	//build the question and ask it
-	question, err := p.GetRandomQuestion()
-	if err != nil {
+	//deny questions that are too long
+	config := p.getConfiguration()
+	if len(givenQuestion) > config.getMaxQuestionLength() {
 		return &model.CommandResponse{
 			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
-			Text:         "Error: There are no questions that I can ask. Be the first one to propose a question by using `/icebreaker add <question>`",
+			Text:         fmt.Sprintf("Your question has not been added: Question too long, must be under %d characters.", config.getMaxQuestionLength()),
 		}
 	}
 
-	message := fmt.Sprintf("Hey @%s! %s", user.GetDisplayName(""), question.Question)
-	post := &model.Post{
-		ChannelId: args.ChannelId,
-		RootId:    args.RootId,
-		UserId:    p.botID,
-		Message:   message,
+	newQuestion := Question{}
+	creator, appErr := p.API.GetUser(args.UserId)
+	if appErr != nil {
+		p.API.LogError("Failed to get the user adding a question", "user", args.UserId, "err", appErr.Error())
+		return &model.CommandResponse{
+			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+			Text:         "Error: Failed to look up your user, please try again",
+		}
+	}
+	newQuestion.Creator = creator.Id
+	newQuestion.Question = givenQuestion
+	newQuestion.Category = normalizeLabel(category)
+	for _, tag := range tags {
+		newQuestion.Tags = append(newQuestion.Tags, normalizeLabel(tag))
 	}
+	switch scope {
+	case scopeTeam:
+		newQuestion.TeamID = args.TeamId
+	case scopeChannel:
+		newQuestion.TeamID = args.TeamId
+		newQuestion.ChannelID = args.ChannelId
+	}
+
+	var errResponse *model.CommandResponse
+	numQuestions := 0
+	err := p.updateData(func(data *IceBreakerData) error {
+		//check if there are already too many questions
+		if len(data.Questions) > config.getMaxQuestions() {
+			errResponse = &model.CommandResponse{
+				ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+				Text:         fmt.Sprintf("Your question has not been added: There are already more than %d questions. Ask an Admin to clean up before adding more questions.", config.getMaxQuestions()),
+			}
+			return errSkipUpdate
+		}
+
+		//Check if the question is already created within the same pool
+		if containsQuestion(data.Questions, &newQuestion) || containsQuestion(data.PendingQuestions, &newQuestion) {
+			errResponse = &model.CommandResponse{
+				ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+				Text:         "Error: Your question has already been added",
+			}
+			return errSkipUpdate
+		}
 
-	//store the user and question so we avoid asking the same users and same questions over and over
-	data.LastUsers = append(data.LastUsers, user.Id)
-	if len(data.LastUsers) > LenHistory {
-		index := 0 //remove the oldest element
-		data.LastUsers = append(data.LastUsers[:index], data.LastUsers[index+1:]...)
+		newQuestion.ID = newQuestionID(data)
+
+		//questions need to be approved by a moderator before they can be asked
+		if config.RequireApproval {
+			if len(data.PendingQuestions) >= maxPendingQuestions {
+				errResponse = &model.CommandResponse{
+					ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+					Text:         fmt.Sprintf("Your question has not been added: There are already %d questions waiting for approval. Please try again later.", maxPendingQuestions),
+				}
+				return errSkipUpdate
+			}
+			if countPendingQuestions(data, newQuestion.Creator) >= maxPendingQuestionsPerUser {
+				errResponse = &model.CommandResponse{
+					ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+					Text:         fmt.Sprintf("Your question has not been added: %d of your questions are already waiting for approval. Please wait until a moderator approved them.", maxPendingQuestionsPerUser),
+				}
+				return errSkipUpdate
+			}
+			data.PendingQuestions = append(data.PendingQuestions, newQuestion)
+			return nil
+		}
+		data.Questions = append(data.Questions, newQuestion)
+		numQuestions = len(data.Questions)
+		return nil
+	})
+	if err != nil {
+		return p.getStorageErrorResponse(err)
 	}
-	data.LastQuestions = append(data.LastQuestions, *question)
-	if len(data.LastQuestions) > LenHistory {
-		index := 0 //remove the oldest element
-		data.LastQuestions = append(data.LastQuestions[:index], data.LastQuestions[index+1:]...)
+	if errResponse != nil {
+		return errResponse
 	}
-	p.WriteToStorage(&data)
 
-	if _, err = p.API.CreatePost(post); err != nil {
-		const errorMessage = "Error: Failed to create post"
-		p.API.LogError(errorMessage, "err", err.Error())
+	if config.RequireApproval {
+		p.notifyModerators(&newQuestion)
 		return &model.CommandResponse{
 			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
-			Text:         errorMessage,
+			Text:         fmt.Sprintf("Thanks %s! Your question '%s' will be asked once a moderator approved it.", creator.GetDisplayName(""), newQuestion.Question),
 		}
 	}
 
-	return &model.CommandResponse{}
+	return &model.CommandResponse{
+		ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+		Text:         fmt.Sprintf("Thanks %s! Added your question: '%s'. Total number of questions: %d", creator.GetDisplayName(""), newQuestion.Question, numQuestions),
+	}
 }
 
-func (p *Plugin) executeCommandIcebreakerAdd(args *model.CommandArgs) *model.CommandResponse {
-	//check the user input and extract the question from it
-	givenQuestion := strings.TrimPrefix(args.Command, fmt.Sprintf("/%s", commandIcebreakerAdd))
-	givenQuestion = strings.TrimPrefix(givenQuestion, " ")
-	if len(givenQuestion) <= 0 {
+// executeCommandIcebreakerEdit changes the text of a question. Creators can edit their own questions, unless new
+// questions need to be approved by a moderator, everyone else needs the permission to manage the questions
+func (p *Plugin) executeCommandIcebreakerEdit(args *model.CommandArgs) *model.CommandResponse {
+	givenQuestion := strings.TrimSpace(strings.TrimPrefix(args.Command, fmt.Sprintf("/%s", commandIcebreakerEdit)))
+	fields := strings.SplitN(givenQuestion, " ", 2)
+	if len(fields) < 2 || strings.TrimSpace(fields[1]) == "" {
 		return &model.CommandResponse{
 			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
-			Text:         "Error: Please enter a question",
+			Text:         "Error: Please enter the ID of the question, as per `/icebreaker list`, followed by the new question",
 		}
 	}
+	id := strings.ToLower(strings.Trim(fields[0], "`"))
+	givenQuestion = strings.TrimSpace(fields[1])
 
 	//deny questions that are too long
-	if len(givenQuestion) > 200 {
+	config := p.getConfiguration()
+	if len(givenQuestion) > config.getMaxQuestionLength() {
 		return &model.CommandResponse{
 			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
-			Text:         "Your question has not been added: Question too long, must be under 200 characters.",
+			Text:         fmt.Sprintf("Your question has not been changed: Question too long, must be under %d characters.", config.getMaxQuestionLength()),
 		}
 	}
 
-	newQuestion := Question{}
-	creator, _ := p.API.GetUser(args.UserId)
-	newQuestion.Creator = creator.Id
-	newQuestion.Question = givenQuestion
+	//the creator never changes, so the permission can be checked before the update
+	data, err := p.ReadFromStorage()
+	if err != nil {
+		return p.getStorageErrorResponse(err)
+	}
+	index, errResponse := getQuestionIndex(id, data.Questions)
+	if errResponse != nil {
+		return errResponse
+	}
+	if data.Questions[index].Creator != args.UserId || config.RequireApproval {
+		if response := p.requirePermission(args, permissionQuestions); response != nil {
+			return response
+		}
+	}
+
+	var previousQuestion string
+	err = p.updateData(func(data *IceBreakerData) error {
+		index, errResponse = getQuestionIndex(id, data.Questions)
+		if errResponse != nil {
+			return errSkipUpdate
+		}
+		question := &data.Questions[index]
+		previousQuestion = question.Question
+		if previousQuestion == givenQuestion {
+			errResponse = &model.CommandResponse{
+				ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+				Text:         "Error: The question has not been changed",
+			}
+			return errSkipUpdate
+		}
 
-	data := p.ReadFromStorage()
+		//check if the new question already exists within the same pool
+		editedQuestion := *question
+		editedQuestion.Question = givenQuestion
+		if containsQuestion(data.Questions, &editedQuestion) || containsQuestion(data.PendingQuestions, &editedQuestion) {
+			errResponse = &model.CommandResponse{
+				ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+				Text:         "Error: This question has already been added",
+			}
+			return errSkipUpdate
+		}
+
+		question.Edits = append(question.Edits, QuestionEdit{Question: previousQuestion, EditorID: args.UserId, EditedAt: model.GetMillis()})
+		if len(question.Edits) > maxQuestionEdits {
+			question.Edits = question.Edits[len(question.Edits)-maxQuestionEdits:]
+		}
+		question.Question = givenQuestion
+		return nil
+	})
+	if err != nil {
+		return p.getStorageErrorResponse(err)
+	}
+	if errResponse != nil {
+		return errResponse
+	}
+
+	return &model.CommandResponse{
+		ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+		Text:         fmt.Sprintf("Changed the question `%s` from '%s' to '%s'", id, previousQuestion, givenQuestion),
+	}
+}
 
-	//check if there are already too many questions
-	if len(data.Questions) > 1000 {
+func (p *Plugin) executeCommandIcebreakerScheduleAdd(args *model.CommandArgs) *model.CommandResponse {
+	givenCron := strings.TrimPrefix(args.Command, fmt.Sprintf("/%s", commandIcebreakerScheduleAdd))
+	givenCron, pair := extractSwitch(givenCron, "pair")
+	givenCron = strings.TrimSpace(givenCron)
+	if len(givenCron) <= 0 {
 		return &model.CommandResponse{
 			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
-			Text:         "Your question has not been added: There are already more than 1000 questions. Ask an Admin to clean up before adding more questions.",
+			Text:         "Error: Please enter a schedule, e.g. `/icebreaker schedule add 0 9 * * 1-5` for 9:00 UTC on every weekday",
+		}
+	}
+	if _, err := parseCron(givenCron); err != nil {
+		return &model.CommandResponse{
+			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+			Text:         fmt.Sprintf("Error: Your schedule is not valid: %s", err.Error()),
 		}
 	}
 
-	//Check if the question is already created
-	for _, question := range data.Questions {
-		if question.Question == newQuestion.Question {
-			return &model.CommandResponse{
+	newSchedule := Schedule{
+		ID:        model.NewId()[:8],
+		TeamID:    args.TeamId,
+		ChannelID: args.ChannelId,
+		Creator:   args.UserId,
+		Cron:      strings.Join(strings.Fields(givenCron), " "),
+	}
+	if pair {
+		newSchedule.Mode = scheduleModePair
+	}
+
+	var errResponse *model.CommandResponse
+	err := p.updateData(func(data *IceBreakerData) error {
+		//check if there are already too many schedules for this channel
+		numSchedules := 0
+		for _, schedule := range data.Schedules {
+			if schedule.ChannelID == args.ChannelId {
+				numSchedules++
+			}
+		}
+		if numSchedules >= maxSchedulesPerChannel {
+			errResponse = &model.CommandResponse{
 				ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
-				Text:         "Error: Your question has already been added",
+				Text:         fmt.Sprintf("Error: There are already %d icebreakers scheduled for this channel. Remove one before adding a new one.", numSchedules),
+			}
+			return errSkipUpdate
+		}
+
+		data.Schedules = append(data.Schedules, newSchedule)
+		return nil
+	})
+	if err != nil {
+		return p.getStorageErrorResponse(err)
+	}
+	if errResponse != nil {
+		return errResponse
+	}
+
+	return &model.CommandResponse{
+		ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+		Text:         fmt.Sprintf("Scheduled %s for this channel at `%s` (UTC). Id: %s", newSchedule.getDescription(), newSchedule.Cron, newSchedule.ID),
+	}
+}
+
+func (p *Plugin) executeCommandIcebreakerScheduleList(args *model.CommandArgs) *model.CommandResponse {
+	data, err := p.ReadFromStorage()
+	if err != nil {
+		return p.getStorageErrorResponse(err)
+	}
+
+	message := ""
+	for _, schedule := range data.Schedules {
+		if schedule.ChannelID != args.ChannelId {
+			continue
+		}
+		message = message + fmt.Sprintf("%s:\t`%s`", schedule.ID, schedule.Cron)
+		if schedule.Mode == scheduleModePair {
+			message = message + "\tpairing"
+		}
+		message = message + "\n"
+	}
+
+	if len(message) == 0 {
+		return &model.CommandResponse{
+			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+			Text:         "There are no icebreakers scheduled for this channel...",
+		}
+	}
+
+	return &model.CommandResponse{
+		ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+		Text:         "Scheduled icebreakers (UTC):\n" + message,
+	}
+}
+
+func (p *Plugin) executeCommandIcebreakerScheduleRemove(args *model.CommandArgs) *model.CommandResponse {
+	givenID := strings.TrimPrefix(args.Command, fmt.Sprintf("/%s", commandIcebreakerScheduleRemove))
+	givenID = strings.TrimSpace(givenID)
+	if len(givenID) <= 0 {
+		return &model.CommandResponse{
+			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+			Text:         "Error: Please enter the id of the schedule, as per `/icebreaker schedule list`",
+		}
+	}
+
+	removed := false
+	err := p.updateData(func(data *IceBreakerData) error {
+		removed = false
+		for index, schedule := range data.Schedules {
+			if schedule.ID == givenID && schedule.ChannelID == args.ChannelId {
+				data.Schedules = append(data.Schedules[:index], data.Schedules[index+1:]...)
+				removed = true
+				return nil
 			}
 		}
+		return errSkipUpdate
+	})
+	if err != nil {
+		return p.getStorageErrorResponse(err)
+	}
+	if removed {
+		return &model.CommandResponse{
+			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+			Text:         "Schedule removed",
+		}
 	}
-	data.Questions = append(data.Questions, newQuestion)
 
-	p.WriteToStorage(&data)
+	return &model.CommandResponse{
+		ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+		Text:         fmt.Sprintf("Error: There is no schedule with id %s in this channel", givenID),
+	}
+}
 
+func isValidScope(scope string) bool {
+	return scope == scopeGlobal || scope == scopeTeam || scope == scopeChannel
+}
+
+// getStorageErrorResponse logs the given storage error and returns a response informing the user about it
+func (p *Plugin) getStorageErrorResponse(err error) *model.CommandResponse {
+	p.API.LogError("Failed to access icebreaker data", "err", err.Error())
+
+	if errors.Cause(err) == errCorruptedData {
+		return &model.CommandResponse{
+			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+			Text:         fmt.Sprintf("Error: The stored icebreaker data is corrupted (%s). Ask an Admin to start over using `/icebreaker admin reset questions`.", err.Error()),
+		}
+	}
 	return &model.CommandResponse{
 		ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
-		Text:         fmt.Sprintf("Thanks %s! Added your question: '%s'. Total number of questions: %d", creator.GetDisplayName(""), newQuestion.Question, len(data.Questions)),
+		Text:         "Error: Failed to access the icebreaker data, please try again",
 	}
 }
@@ -3,15 +3,45 @@ package main
 import (
 	"bytes"
 	"encoding/json"
-	"math/rand"
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/mattermost/mattermost-server/v5/model"
 	"github.com/mattermost/mattermost-server/v5/plugin/plugintest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
+// matchIcebreakerPost matches an icebreaker post by its channel, thread and message, ignoring the attached buttons
+func matchIcebreakerPost(channelID string, rootID string, message string) interface{} {
+	return mock.MatchedBy(func(post *model.Post) bool {
+		return post.ChannelId == channelID && post.RootId == rootID && post.Message == message && len(post.Attachments()) == 1
+	})
+}
+
+// matchDataWithNewIDs matches stored data that equals the expected data, except for the generated IDs of the questions
+// that have no ID in the expected data
+func matchDataWithNewIDs(expected *IceBreakerData) interface{} {
+	expectedBytes := new(bytes.Buffer)
+	json.NewEncoder(expectedBytes).Encode(expected)
+	return mock.MatchedBy(func(kvData []byte) bool {
+		data := IceBreakerData{}
+		if err := json.Unmarshal(kvData, &data); err != nil || len(data.Questions) != len(expected.Questions) {
+			return false
+		}
+		for index := range data.Questions {
+			if expected.Questions[index].ID == "" && len(data.Questions[index].ID) == questionIDLength {
+				data.Questions[index].ID = ""
+			}
+		}
+		dataBytes := new(bytes.Buffer)
+		json.NewEncoder(dataBytes).Encode(&data)
+		return bytes.Equal(expectedBytes.Bytes(), dataBytes.Bytes())
+	})
+}
+
 func TestAskIcebreaker_fail(t *testing.T) {
 	t.Run("No questions", func(t *testing.T) {
 		icebreakerData := &IceBreakerData{Questions: []Question{}}
@@ -34,6 +64,29 @@ func TestAskIcebreaker_fail(t *testing.T) {
 		result := plugin.executeCommandIcebreaker(args)
 		assert.Equal(t, "Error: There are no questions that I can ask. Be the first one to propose a question by using `/icebreaker add <question>`", result.Text)
 	})
+	t.Run("No questions matching the filter", func(t *testing.T) {
+		icebreakerData := &IceBreakerData{Questions: []Question{
+			Question{
+				Creator: "TestUser", Question: "How do you do?", Category: "mild",
+			}}}
+		reqBodyBytes := new(bytes.Buffer)
+		json.NewEncoder(reqBodyBytes).Encode(icebreakerData)
+
+		plugin := &Plugin{}
+		api := &plugintest.API{}
+		api.On("KVGet", mock.AnythingOfType("string")).Return(reqBodyBytes.Bytes(), nil)
+		plugin.SetAPI(api)
+
+		args := &model.CommandArgs{
+			Command:   "/icebreaker ask #food",
+			ChannelId: "TestChannel",
+			TeamId:    "TestTeam",
+			UserId:    "TestUser",
+		}
+
+		result := plugin.executeCommandIcebreaker(args)
+		assert.Equal(t, "Error: There are no questions matching '#food'. Use `/icebreaker list` to see the available questions.", result.Text)
+	})
 	t.Run("No users in channel", func(t *testing.T) {
 		icebreakerData := &IceBreakerData{Questions: []Question{
 			Question{
@@ -164,11 +217,44 @@ func TestAskIcebreaker_fail(t *testing.T) {
 		result := plugin.executeCommandIcebreaker(args)
 		assert.Equal(t, "Error: Cannot get a user to ask a question for. Note: This plugin will not ask questions to offline or DND users.", result.Text)
 	})
+	t.Run("Failed post", func(t *testing.T) {
+		icebreakerData := &IceBreakerData{
+			Questions:         []Question{Question{ID: "q1", Creator: "TestUser", Question: "How do you do?"}},
+			ChannelStrategies: map[string]string{"TestChannel": strategyRoundRobin},
+		}
+		reqBodyBytes := new(bytes.Buffer)
+		json.NewEncoder(reqBodyBytes).Encode(icebreakerData)
+
+		api, _ := newFakeKVStore(map[string][]byte{KVKEY: reqBodyBytes.Bytes()})
+		api.On("GetUsersInChannel", mock.AnythingOfType("string"), mock.AnythingOfType("string"), mock.AnythingOfType("int"), mock.AnythingOfType("int")).
+			Return([]*model.User{&model.User{Id: "User1"}}, nil)
+		api.On("GetUserStatus", "User1").Return(&model.Status{Status: "online"}, nil)
+		api.On("CreatePost", mock.AnythingOfType("*model.Post")).Return(nil, &model.AppError{Message: "database is gone"})
+		api.On("LogError", mock.Anything, mock.Anything, mock.Anything)
+		plugin := &Plugin{}
+		plugin.SetAPI(api)
+
+		args := &model.CommandArgs{
+			Command:   "/icebreaker",
+			ChannelId: "TestChannel",
+			TeamId:    "TestTeam",
+			UserId:    "TestUser",
+		}
+
+		result := plugin.executeCommandIcebreaker(args)
+		assert.Equal(t, "Error: Failed to create post", result.Text)
+
+		//the user and question have not been asked, so they must not count as asked
+		data := readData(t, plugin)
+		assert.Empty(t, data.LastUsers)
+		assert.Empty(t, data.LastQuestions)
+		assert.Empty(t, data.UserRotations)
+		assert.Empty(t, data.QuestionRotations)
+	})
 }
 
 func TestAskIcebreaker_success(t *testing.T) {
 	t.Run("Successful, first user", func(t *testing.T) {
-		rand.Seed(1338)
 		icebreakerData := &IceBreakerData{Questions: []Question{
 			Question{
 				Creator: "TestUser", Question: "How do you do?",
@@ -185,23 +271,18 @@ func TestAskIcebreaker_success(t *testing.T) {
 			&model.User{Id: "SuccessUser2", Username: "success_user2"},
 		}
 
-		plugin := &Plugin{}
+		plugin := &Plugin{random: newRandom(1338)}
 		api := &plugintest.API{}
 		api.On("GetUser", mock.AnythingOfType("string")).Return(&model.User{Username: "TestUser"}, nil)
 		api.On("KVGet", mock.AnythingOfType("string")).Return(reqBodyBytes.Bytes(), nil)
-		api.On("KVSet", mock.AnythingOfType("string"), mock.AnythingOfType("[]uint8")).Return(nil)
+		api.On("KVCompareAndSet", mock.AnythingOfType("string"), mock.AnythingOfType("[]uint8"), mock.AnythingOfType("[]uint8")).Return(true, nil)
 		api.On("GetUsersInChannel", mock.AnythingOfType("string"), mock.AnythingOfType("string"), mock.AnythingOfType("int"), mock.AnythingOfType("int")).
 			Return(users, nil)
 		api.On("GetUserStatus", "User1").Return(&model.Status{Status: "offline"}, nil)
 		api.On("GetUserStatus", "User2").Return(&model.Status{Status: "dnd"}, nil)
 		api.On("GetUserStatus", "SuccessUser").Return(&model.Status{Status: "online"}, nil)
 		api.On("GetUserStatus", "SuccessUser2").Return(&model.Status{Status: "online"}, nil)
-		api.On("CreatePost", &model.Post{
-			ChannelId: "TestChannel",
-			RootId:    "TestRoot",
-			UserId:    "",
-			Message:   "Hey @success_user! How do you do?",
-		}).Return(nil, nil)
+		api.On("CreatePost", matchIcebreakerPost("TestChannel", "TestRoot", "Hey @success_user! How do you do?")).Return(nil, nil)
 
 		plugin.SetAPI(api)
 
@@ -216,7 +297,6 @@ func TestAskIcebreaker_success(t *testing.T) {
 		plugin.ExecuteCommand(nil, args)
 	})
 	t.Run("Successful, other user", func(t *testing.T) {
-		rand.Seed(1337)
 		icebreakerData := &IceBreakerData{Questions: []Question{
 			Question{
 				Creator: "TestUser", Question: "How do you do?",
@@ -233,23 +313,18 @@ func TestAskIcebreaker_success(t *testing.T) {
 			&model.User{Id: "SuccessUser2", Username: "success_user2"},
 		}
 
-		plugin := &Plugin{}
+		plugin := &Plugin{random: newRandom(1337)}
 		api := &plugintest.API{}
 		api.On("GetUser", mock.AnythingOfType("string")).Return(&model.User{Username: "TestUser"}, nil)
 		api.On("KVGet", mock.AnythingOfType("string")).Return(reqBodyBytes.Bytes(), nil)
-		api.On("KVSet", mock.AnythingOfType("string"), mock.AnythingOfType("[]uint8")).Return(nil)
+		api.On("KVCompareAndSet", mock.AnythingOfType("string"), mock.AnythingOfType("[]uint8"), mock.AnythingOfType("[]uint8")).Return(true, nil)
 		api.On("GetUsersInChannel", mock.AnythingOfType("string"), mock.AnythingOfType("string"), mock.AnythingOfType("int"), mock.AnythingOfType("int")).
 			Return(users, nil)
 		api.On("GetUserStatus", "User1").Return(&model.Status{Status: "offline"}, nil)
 		api.On("GetUserStatus", "User2").Return(&model.Status{Status: "dnd"}, nil)
 		api.On("GetUserStatus", "SuccessUser").Return(&model.Status{Status: "online"}, nil)
 		api.On("GetUserStatus", "SuccessUser2").Return(&model.Status{Status: "online"}, nil)
-		api.On("CreatePost", &model.Post{
-			ChannelId: "TestChannel",
-			RootId:    "TestRoot",
-			UserId:    "",
-			Message:   "Hey @success_user2! How do you do?",
-		}).Return(nil, nil)
+		api.On("CreatePost", matchIcebreakerPost("TestChannel", "TestRoot", "Hey @success_user2! How do you do?")).Return(nil, nil)
 
 		plugin.SetAPI(api)
 
@@ -264,7 +339,6 @@ func TestAskIcebreaker_success(t *testing.T) {
 		plugin.executeCommandIcebreaker(args)
 	})
 	t.Run("Successful, history", func(t *testing.T) {
-		rand.Seed(1338)
 		icebreakerData := &IceBreakerData{Questions: []Question{
 			Question{
 				Creator: "TestUser", Question: "First question",
@@ -309,11 +383,11 @@ func TestAskIcebreaker_success(t *testing.T) {
 			UserId:    "TestUser",
 		}
 
-		plugin := &Plugin{}
+		plugin := &Plugin{random: newRandom(1338)}
 		api := &plugintest.API{}
 		api.On("GetUser", mock.AnythingOfType("string")).Return(&model.User{Username: "TestUser"}, nil)
 		api.On("KVGet", mock.AnythingOfType("string")).Return(reqBodyBytes.Bytes(), nil)
-		api.On("KVSet", mock.AnythingOfType("string"), mock.AnythingOfType("[]uint8")).Return(nil)
+		api.On("KVCompareAndSet", mock.AnythingOfType("string"), mock.AnythingOfType("[]uint8"), mock.AnythingOfType("[]uint8")).Return(true, nil)
 		api.On("GetUsersInChannel", mock.AnythingOfType("string"), mock.AnythingOfType("string"), mock.AnythingOfType("int"), mock.AnythingOfType("int")).
 			Return(users, nil)
 		api.On("GetUserStatus", "User1").Return(&model.Status{Status: "offline"}, nil)
@@ -321,12 +395,7 @@ func TestAskIcebreaker_success(t *testing.T) {
 		api.On("GetUserStatus", "SuccessUser1").Return(&model.Status{Status: "online"}, nil)
 		api.On("GetUserStatus", "SuccessUser2").Return(&model.Status{Status: "online"}, nil)
 		api.On("GetUserStatus", "SuccessUser3").Return(&model.Status{Status: "online"}, nil)
-		api.On("CreatePost", &model.Post{
-			ChannelId: "TestChannel",
-			RootId:    "TestRoot",
-			UserId:    "",
-			Message:   "Hey @success_user3! Second question",
-		}).Return(nil, nil)
+		api.On("CreatePost", matchIcebreakerPost("TestChannel", "TestRoot", "Hey @success_user3! Second question")).Return(nil, nil)
 		plugin.SetAPI(api)
 
 		plugin.ExecuteCommand(nil, args)
@@ -390,16 +459,14 @@ func TestAddIcebreaker(t *testing.T) {
 		dataAfterAddingTheQuestion := &IceBreakerData{
 			Questions: []Question{
 				Question{
-					Creator: "TestUserId", Question: "How do you do?",
+					Creator: "TestUserId", Question: "How do you do?", Category: "uncategorized",
 				}}}
-		bytesAfterAddingTheQuestion := new(bytes.Buffer)
-		json.NewEncoder(bytesAfterAddingTheQuestion).Encode(dataAfterAddingTheQuestion)
 
 		plugin := &Plugin{}
 		api := &plugintest.API{}
 		api.On("GetUser", mock.AnythingOfType("string")).Return(&model.User{Username: "TestUser", Id: "TestUserId"}, nil)
 		api.On("KVGet", mock.AnythingOfType("string")).Return(reqBodyBytes.Bytes(), nil)
-		api.On("KVSet", "IceBreakerData_v2", bytesAfterAddingTheQuestion.Bytes()).Return(nil)
+		api.On("KVCompareAndSet", "IceBreakerData_v2", reqBodyBytes.Bytes(), matchDataWithNewIDs(dataAfterAddingTheQuestion)).Return(true, nil)
 		plugin.SetAPI(api)
 
 		args := &model.CommandArgs{
@@ -412,10 +479,138 @@ func TestAddIcebreaker(t *testing.T) {
 		result := plugin.executeCommandIcebreakerAdd(args)
 		assert.Equal(t, "Thanks TestUser! Added your question: 'How do you do?'. Total number of questions: 1", result.Text)
 	})
+
+	t.Run("Valid question, channel scope", func(t *testing.T) {
+		icebreakerData := &IceBreakerData{
+			Questions: []Question{
+				Question{
+					Creator: "TestUserId", Question: "How do you do?",
+				}}}
+		reqBodyBytes := new(bytes.Buffer)
+		json.NewEncoder(reqBodyBytes).Encode(icebreakerData)
+
+		dataAfterAddingTheQuestion := &IceBreakerData{
+			Questions: []Question{
+				Question{
+					Creator: "TestUserId", Question: "How do you do?",
+				},
+				Question{
+					Creator: "TestUserId", Question: "How do you do?", TeamID: "TestTeam", ChannelID: "TestChannel", Category: "uncategorized",
+				}}}
+
+		plugin := &Plugin{}
+		api := &plugintest.API{}
+		api.On("GetUser", mock.AnythingOfType("string")).Return(&model.User{Username: "TestUser", Id: "TestUserId"}, nil)
+		api.On("KVGet", mock.AnythingOfType("string")).Return(reqBodyBytes.Bytes(), nil)
+		api.On("KVCompareAndSet", "IceBreakerData_v2", reqBodyBytes.Bytes(), matchDataWithNewIDs(dataAfterAddingTheQuestion)).Return(true, nil)
+		plugin.SetAPI(api)
+
+		args := &model.CommandArgs{
+			Command:   "/icebreaker add --scope channel How do you do?",
+			ChannelId: "TestChannel",
+			TeamId:    "TestTeam",
+			UserId:    "TestUser",
+		}
+
+		result := plugin.executeCommandIcebreakerAdd(args)
+		assert.Equal(t, "Thanks TestUser! Added your question: 'How do you do?'. Total number of questions: 2", result.Text)
+	})
+
+	t.Run("Valid question, category and tags", func(t *testing.T) {
+		icebreakerData := &IceBreakerData{}
+		reqBodyBytes := new(bytes.Buffer)
+		json.NewEncoder(reqBodyBytes).Encode(icebreakerData)
+
+		dataAfterAddingTheQuestion := &IceBreakerData{
+			Questions: []Question{
+				Question{
+					Creator: "TestUserId", Question: "What is your favourite dish?", Category: "work", Tags: []string{"food", "drinks"},
+				}}}
+
+		plugin := &Plugin{}
+		api := &plugintest.API{}
+		api.On("GetUser", mock.AnythingOfType("string")).Return(&model.User{Username: "TestUser", Id: "TestUserId"}, nil)
+		api.On("KVGet", mock.AnythingOfType("string")).Return(reqBodyBytes.Bytes(), nil)
+		api.On("KVCompareAndSet", "IceBreakerData_v2", reqBodyBytes.Bytes(), matchDataWithNewIDs(dataAfterAddingTheQuestion)).Return(true, nil)
+		plugin.SetAPI(api)
+
+		args := &model.CommandArgs{
+			Command:   "/icebreaker add --category Work --tag #food --tag drinks What is your favourite dish?",
+			ChannelId: "TestChannel",
+			TeamId:    "TestTeam",
+			UserId:    "TestUser",
+		}
+
+		result := plugin.executeCommandIcebreakerAdd(args)
+		assert.Equal(t, "Thanks TestUser! Added your question: 'What is your favourite dish?'. Total number of questions: 1", result.Text)
+	})
+
+	t.Run("Invalid scope", func(t *testing.T) {
+		plugin := &Plugin{}
+		args := &model.CommandArgs{
+			Command: "/icebreaker add --scope galaxy How do you do?",
+		}
+		result := plugin.executeCommandIcebreakerAdd(args)
+		assert.Equal(t, "Error: Unknown scope 'galaxy', use one of: global, team, channel", result.Text)
+	})
+}
+
+func TestGetRandomQuestion_scopes(t *testing.T) {
+	icebreakerData := &IceBreakerData{Questions: []Question{
+		Question{Creator: "TestUser", Question: "Other team", TeamID: "OtherTeam"},
+		Question{Creator: "TestUser", Question: "Other channel", TeamID: "TestTeam", ChannelID: "OtherChannel"},
+		Question{Creator: "TestUser", Question: "Own channel", TeamID: "TestTeam", ChannelID: "TestChannel"},
+	}}
+	reqBodyBytes := new(bytes.Buffer)
+	json.NewEncoder(reqBodyBytes).Encode(icebreakerData)
+
+	plugin := &Plugin{}
+	api := &plugintest.API{}
+	api.On("KVGet", mock.AnythingOfType("string")).Return(reqBodyBytes.Bytes(), nil)
+	plugin.SetAPI(api)
+
+	for i := 0; i < 10; i++ {
+		question, err := plugin.GetRandomQuestion("TestTeam", "TestChannel", questionFilter{})
+		assert.Nil(t, err)
+		assert.Equal(t, "Own channel", question.Question)
+	}
+
+	_, err := plugin.GetRandomQuestion("TestTeam", "EmptyChannel", questionFilter{})
+	assert.NotNil(t, err)
+}
+
+func TestGetRandomQuestion_filter(t *testing.T) {
+	icebreakerData := &IceBreakerData{Questions: []Question{
+		Question{Creator: "TestUser", Question: "Uncategorized"},
+		Question{Creator: "TestUser", Question: "Work", Category: "work"},
+		Question{Creator: "TestUser", Question: "Food", Category: "mild", Tags: []string{"food"}},
+	}}
+	reqBodyBytes := new(bytes.Buffer)
+	json.NewEncoder(reqBodyBytes).Encode(icebreakerData)
+
+	plugin := &Plugin{}
+	api := &plugintest.API{}
+	api.On("KVGet", mock.AnythingOfType("string")).Return(reqBodyBytes.Bytes(), nil)
+	plugin.SetAPI(api)
+
+	question, err := plugin.GetRandomQuestion("TestTeam", "TestChannel", parseQuestionFilter("WORK"))
+	assert.Nil(t, err)
+	assert.Equal(t, "Work", question.Question)
+
+	question, err = plugin.GetRandomQuestion("TestTeam", "TestChannel", parseQuestionFilter("#food"))
+	assert.Nil(t, err)
+	assert.Equal(t, "Food", question.Question)
+
+	question, err = plugin.GetRandomQuestion("TestTeam", "TestChannel", parseQuestionFilter("uncategorized"))
+	assert.Nil(t, err)
+	assert.Equal(t, "Uncategorized", question.Question)
+
+	_, err = plugin.GetRandomQuestion("TestTeam", "TestChannel", parseQuestionFilter("holiday"))
+	assert.NotNil(t, err)
 }
 
 func TestRemoveIcebreaker(t *testing.T) {
-	t.Run("No index given", func(t *testing.T) {
+	t.Run("No ID given", func(t *testing.T) {
 		icebreakerData := &IceBreakerData{}
 		reqBodyBytes := new(bytes.Buffer)
 		json.NewEncoder(reqBodyBytes).Encode(icebreakerData)
@@ -427,17 +622,20 @@ func TestRemoveIcebreaker(t *testing.T) {
 		plugin := &Plugin{}
 		api := &plugintest.API{}
 		api.On("GetUser", mock.AnythingOfType("string")).Return(&model.User{Username: "TestUser", Id: "TestUserId", Roles: model.SYSTEM_ADMIN_ROLE_ID}, nil)
+		api.On("KVGet", backupIndexKey).Return(nil, nil)
+		api.On("KVCompareAndSet", backupIndexKey, mock.Anything, mock.AnythingOfType("[]uint8")).Return(true, nil)
+		api.On("KVSet", backupKeyPrefix+"0", reqBodyBytes.Bytes()).Return(nil)
 		api.On("KVGet", mock.AnythingOfType("string")).Return(reqBodyBytes.Bytes(), nil)
-		api.On("KVSet", "IceBreakerData_v2", bytesAfter.Bytes()).Return(nil)
+		api.On("KVCompareAndSet", "IceBreakerData_v2", reqBodyBytes.Bytes(), bytesAfter.Bytes()).Return(true, nil)
 		plugin.SetAPI(api)
 
 		args := &model.CommandArgs{
 			Command: "/icebreaker admin remove",
 		}
 		result, _ := plugin.ExecuteCommand(nil, args)
-		assert.Equal(t, "Error: Please enter a valid index", result.Text)
+		assert.Equal(t, "Error: Please enter the ID of a question, as per `/icebreaker list`", result.Text)
 	})
-	t.Run("Negative index", func(t *testing.T) {
+	t.Run("Unknown ID", func(t *testing.T) {
 		icebreakerData := &IceBreakerData{}
 		reqBodyBytes := new(bytes.Buffer)
 		json.NewEncoder(reqBodyBytes).Encode(icebreakerData)
@@ -449,21 +647,24 @@ func TestRemoveIcebreaker(t *testing.T) {
 		plugin := &Plugin{}
 		api := &plugintest.API{}
 		api.On("GetUser", mock.AnythingOfType("string")).Return(&model.User{Username: "TestUser", Id: "TestUserId", Roles: model.SYSTEM_ADMIN_ROLE_ID}, nil)
+		api.On("KVGet", backupIndexKey).Return(nil, nil)
+		api.On("KVCompareAndSet", backupIndexKey, mock.Anything, mock.AnythingOfType("[]uint8")).Return(true, nil)
+		api.On("KVSet", backupKeyPrefix+"0", reqBodyBytes.Bytes()).Return(nil)
 		api.On("KVGet", mock.AnythingOfType("string")).Return(reqBodyBytes.Bytes(), nil)
-		api.On("KVSet", "IceBreakerData_v2", bytesAfter.Bytes()).Return(nil)
+		api.On("KVCompareAndSet", "IceBreakerData_v2", reqBodyBytes.Bytes(), bytesAfter.Bytes()).Return(true, nil)
 		plugin.SetAPI(api)
 
 		args := &model.CommandArgs{
-			Command: "/icebreaker admin remove -1",
+			Command: "/icebreaker admin remove abc123",
 		}
 		result, _ := plugin.ExecuteCommand(nil, args)
-		assert.Equal(t, "Error: Your given index of -1 is not valid", result.Text)
+		assert.Equal(t, "Error: There is no question with the ID abc123", result.Text)
 	})
-	t.Run("Index out of bounds", func(t *testing.T) {
+	t.Run("List index instead of ID", func(t *testing.T) {
 		icebreakerData := &IceBreakerData{
 			Questions: []Question{
 				Question{
-					Creator: "TestUserId", Question: "How do you do?",
+					ID: "q1", Creator: "TestUserId", Question: "How do you do?",
 				}}}
 		reqBodyBytes := new(bytes.Buffer)
 		json.NewEncoder(reqBodyBytes).Encode(icebreakerData)
@@ -471,7 +672,7 @@ func TestRemoveIcebreaker(t *testing.T) {
 		dataAfter := &IceBreakerData{
 			Questions: []Question{
 				Question{
-					Creator: "TestUserId", Question: "How do you do?",
+					ID: "q1", Creator: "TestUserId", Question: "How do you do?",
 				}}}
 		bytesAfter := new(bytes.Buffer)
 		json.NewEncoder(bytesAfter).Encode(dataAfter)
@@ -479,21 +680,24 @@ func TestRemoveIcebreaker(t *testing.T) {
 		plugin := &Plugin{}
 		api := &plugintest.API{}
 		api.On("GetUser", mock.AnythingOfType("string")).Return(&model.User{Username: "TestUser", Id: "TestUserId", Roles: model.SYSTEM_ADMIN_ROLE_ID}, nil)
+		api.On("KVGet", backupIndexKey).Return(nil, nil)
+		api.On("KVCompareAndSet", backupIndexKey, mock.Anything, mock.AnythingOfType("[]uint8")).Return(true, nil)
+		api.On("KVSet", backupKeyPrefix+"0", reqBodyBytes.Bytes()).Return(nil)
 		api.On("KVGet", mock.AnythingOfType("string")).Return(reqBodyBytes.Bytes(), nil)
-		api.On("KVSet", "IceBreakerData_v2", bytesAfter.Bytes()).Return(nil)
+		api.On("KVCompareAndSet", "IceBreakerData_v2", reqBodyBytes.Bytes(), bytesAfter.Bytes()).Return(true, nil)
 		plugin.SetAPI(api)
 
 		args := &model.CommandArgs{
 			Command: "/icebreaker admin remove 1",
 		}
 		result, _ := plugin.ExecuteCommand(nil, args)
-		assert.Equal(t, "Error: Your given index of 1 is not valid", result.Text)
+		assert.Equal(t, "Error: There is no question with the ID 1", result.Text)
 	})
 	t.Run("Success", func(t *testing.T) {
 		icebreakerData := &IceBreakerData{
 			Questions: []Question{
 				Question{
-					Creator: "TestUserId", Question: "How do you do?",
+					ID: "q1", Creator: "TestUserId", Question: "How do you do?",
 				}}}
 		reqBodyBytes := new(bytes.Buffer)
 		json.NewEncoder(reqBodyBytes).Encode(icebreakerData)
@@ -506,12 +710,15 @@ func TestRemoveIcebreaker(t *testing.T) {
 		plugin := &Plugin{}
 		api := &plugintest.API{}
 		api.On("GetUser", mock.AnythingOfType("string")).Return(&model.User{Username: "TestUser", Id: "TestUserId", Roles: model.SYSTEM_ADMIN_ROLE_ID}, nil)
+		api.On("KVGet", backupIndexKey).Return(nil, nil)
+		api.On("KVCompareAndSet", backupIndexKey, mock.Anything, mock.AnythingOfType("[]uint8")).Return(true, nil)
+		api.On("KVSet", backupKeyPrefix+"0", reqBodyBytes.Bytes()).Return(nil)
 		api.On("KVGet", mock.AnythingOfType("string")).Return(reqBodyBytes.Bytes(), nil)
-		api.On("KVSet", "IceBreakerData_v2", bytesAfter.Bytes()).Return(nil)
+		api.On("KVCompareAndSet", "IceBreakerData_v2", reqBodyBytes.Bytes(), bytesAfter.Bytes()).Return(true, nil)
 		plugin.SetAPI(api)
 
 		args := &model.CommandArgs{
-			Command: "/icebreaker admin remove 0",
+			Command: "/icebreaker admin remove q1",
 		}
 		result, _ := plugin.ExecuteCommand(nil, args)
 		assert.Equal(t, "Question removed", result.Text)
@@ -520,13 +727,13 @@ func TestRemoveIcebreaker(t *testing.T) {
 		icebreakerData := &IceBreakerData{
 			Questions: []Question{
 				Question{
-					Creator: "TestUserId", Question: "Index 0",
+					ID: "q0", Creator: "TestUserId", Question: "Index 0",
 				},
 				Question{
-					Creator: "TestUserId", Question: "Index 1",
+					ID: "q1", Creator: "TestUserId", Question: "Index 1",
 				},
 				Question{
-					Creator: "TestUserId", Question: "Index 2",
+					ID: "q2", Creator: "TestUserId", Question: "Index 2",
 				},
 			}}
 		reqBodyBytes := new(bytes.Buffer)
@@ -535,10 +742,10 @@ func TestRemoveIcebreaker(t *testing.T) {
 		dataAfter := &IceBreakerData{
 			Questions: []Question{
 				Question{
-					Creator: "TestUserId", Question: "Index 0",
+					ID: "q0", Creator: "TestUserId", Question: "Index 0",
 				},
 				Question{
-					Creator: "TestUserId", Question: "Index 2",
+					ID: "q2", Creator: "TestUserId", Question: "Index 2",
 				},
 			}}
 		bytesAfter := new(bytes.Buffer)
@@ -547,12 +754,15 @@ func TestRemoveIcebreaker(t *testing.T) {
 		plugin := &Plugin{}
 		api := &plugintest.API{}
 		api.On("GetUser", mock.AnythingOfType("string")).Return(&model.User{Username: "TestUser", Id: "TestUserId", Roles: model.SYSTEM_ADMIN_ROLE_ID}, nil)
+		api.On("KVGet", backupIndexKey).Return(nil, nil)
+		api.On("KVCompareAndSet", backupIndexKey, mock.Anything, mock.AnythingOfType("[]uint8")).Return(true, nil)
+		api.On("KVSet", backupKeyPrefix+"0", reqBodyBytes.Bytes()).Return(nil)
 		api.On("KVGet", mock.AnythingOfType("string")).Return(reqBodyBytes.Bytes(), nil)
-		api.On("KVSet", "IceBreakerData_v2", bytesAfter.Bytes()).Return(nil)
+		api.On("KVCompareAndSet", "IceBreakerData_v2", reqBodyBytes.Bytes(), bytesAfter.Bytes()).Return(true, nil)
 		plugin.SetAPI(api)
 
 		args := &model.CommandArgs{
-			Command: "/icebreaker admin remove 1",
+			Command: "/icebreaker admin remove q1",
 		}
 		result, _ := plugin.ExecuteCommand(nil, args)
 		assert.Equal(t, "Question removed", result.Text)
@@ -561,13 +771,13 @@ func TestRemoveIcebreaker(t *testing.T) {
 		icebreakerData := &IceBreakerData{
 			Questions: []Question{
 				Question{
-					Creator: "TestUserId", Question: "Index 0",
+					ID: "q0", Creator: "TestUserId", Question: "Index 0",
 				},
 				Question{
-					Creator: "TestUserId", Question: "Index 1",
+					ID: "q1", Creator: "TestUserId", Question: "Index 1",
 				},
 				Question{
-					Creator: "TestUserId", Question: "Index 2",
+					ID: "q2", Creator: "TestUserId", Question: "Index 2",
 				},
 			}}
 		reqBodyBytes := new(bytes.Buffer)
@@ -576,10 +786,10 @@ func TestRemoveIcebreaker(t *testing.T) {
 		dataAfter := &IceBreakerData{
 			Questions: []Question{
 				Question{
-					Creator: "TestUserId", Question: "Index 0",
+					ID: "q0", Creator: "TestUserId", Question: "Index 0",
 				},
 				Question{
-					Creator: "TestUserId", Question: "Index 1",
+					ID: "q1", Creator: "TestUserId", Question: "Index 1",
 				},
 			}}
 		bytesAfter := new(bytes.Buffer)
@@ -588,21 +798,24 @@ func TestRemoveIcebreaker(t *testing.T) {
 		plugin := &Plugin{}
 		api := &plugintest.API{}
 		api.On("GetUser", mock.AnythingOfType("string")).Return(&model.User{Username: "TestUser", Id: "TestUserId", Roles: model.SYSTEM_ADMIN_ROLE_ID}, nil)
+		api.On("KVGet", backupIndexKey).Return(nil, nil)
+		api.On("KVCompareAndSet", backupIndexKey, mock.Anything, mock.AnythingOfType("[]uint8")).Return(true, nil)
+		api.On("KVSet", backupKeyPrefix+"0", reqBodyBytes.Bytes()).Return(nil)
 		api.On("KVGet", mock.AnythingOfType("string")).Return(reqBodyBytes.Bytes(), nil)
-		api.On("KVSet", "IceBreakerData_v2", bytesAfter.Bytes()).Return(nil)
+		api.On("KVCompareAndSet", "IceBreakerData_v2", reqBodyBytes.Bytes(), bytesAfter.Bytes()).Return(true, nil)
 		plugin.SetAPI(api)
 
 		args := &model.CommandArgs{
-			Command: "/icebreaker admin remove 2",
+			Command: "/icebreaker admin remove q2",
 		}
 		result, _ := plugin.ExecuteCommand(nil, args)
 		assert.Equal(t, "Question removed", result.Text)
 	})
-	t.Run("Success, multi index but only first is used", func(t *testing.T) {
+	t.Run("Success, multiple IDs but only first is used", func(t *testing.T) {
 		icebreakerData := &IceBreakerData{
 			Questions: []Question{
 				Question{
-					Creator: "TestUserId", Question: "How do you do?",
+					ID: "q1", Creator: "TestUserId", Question: "How do you do?",
 				}}}
 		reqBodyBytes := new(bytes.Buffer)
 		json.NewEncoder(reqBodyBytes).Encode(icebreakerData)
@@ -615,21 +828,24 @@ func TestRemoveIcebreaker(t *testing.T) {
 		plugin := &Plugin{}
 		api := &plugintest.API{}
 		api.On("GetUser", mock.AnythingOfType("string")).Return(&model.User{Username: "TestUser", Id: "TestUserId", Roles: model.SYSTEM_ADMIN_ROLE_ID}, nil)
+		api.On("KVGet", backupIndexKey).Return(nil, nil)
+		api.On("KVCompareAndSet", backupIndexKey, mock.Anything, mock.AnythingOfType("[]uint8")).Return(true, nil)
+		api.On("KVSet", backupKeyPrefix+"0", reqBodyBytes.Bytes()).Return(nil)
 		api.On("KVGet", mock.AnythingOfType("string")).Return(reqBodyBytes.Bytes(), nil)
-		api.On("KVSet", "IceBreakerData_v2", bytesAfter.Bytes()).Return(nil)
+		api.On("KVCompareAndSet", "IceBreakerData_v2", reqBodyBytes.Bytes(), bytesAfter.Bytes()).Return(true, nil)
 		plugin.SetAPI(api)
 
 		args := &model.CommandArgs{
-			Command: "/icebreaker admin remove 0 5",
+			Command: "/icebreaker admin remove q1 q5",
 		}
 		result, _ := plugin.ExecuteCommand(nil, args)
 		assert.Equal(t, "Question removed", result.Text)
 	})
-	t.Run("Multi index and first is out of bounds", func(t *testing.T) {
+	t.Run("Multiple IDs and first is unknown", func(t *testing.T) {
 		icebreakerData := &IceBreakerData{
 			Questions: []Question{
 				Question{
-					Creator: "TestUserId", Question: "How do you do?",
+					ID: "q1", Creator: "TestUserId", Question: "How do you do?",
 				}}}
 		reqBodyBytes := new(bytes.Buffer)
 		json.NewEncoder(reqBodyBytes).Encode(icebreakerData)
@@ -637,7 +853,7 @@ func TestRemoveIcebreaker(t *testing.T) {
 		dataAfter := &IceBreakerData{
 			Questions: []Question{
 				Question{
-					Creator: "TestUserId", Question: "How do you do?",
+					ID: "q1", Creator: "TestUserId", Question: "How do you do?",
 				}}}
 		bytesAfter := new(bytes.Buffer)
 		json.NewEncoder(bytesAfter).Encode(dataAfter)
@@ -645,14 +861,238 @@ func TestRemoveIcebreaker(t *testing.T) {
 		plugin := &Plugin{}
 		api := &plugintest.API{}
 		api.On("GetUser", mock.AnythingOfType("string")).Return(&model.User{Username: "TestUser", Id: "TestUserId", Roles: model.SYSTEM_ADMIN_ROLE_ID}, nil)
+		api.On("KVGet", backupIndexKey).Return(nil, nil)
+		api.On("KVCompareAndSet", backupIndexKey, mock.Anything, mock.AnythingOfType("[]uint8")).Return(true, nil)
+		api.On("KVSet", backupKeyPrefix+"0", reqBodyBytes.Bytes()).Return(nil)
 		api.On("KVGet", mock.AnythingOfType("string")).Return(reqBodyBytes.Bytes(), nil)
-		api.On("KVSet", "IceBreakerData_v2", bytesAfter.Bytes()).Return(nil)
+		api.On("KVCompareAndSet", "IceBreakerData_v2", reqBodyBytes.Bytes(), bytesAfter.Bytes()).Return(true, nil)
 		plugin.SetAPI(api)
 
 		args := &model.CommandArgs{
-			Command: "/icebreaker admin remove 5 0",
+			Command: "/icebreaker admin remove Q5 q1",
 		}
 		result, _ := plugin.ExecuteCommand(nil, args)
-		assert.Equal(t, "Error: Your given index of 5 is not valid", result.Text)
+		assert.Equal(t, "Error: There is no question with the ID q5", result.Text)
 	})
 }
+
+func TestQuestionIDs(t *testing.T) {
+	api, _ := newFakeKVStore(nil)
+	api.On("GetUser", "TestUser").Return(&model.User{Id: "TestUser", Username: "test_user", Roles: model.SYSTEM_ADMIN_ROLE_ID}, nil)
+	plugin := &Plugin{}
+	plugin.SetAPI(api)
+	execute := func(command string) string {
+		response, _ := plugin.ExecuteCommand(nil, &model.CommandArgs{Command: command, UserId: "TestUser"})
+		return response.Text
+	}
+
+	execute("/icebreaker add How do you do?")
+	execute("/icebreaker add What do you do?")
+	questions := readData(t, plugin).Questions
+	assert.Len(t, questions[0].ID, questionIDLength)
+	assert.NotEqual(t, questions[0].ID, questions[1].ID)
+
+	//the IDs stay the same when other questions are removed
+	assert.Equal(t, "Question removed", execute("/icebreaker admin remove "+questions[0].ID))
+	assert.Equal(t, []Question{questions[1]}, readData(t, plugin).Questions)
+	assert.Equal(t, "Questions:\n`"+questions[1].ID+"`\t@test_user:\tWhat do you do?\n", execute("/icebreaker list"))
+}
+
+func TestGetRandomQuestion_historyByID(t *testing.T) {
+	icebreakerData := &IceBreakerData{
+		Questions: []Question{
+			Question{ID: "q1", Creator: "TestUser", Question: "How do you do?"},
+			Question{ID: "q2", Creator: "TestUser", Question: "How are you doing?"},
+		},
+		//the text of the recently asked question has been changed in the meantime
+		LastQuestions: []Question{Question{ID: "q2", Creator: "TestUser", Question: "How are you?"}},
+	}
+	reqBodyBytes := new(bytes.Buffer)
+	json.NewEncoder(reqBodyBytes).Encode(icebreakerData)
+
+	plugin := &Plugin{}
+	api := &plugintest.API{}
+	api.On("KVGet", mock.AnythingOfType("string")).Return(reqBodyBytes.Bytes(), nil)
+	plugin.SetAPI(api)
+	plugin.setConfiguration(&configuration{NewItemWeight: 1000000})
+
+	for i := 0; i < 10; i++ {
+		question, err := plugin.GetRandomQuestion("TestTeam", "TestChannel", questionFilter{})
+		assert.Nil(t, err)
+		assert.Equal(t, "q1", question.ID)
+	}
+}
+
+func TestEditIcebreaker(t *testing.T) {
+	setup := func(t *testing.T) *Plugin {
+		icebreakerData := &IceBreakerData{Questions: []Question{
+			Question{ID: "q1", Creator: "Creator", Question: "How do you doo?"},
+			Question{ID: "q2", Creator: "Creator", Question: "What do you do?"},
+		}}
+		reqBodyBytes := new(bytes.Buffer)
+		json.NewEncoder(reqBodyBytes).Encode(icebreakerData)
+
+		api, _ := newFakeKVStore(map[string][]byte{KVKEY: reqBodyBytes.Bytes()})
+		api.On("GetUser", "Creator").Return(&model.User{Id: "Creator", Roles: model.SYSTEM_USER_ROLE_ID}, nil)
+		api.On("GetUser", "OtherUser").Return(&model.User{Id: "OtherUser", Roles: model.SYSTEM_USER_ROLE_ID}, nil)
+		api.On("GetUser", "AdminUser").Return(&model.User{Id: "AdminUser", Roles: model.SYSTEM_ADMIN_ROLE_ID}, nil)
+		plugin := &Plugin{}
+		plugin.SetAPI(api)
+		return plugin
+	}
+	execute := func(plugin *Plugin, userID string, command string) string {
+		response, _ := plugin.ExecuteCommand(nil, &model.CommandArgs{Command: command, UserId: userID})
+		return response.Text
+	}
+
+	t.Run("Creator", func(t *testing.T) {
+		plugin := setup(t)
+
+		assert.Equal(t, "Changed the question `q1` from 'How do you doo?' to 'How do you do?'", execute(plugin, "Creator", "/icebreaker edit q1 How do you do?"))
+		question := readData(t, plugin).Questions[0]
+		assert.Equal(t, "q1", question.ID)
+		assert.Equal(t, "Creator", question.Creator)
+		assert.Equal(t, "How do you do?", question.Question)
+		require.Len(t, question.Edits, 1)
+		assert.Equal(t, "How do you doo?", question.Edits[0].Question)
+		assert.Equal(t, "Creator", question.Edits[0].EditorID)
+	})
+	t.Run("Admin", func(t *testing.T) {
+		plugin := setup(t)
+
+		assert.Equal(t, "Changed the question `q1` from 'How do you doo?' to 'How do you do?'", execute(plugin, "AdminUser", "/icebreaker edit q1 How do you do?"))
+		assert.Equal(t, "AdminUser", readData(t, plugin).Questions[0].Edits[0].EditorID)
+	})
+	t.Run("Other user", func(t *testing.T) {
+		plugin := setup(t)
+
+		assert.Equal(t, "Error: You need to be System Admin in order to use this command", execute(plugin, "OtherUser", "/icebreaker edit q1 How do you do?"))
+		assert.Equal(t, "How do you doo?", readData(t, plugin).Questions[0].Question)
+	})
+	t.Run("Creator when approval is required", func(t *testing.T) {
+		plugin := setup(t)
+		plugin.setConfiguration(&configuration{RequireApproval: true})
+
+		assert.Equal(t, "Error: You need to be System Admin in order to use this command", execute(plugin, "Creator", "/icebreaker edit q1 How do you do?"))
+	})
+	t.Run("Invalid edits", func(t *testing.T) {
+		plugin := setup(t)
+
+		assert.Equal(t, "Error: Please enter the ID of the question, as per `/icebreaker list`, followed by the new question", execute(plugin, "Creator", "/icebreaker edit q1"))
+		assert.Equal(t, "Error: There is no question with the ID q3", execute(plugin, "Creator", "/icebreaker edit q3 How do you do?"))
+		assert.Equal(t, "Error: This question has already been added", execute(plugin, "Creator", "/icebreaker edit q1 What do you do?"))
+		assert.Equal(t, "Error: The question has not been changed", execute(plugin, "Creator", "/icebreaker edit q1 How do you doo?"))
+		assert.True(t, strings.HasPrefix(execute(plugin, "Creator", "/icebreaker edit q1 "+strings.Repeat("a", defaultMaxQuestionLength+1)), "Your question has not been changed: Question too long"))
+		assert.Empty(t, readData(t, plugin).Questions[0].Edits)
+	})
+	t.Run("Edit history is limited", func(t *testing.T) {
+		plugin := setup(t)
+
+		for i := 0; i < maxQuestionEdits+2; i++ {
+			execute(plugin, "Creator", fmt.Sprintf("/icebreaker edit q1 How do you do %d?", i))
+		}
+		question := readData(t, plugin).Questions[0]
+		require.Len(t, question.Edits, maxQuestionEdits)
+		assert.Equal(t, "How do you do 1?", question.Edits[0].Question)
+		assert.Equal(t, fmt.Sprintf("How do you do %d?", maxQuestionEdits+1), question.Question)
+	})
+}
+
+func TestAskIcebreaker_users(t *testing.T) {
+	setup := func(t *testing.T) (*Plugin, *plugintest.API) {
+		icebreakerData := &IceBreakerData{
+			Questions:       []Question{Question{ID: "q1", Creator: "TestUser", Question: "How do you do?"}},
+			UserPreferences: map[string]*UserPreferences{"Bob": &UserPreferences{OptedOut: true}},
+		}
+		reqBodyBytes := new(bytes.Buffer)
+		json.NewEncoder(reqBodyBytes).Encode(icebreakerData)
+
+		api, _ := newFakeKVStore(map[string][]byte{KVKEY: reqBodyBytes.Bytes()})
+		api.On("GetUserStatus", "Carol").Return(&model.Status{Status: model.STATUS_OFFLINE}, nil)
+		for _, user := range []*model.User{
+			&model.User{Id: "Alice", Username: "alice"},
+			&model.User{Id: "Bob", Username: "bob"},
+			&model.User{Id: "Carol", Username: "carol"},
+			&model.User{Id: "Dave", Username: "dave"},
+			&model.User{Id: "Eve", Username: "eve"},
+		} {
+			api.On("GetUserByUsername", user.Username).Return(user, nil)
+			api.On("GetUserStatus", user.Id).Return(&model.Status{Status: model.STATUS_ONLINE}, nil)
+		}
+		api.On("GetUserByUsername", mock.AnythingOfType("string")).Return(nil, &model.AppError{})
+		api.On("GetChannelMember", "TestChannel", "Dave").Return(nil, &model.AppError{})
+		api.On("GetChannelMember", "TestChannel", mock.AnythingOfType("string")).Return(&model.ChannelMember{}, nil)
+		api.On("CreatePost", mock.AnythingOfType("*model.Post")).Return(nil, nil)
+		plugin := &Plugin{}
+		plugin.SetAPI(api)
+		return plugin, api
+	}
+	execute := func(plugin *Plugin, command string) *model.CommandResponse {
+		response, _ := plugin.ExecuteCommand(nil, &model.CommandArgs{Command: command, UserId: "TestUser", TeamId: "TestTeam", ChannelId: "TestChannel"})
+		return response
+	}
+
+	t.Run("Single user", func(t *testing.T) {
+		plugin, api := setup(t)
+
+		assert.Equal(t, &model.CommandResponse{}, execute(plugin, "/icebreaker ask @alice"))
+		api.AssertCalled(t, "CreatePost", matchIcebreakerPost("TestChannel", "", "Hey @alice! How do you do?"))
+		assert.Equal(t, []string{"Alice"}, readData(t, plugin).LastUsers)
+	})
+	t.Run("Multiple users with a filter", func(t *testing.T) {
+		plugin, api := setup(t)
+
+		assert.Equal(t, &model.CommandResponse{}, execute(plugin, "/icebreaker ask @alice uncategorized @eve @alice"))
+		api.AssertCalled(t, "CreatePost", matchIcebreakerPost("TestChannel", "", "Hey @alice! How do you do?"))
+		api.AssertCalled(t, "CreatePost", matchIcebreakerPost("TestChannel", "", "Hey @eve! How do you do?"))
+		api.AssertNumberOfCalls(t, "CreatePost", 2)
+	})
+	t.Run("Some users cannot be asked", func(t *testing.T) {
+		plugin, api := setup(t)
+
+		response := execute(plugin, "/icebreaker ask @alice @bob @carol @dave @nobody")
+		assert.Equal(t, "I did not ask everyone:\n"+
+			"* @bob does not want to be asked icebreaker questions in this channel\n"+
+			"* @carol is offline right now\n"+
+			"* @dave is not a member of this channel\n"+
+			"* Cannot find user @nobody", response.Text)
+		api.AssertNumberOfCalls(t, "CreatePost", 1)
+	})
+	t.Run("Nobody can be asked", func(t *testing.T) {
+		plugin, api := setup(t)
+
+		response := execute(plugin, "/icebreaker ask @bob")
+		assert.Equal(t, "Error: I cannot ask anyone of them:\n* @bob does not want to be asked icebreaker questions in this channel", response.Text)
+		api.AssertNotCalled(t, "CreatePost", mock.Anything)
+	})
+	t.Run("No matching questions", func(t *testing.T) {
+		plugin, _ := setup(t)
+
+		response := execute(plugin, "/icebreaker ask @alice #food")
+		assert.Equal(t, "Error: There are no questions matching '#food'. Use `/icebreaker list` to see the available questions.", response.Text)
+	})
+}
+
+func TestExtractFlag(t *testing.T) {
+	command, value, found := extractFlag(" --scope team --category work  How do you  do?", "scope")
+	assert.True(t, found)
+	assert.Equal(t, "team", value)
+	assert.Equal(t, " --category work  How do you  do?", command)
+
+	//flags within the text are part of the question, and so is its whitespace
+	command, value, found = extractFlag(" What does --scope team  mean?", "scope")
+	assert.False(t, found)
+	assert.Equal(t, "", value)
+	assert.Equal(t, " What does --scope team  mean?", command)
+
+	command, tags := extractFlags("--tag a --mine --tag b text --tag c", "tag")
+	assert.Equal(t, []string{"a", "b"}, tags)
+	assert.Equal(t, "--mine text --tag c", command)
+
+	command, found = extractSwitch("--sort popular --mine food --mine", "mine")
+	assert.True(t, found)
+	assert.Equal(t, "--sort popular food --mine", command)
+
+	_, _, found = extractFlag("--scope", "scope")
+	assert.False(t, found)
+}
@@ -2,10 +2,23 @@ package main
 
 import (
 	"reflect"
+	"strings"
+	"time"
 
+	"github.com/mattermost/mattermost-server/v5/model"
 	"github.com/pkg/errors"
 )
 
+const (
+	//defaults that are used when a setting has not been configured
+	defaultMaxQuestionLength = 200
+	defaultMaxQuestions      = 1000
+	defaultMaxChannelUsers   = 1000
+	defaultNewItemWeight     = 1000
+	defaultSkippedStatuses   = model.STATUS_OFFLINE + "," + model.STATUS_DND
+	defaultFlagThreshold     = 3
+)
+
 // configuration captures the plugin's external configuration as exposed in the Mattermost server
 // configuration, as well as values computed from the configuration. Any public fields will be
 // deserialized from the Mattermost server configuration in OnConfigurationChange.
@@ -18,6 +31,57 @@ import (
 // If you add non-reference types to your configuration struct, be sure to rewrite Clone as a deep
 // copy appropriate for your types.
 type configuration struct {
+	// QuestionsPermission is the minimum role needed to manage the questions, e.g. `admin remove`
+	QuestionsPermission string
+
+	// ChannelSettingsPermission is the minimum role needed to change the settings of a channel, e.g. `schedule add`
+	ChannelSettingsPermission string
+
+	// HistoryLength sets how many asked users and questions are remembered to avoid asking them over and over
+	HistoryLength int
+
+	// MaxQuestionLength is the maximum number of characters of a question
+	MaxQuestionLength int
+
+	// MaxQuestions is the maximum number of stored questions
+	MaxQuestions int
+
+	// MaxChannelUsers limits how many users of a channel are considered when choosing who to ask
+	MaxChannelUsers int
+
+	// NewItemWeight is the weight of users and questions that have not been asked lately.
+	// Recently asked ones are weighted by their position in the history, so this needs to be larger than HistoryLength
+	NewItemWeight int
+
+	// SelectionStrategy is how users and questions are chosen in channels without a strategy of their own, e.g. `roundrobin`
+	SelectionStrategy string
+
+	// SkippedStatuses is a comma separated list of user statuses that are never asked, e.g. `offline,dnd`
+	SkippedStatuses string
+
+	// RequireApproval makes new questions wait for the approval of a moderator before they can be asked
+	RequireApproval bool
+
+	// Moderators is a comma separated list of usernames that approve new questions, System Admins if empty
+	Moderators string
+
+	// WelcomeDelay is the number of seconds after which users joining a welcome channel are asked an icebreaker
+	WelcomeDelay int
+
+	// WelcomeSkipGuests stops guests joining a welcome channel from being asked an icebreaker
+	WelcomeSkipGuests bool
+
+	// WorkingHours is the range of hours users are working in their own timezone, e.g. `9-17`
+	WorkingHours string
+
+	// WorkingDays is the range or list of days of the week users are working, e.g. `1-5` for Monday to Friday
+	WorkingDays string
+
+	// FlagThreshold is how many more down votes than up votes a question needs to be flagged for review
+	FlagThreshold int
+
+	// OnlyWorkingHours makes all channels only ask users during their working hours, not just the ones with `admin workinghours on`
+	OnlyWorkingHours bool
 }
 
 // Clone shallow copies the configuration. Your implementation may require a deep copy if
@@ -27,6 +91,157 @@ func (c *configuration) Clone() *configuration {
 	return &clone
 }
 
+// IsValid checks the configured values, unset values are valid as they fall back to their defaults
+func (c *configuration) IsValid() error {
+	if c.QuestionsPermission != "" && !isValidRole(c.QuestionsPermission) {
+		return errors.Errorf("unknown role %q for QuestionsPermission", c.QuestionsPermission)
+	}
+	if c.ChannelSettingsPermission != "" && !isValidRole(c.ChannelSettingsPermission) {
+		return errors.Errorf("unknown role %q for ChannelSettingsPermission", c.ChannelSettingsPermission)
+	}
+	if c.HistoryLength < 0 {
+		return errors.New("HistoryLength must not be negative")
+	}
+	if c.MaxQuestionLength < 0 || c.MaxQuestionLength > model.POST_MESSAGE_MAX_RUNES_V1 {
+		return errors.Errorf("MaxQuestionLength must be between 0 and %d", model.POST_MESSAGE_MAX_RUNES_V1)
+	}
+	if c.MaxQuestions < 0 {
+		return errors.New("MaxQuestions must not be negative")
+	}
+	if c.MaxChannelUsers < 0 {
+		return errors.New("MaxChannelUsers must not be negative")
+	}
+	if c.FlagThreshold < 0 {
+		return errors.New("FlagThreshold must not be negative")
+	}
+	if c.WelcomeDelay < 0 {
+		return errors.New("WelcomeDelay must not be negative")
+	}
+	if c.NewItemWeight < 0 {
+		return errors.New("NewItemWeight must not be negative")
+	}
+	if c.getNewItemWeight() <= c.getHistoryLength() {
+		return errors.Errorf("NewItemWeight (%d) must be larger than HistoryLength (%d)", c.getNewItemWeight(), c.getHistoryLength())
+	}
+	if c.SelectionStrategy != "" && !isValidStrategy(c.SelectionStrategy) {
+		return errors.Errorf("unknown strategy %q for SelectionStrategy", c.SelectionStrategy)
+	}
+	if _, err := c.getWorkingWindow(); err != nil {
+		return err
+	}
+	for _, status := range c.getSkippedStatuses() {
+		if status != model.STATUS_ONLINE && status != model.STATUS_AWAY && status != model.STATUS_OFFLINE && status != model.STATUS_DND {
+			return errors.Errorf("unknown status %q in SkippedStatuses", status)
+		}
+	}
+	return nil
+}
+
+func (c *configuration) getHistoryLength() int {
+	if c.HistoryLength <= 0 {
+		return LenHistory
+	}
+	return c.HistoryLength
+}
+
+func (c *configuration) getMaxQuestionLength() int {
+	if c.MaxQuestionLength <= 0 {
+		return defaultMaxQuestionLength
+	}
+	return c.MaxQuestionLength
+}
+
+func (c *configuration) getMaxQuestions() int {
+	if c.MaxQuestions <= 0 {
+		return defaultMaxQuestions
+	}
+	return c.MaxQuestions
+}
+
+func (c *configuration) getMaxChannelUsers() int {
+	if c.MaxChannelUsers <= 0 {
+		return defaultMaxChannelUsers
+	}
+	return c.MaxChannelUsers
+}
+
+func (c *configuration) getNewItemWeight() int {
+	if c.NewItemWeight <= 0 {
+		return defaultNewItemWeight
+	}
+	return c.NewItemWeight
+}
+
+func (c *configuration) getSelectionStrategy() string {
+	if !isValidStrategy(c.SelectionStrategy) {
+		return strategyWeighted
+	}
+	return c.SelectionStrategy
+}
+
+func (c *configuration) getFlagThreshold() int {
+	if c.FlagThreshold <= 0 {
+		return defaultFlagThreshold
+	}
+	return c.FlagThreshold
+}
+
+// getWelcomeDelay returns how long new members are given before they are asked, 0 asks them right away.
+// Its default of 60 seconds comes from the plugin settings, as an unset value cannot be told apart from 0
+func (c *configuration) getWelcomeDelay() time.Duration {
+	return time.Duration(c.WelcomeDelay) * time.Second
+}
+
+func (c *configuration) getWorkingHours() string {
+	if strings.TrimSpace(c.WorkingHours) == "" {
+		return defaultWorkingHours
+	}
+	return strings.TrimSpace(c.WorkingHours)
+}
+
+func (c *configuration) getWorkingDays() string {
+	if strings.TrimSpace(c.WorkingDays) == "" {
+		return defaultWorkingDays
+	}
+	return strings.TrimSpace(c.WorkingDays)
+}
+
+// getWorkingWindow returns the parsed working hours and days
+func (c *configuration) getWorkingWindow() (*workingWindow, error) {
+	return parseWorkingWindow(c.getWorkingHours(), c.getWorkingDays())
+}
+
+func (c *configuration) getSkippedStatuses() []string {
+	skippedStatuses := strings.TrimSpace(c.SkippedStatuses)
+	if skippedStatuses == "" {
+		skippedStatuses = defaultSkippedStatuses
+	}
+
+	statuses := []string{}
+	for _, status := range strings.Split(skippedStatuses, ",") {
+		if status = strings.ToLower(strings.TrimSpace(status)); status != "" {
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses
+}
+
+// getModerators returns the usernames of the configured moderators
+func (c *configuration) getModerators() []string {
+	moderators := []string{}
+	for _, username := range strings.Split(c.Moderators, ",") {
+		if username = strings.TrimPrefix(strings.TrimSpace(username), "@"); username != "" {
+			moderators = append(moderators, username)
+		}
+	}
+	return moderators
+}
+
+// isSkippedStatus checks whether users with the given status should not be asked
+func (c *configuration) isSkippedStatus(status string) bool {
+	return containsString(c.getSkippedStatuses(), status)
+}
+
 // getConfiguration retrieves the active configuration under lock, making it safe to use
 // concurrently. The active configuration may change underneath the client of this method, but
 // the struct returned by this API call is considered immutable.
@@ -79,6 +294,9 @@ func (p *Plugin) OnConfigurationChange() error {
 	if loadConfigErr := p.API.LoadPluginConfiguration(configuration); loadConfigErr != nil {
 		return errors.Wrap(loadConfigErr, "failed to load plugin configuration")
 	}
+	if err := configuration.IsValid(); err != nil {
+		return errors.Wrap(err, "invalid plugin configuration")
+	}
 
 	p.setConfiguration(configuration)
 	return nil
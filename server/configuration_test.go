@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/mattermost/mattermost-server/v5/plugin/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfiguration_IsValid(t *testing.T) {
+	validConfigs := []*configuration{
+		&configuration{},
+		&configuration{HistoryLength: 10, MaxQuestionLength: 500, MaxQuestions: 50, MaxChannelUsers: 200, NewItemWeight: 11, SkippedStatuses: "offline, DND, away"},
+		&configuration{QuestionsPermission: roleTeamAdmin, ChannelSettingsPermission: roleSystemAdmin},
+		&configuration{WorkingHours: "22-6", WorkingDays: "0,6"},
+		&configuration{SelectionStrategy: strategyRoundRobin},
+	}
+	for _, config := range validConfigs {
+		assert.NoError(t, config.IsValid(), "%+v", config)
+	}
+
+	invalidConfigs := []*configuration{
+		&configuration{QuestionsPermission: "everyone"},
+		&configuration{ChannelSettingsPermission: "everyone"},
+		&configuration{HistoryLength: -1},
+		&configuration{MaxQuestionLength: -1},
+		&configuration{MaxQuestionLength: 5000},
+		&configuration{MaxQuestions: -1},
+		&configuration{MaxChannelUsers: -1},
+		&configuration{NewItemWeight: -1},
+		&configuration{WelcomeDelay: -1},
+		&configuration{WorkingHours: "9-25"},
+		&configuration{WorkingDays: "mon-fri"},
+		&configuration{NewItemWeight: 50},
+		&configuration{HistoryLength: 2000},
+		&configuration{SkippedStatuses: "offline,busy"},
+		&configuration{SelectionStrategy: "random"},
+	}
+	for _, config := range invalidConfigs {
+		assert.Error(t, config.IsValid(), "%+v", config)
+	}
+}
+
+func TestConfiguration_defaults(t *testing.T) {
+	config := &configuration{}
+	assert.Equal(t, LenHistory, config.getHistoryLength())
+	assert.Equal(t, 200, config.getMaxQuestionLength())
+	assert.Equal(t, 1000, config.getMaxQuestions())
+	assert.Equal(t, 1000, config.getMaxChannelUsers())
+	assert.Equal(t, 1000, config.getNewItemWeight())
+	assert.Equal(t, []string{"offline", "dnd"}, config.getSkippedStatuses())
+	assert.Equal(t, time.Duration(0), config.getWelcomeDelay())
+	assert.Equal(t, strategyWeighted, config.getSelectionStrategy())
+	assert.True(t, config.isSkippedStatus("dnd"))
+	assert.False(t, config.isSkippedStatus("away"))
+
+	config = &configuration{SkippedStatuses: " Away ,,offline"}
+	assert.Equal(t, []string{"away", "offline"}, config.getSkippedStatuses())
+
+	config = &configuration{WelcomeDelay: 60}
+	assert.Equal(t, time.Minute, config.getWelcomeDelay())
+}
+
+func TestOnConfigurationChange(t *testing.T) {
+	loadConfig := func(loaded configuration) *plugintest.API {
+		api := &plugintest.API{}
+		api.On("LoadPluginConfiguration", mock.AnythingOfType("*main.configuration")).Return(nil).Run(func(args mock.Arguments) {
+			*args.Get(0).(*configuration) = loaded
+		})
+		return api
+	}
+
+	t.Run("Valid configuration is applied", func(t *testing.T) {
+		plugin := &Plugin{}
+		plugin.SetAPI(loadConfig(configuration{MaxQuestionLength: 20}))
+		assert.NoError(t, plugin.OnConfigurationChange())
+		assert.Equal(t, 20, plugin.getConfiguration().getMaxQuestionLength())
+	})
+	t.Run("Invalid configuration is rejected", func(t *testing.T) {
+		plugin := &Plugin{}
+		plugin.SetAPI(loadConfig(configuration{MaxQuestionLength: -5}))
+		assert.Error(t, plugin.OnConfigurationChange())
+		assert.Equal(t, 200, plugin.getConfiguration().getMaxQuestionLength())
+	})
+}
+
+func TestAddIcebreaker_configuredLength(t *testing.T) {
+	api, _ := newFakeKVStore(nil)
+	api.On("GetUser", mock.AnythingOfType("string")).Return(&model.User{Id: "TestUser"}, nil)
+	plugin := &Plugin{}
+	plugin.SetAPI(api)
+	plugin.setConfiguration(&configuration{MaxQuestionLength: 10})
+
+	response, _ := plugin.ExecuteCommand(nil, &model.CommandArgs{Command: "/icebreaker add This question is too long", UserId: "TestUser"})
+	assert.Equal(t, "Your question has not been added: Question too long, must be under 10 characters.", response.Text)
+	assert.Empty(t, readData(t, plugin).Questions)
+}
+
+func TestPostIcebreaker_configuredHistoryLength(t *testing.T) {
+	icebreakerData := IceBreakerData{
+		Questions:     []Question{Question{ID: "q1", Creator: "TestUser", Question: "How do you do?"}},
+		LastUsers:     []string{"User1", "User2", "User3", "User4"},
+		LastQuestions: []Question{Question{ID: "q2"}, Question{ID: "q3"}, Question{ID: "q4"}, Question{ID: "q5"}},
+	}
+	dataBytes, err := json.Marshal(icebreakerData)
+	require.NoError(t, err)
+
+	api, _ := newFakeKVStore(map[string][]byte{KVKEY: dataBytes})
+	api.On("CreatePost", mock.AnythingOfType("*model.Post")).Return(&model.Post{Id: "IcebreakerPost", ChannelId: "TestChannel"}, nil)
+	plugin := &Plugin{botID: "BotUser"}
+	plugin.SetAPI(api)
+	plugin.setConfiguration(&configuration{HistoryLength: 2})
+
+	//a lowered history length shrinks the stored history right away
+	require.NoError(t, plugin.postIcebreaker("TestTeam", "TestChannel", "", &model.User{Id: "AskedUser", Username: "asked_user"}, &icebreakerData.Questions[0], questionFilter{}))
+	data := readData(t, plugin)
+	assert.Equal(t, []string{"User4", "AskedUser"}, data.LastUsers)
+	assert.Equal(t, []Question{Question{ID: "q5"}, icebreakerData.Questions[0]}, data.LastQuestions)
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	//exportFileName is the name of the exported files, without extension
+	exportFileName = "icebreaker-questions"
+
+	//maxImportErrorsShown limits how many skipped rows are explained in the import summary
+	maxImportErrorsShown = 10
+)
+
+// csvHeader are the columns of the CSV export, imports expect the same columns in any order
+var csvHeader = []string{"question", "creator", "category", "tags", "team_id", "channel_id", "recently_asked"}
+
+// ExportedQuestion is a question as it is stored in the exported files
+type ExportedQuestion struct {
+	Question      string   `json:"question"`
+	Creator       string   `json:"creator"`
+	Category      string   `json:"category,omitempty"`
+	Tags          []string `json:"tags,omitempty"`
+	TeamID        string   `json:"team_id,omitempty"`
+	ChannelID     string   `json:"channel_id,omitempty"`
+	RecentlyAsked int      `json:"recently_asked"`
+}
+
+// importResult sums up what happened to the rows of an imported file
+type importResult struct {
+	Rows       int
+	Added      int
+	Duplicates int
+	Invalid    []string
+}
+
+// exportQuestions converts the stored questions into their exported form
+func (p *Plugin) exportQuestions(data *IceBreakerData) []ExportedQuestion {
+	usernames := map[string]string{}
+	exported := []ExportedQuestion{}
+	for _, question := range data.Questions {
+		creator, ok := usernames[question.Creator]
+		if !ok {
+			creator = question.Creator
+			if user, appErr := p.API.GetUser(question.Creator); appErr == nil {
+				creator = user.Username
+			}
+			usernames[question.Creator] = creator
+		}
+
+		recentlyAsked := 0
+		for _, lastQuestion := range data.LastQuestions {
+			if lastQuestion.isSameAs(&question) && lastQuestion.TeamID == question.TeamID && lastQuestion.ChannelID == question.ChannelID {
+				recentlyAsked++
+			}
+		}
+
+		exported = append(exported, ExportedQuestion{
+			Question:      question.Question,
+			Creator:       creator,
+			Category:      question.Category,
+			Tags:          question.Tags,
+			TeamID:        question.TeamID,
+			ChannelID:     question.ChannelID,
+			RecentlyAsked: recentlyAsked,
+		})
+	}
+	return exported
+}
+
+func encodeQuestionsCSV(questions []ExportedQuestion) ([]byte, error) {
+	buffer := new(bytes.Buffer)
+	writer := csv.NewWriter(buffer)
+	if err := writer.Write(csvHeader); err != nil {
+		return nil, err
+	}
+	for _, question := range questions {
+		record := []string{
+			question.Question,
+			question.Creator,
+			question.Category,
+			strings.Join(question.Tags, " "),
+			question.TeamID,
+			question.ChannelID,
+			strconv.Itoa(question.RecentlyAsked),
+		}
+		if err := writer.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	writer.Flush()
+	return buffer.Bytes(), writer.Error()
+}
+
+func decodeQuestionsCSV(content []byte) ([]ExportedQuestion, error) {
+	records, err := csv.NewReader(bytes.NewReader(content)).ReadAll()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse CSV")
+	}
+	if len(records) == 0 {
+		return []ExportedQuestion{}, nil
+	}
+
+	columns := map[string]int{}
+	for index, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = index
+	}
+	if _, ok := columns["question"]; !ok {
+		return nil, errors.New("the CSV file needs a `question` column")
+	}
+	getColumn := func(record []string, name string) string {
+		index, ok := columns[name]
+		if !ok || index >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[index])
+	}
+
+	questions := []ExportedQuestion{}
+	for _, record := range records[1:] {
+		questions = append(questions, ExportedQuestion{
+			Question:  getColumn(record, "question"),
+			Creator:   getColumn(record, "creator"),
+			Category:  getColumn(record, "category"),
+			Tags:      strings.Fields(getColumn(record, "tags")),
+			TeamID:    getColumn(record, "team_id"),
+			ChannelID: getColumn(record, "channel_id"),
+		})
+	}
+	return questions, nil
+}
+
+func decodeQuestionsJSON(content []byte) ([]ExportedQuestion, error) {
+	questions := []ExportedQuestion{}
+	if err := json.Unmarshal(content, &questions); err != nil {
+		return nil, errors.Wrap(err, "failed to parse JSON")
+	}
+	return questions, nil
+}
+
+func (p *Plugin) executeCommandIcebreakerExport(args *model.CommandArgs) *model.CommandResponse {
+	data, err := p.ReadFromStorage()
+	if err != nil {
+		return p.getStorageErrorResponse(err)
+	}
+	questions := p.exportQuestions(&data)
+
+	jsonContent, err := json.MarshalIndent(questions, "", "  ")
+	if err != nil {
+		return p.getExportErrorResponse(err)
+	}
+	csvContent, err := encodeQuestionsCSV(questions)
+	if err != nil {
+		return p.getExportErrorResponse(err)
+	}
+
+	channel, appErr := p.API.GetDirectChannel(p.botID, args.UserId)
+	if appErr != nil {
+		return p.getExportErrorResponse(appErr)
+	}
+	fileIDs := []string{}
+	for _, file := range []struct {
+		name    string
+		content []byte
+	}{
+		{name: exportFileName + ".json", content: jsonContent},
+		{name: exportFileName + ".csv", content: csvContent},
+	} {
+		fileInfo, appErr := p.API.UploadFile(file.content, channel.Id, file.name)
+		if appErr != nil {
+			return p.getExportErrorResponse(appErr)
+		}
+		fileIDs = append(fileIDs, fileInfo.Id)
+	}
+
+	post := &model.Post{
+		ChannelId: channel.Id,
+		UserId:    p.botID,
+		Message:   fmt.Sprintf("Here are all %d icebreaker questions. Import them again using `/icebreaker admin import <file id>`.", len(questions)),
+		FileIds:   fileIDs,
+	}
+	if _, appErr := p.API.CreatePost(post); appErr != nil {
+		return p.getExportErrorResponse(appErr)
+	}
+
+	return &model.CommandResponse{
+		ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+		Text:         fmt.Sprintf("Exported %d questions, I sent you the files as a direct message.", len(questions)),
+	}
+}
+
+func (p *Plugin) getExportErrorResponse(err error) *model.CommandResponse {
+	p.API.LogError("Failed to export icebreaker questions", "err", err.Error())
+	return &model.CommandResponse{
+		ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+		Text:         "Error: Failed to export the questions, please try again",
+	}
+}
+
+func (p *Plugin) executeCommandIcebreakerImport(args *model.CommandArgs) *model.CommandResponse {
+	givenFile := strings.TrimPrefix(args.Command, fmt.Sprintf("/%s", commandIcebreakerImport))
+	fields := strings.Fields(givenFile)
+	dryRun := false
+	fileID := ""
+	for _, field := range fields {
+		if field == "--dry-run" {
+			dryRun = true
+			continue
+		}
+		fileID = field
+	}
+	if fileID == "" {
+		return &model.CommandResponse{
+			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+			Text:         "Error: Please give the ID of the uploaded file or of the post it is attached to, e.g. `/icebreaker admin import --dry-run <file id>`",
+		}
+	}
+
+	fileName, content, errResponse := p.readImportFile(args.UserId, fileID)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	var imported []ExportedQuestion
+	var err error
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".json":
+		imported, err = decodeQuestionsJSON(content)
+	case ".csv":
+		imported, err = decodeQuestionsCSV(content)
+	default:
+		err = errors.Errorf("unsupported file type of %s, use a .json or .csv file", fileName)
+	}
+	if err != nil {
+		return &model.CommandResponse{
+			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+			Text:         fmt.Sprintf("Error: Cannot import %s: %s", fileName, err.Error()),
+		}
+	}
+
+	//the update might be retried, so the creators are looked up before
+	creators := p.getImportCreators(imported, args.UserId)
+	var result importResult
+	err = p.updateDataWithBackup(subcommandImport, args.UserId, func(data *IceBreakerData) error {
+		result = p.importQuestions(data, imported, creators)
+		if dryRun {
+			return errSkipUpdate
+		}
+		return nil
+	})
+	if err != nil {
+		return p.getStorageErrorResponse(err)
+	}
+
+	message := fmt.Sprintf("Imported %s: %d rows, %d added, %d duplicates skipped, %d invalid rows skipped.", fileName, result.Rows, result.Added, result.Duplicates, len(result.Invalid))
+	if dryRun {
+		message = fmt.Sprintf("Dry run of importing %s: %d rows, %d would be added, %d duplicates would be skipped, %d invalid rows would be skipped.", fileName, result.Rows, result.Added, result.Duplicates, len(result.Invalid))
+	}
+	for index, invalid := range result.Invalid {
+		if index >= maxImportErrorsShown {
+			message = message + fmt.Sprintf("\n* ... and %d more", len(result.Invalid)-maxImportErrorsShown)
+			break
+		}
+		message = message + "\n* " + invalid
+	}
+	return &model.CommandResponse{
+		ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+		Text:         message,
+	}
+}
+
+// readImportFile returns the name and content of the file with the given ID. The ID of a post is accepted as well,
+// its first attached file is used then. The user needs to be able to read the channel the file has been posted in
+func (p *Plugin) readImportFile(userID string, id string) (string, []byte, *model.CommandResponse) {
+	fileInfo, appErr := p.API.GetFileInfo(id)
+	if appErr != nil {
+		post, postErr := p.API.GetPost(id)
+		if postErr != nil || len(post.FileIds) == 0 {
+			return "", nil, &model.CommandResponse{
+				ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+				Text:         fmt.Sprintf("Error: Cannot find a file or a post with a file attachment with the ID %s", id),
+			}
+		}
+		if fileInfo, appErr = p.API.GetFileInfo(post.FileIds[0]); appErr != nil {
+			return "", nil, &model.CommandResponse{
+				ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+				Text:         fmt.Sprintf("Error: Cannot read the file attached to the post %s", id),
+			}
+		}
+	}
+
+	post, appErr := p.API.GetPost(fileInfo.PostId)
+	if appErr != nil || !p.API.HasPermissionToChannel(userID, post.ChannelId, model.PERMISSION_READ_CHANNEL) {
+		return "", nil, &model.CommandResponse{
+			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+			Text:         "Error: You can only import files that have been posted in a channel you can read",
+		}
+	}
+
+	content, appErr := p.API.GetFile(fileInfo.Id)
+	if appErr != nil {
+		p.API.LogError("Failed to read import file", "file", fileInfo.Id, "err", appErr.Error())
+		return "", nil, &model.CommandResponse{
+			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+			Text:         "Error: Failed to read the file, please try again",
+		}
+	}
+	return fileInfo.Name, content, nil
+}
+
+// getImportCreators maps the creators of the imported questions to user IDs. The original creators are kept
+// if they exist on this server, all other questions are credited to the importing user
+func (p *Plugin) getImportCreators(imported []ExportedQuestion, importingUserID string) map[string]string {
+	creators := map[string]string{}
+	for _, row := range imported {
+		if _, ok := creators[row.Creator]; ok {
+			continue
+		}
+		creators[row.Creator] = importingUserID
+		if row.Creator != "" {
+			if user, appErr := p.API.GetUserByUsername(strings.TrimPrefix(row.Creator, "@")); appErr == nil {
+				creators[row.Creator] = user.Id
+			}
+		}
+	}
+	return creators
+}
+
+// importQuestions adds the imported questions to the data, skipping duplicates and invalid rows.
+// The creators map the creators of the rows to user IDs, see getImportCreators
+func (p *Plugin) importQuestions(data *IceBreakerData, imported []ExportedQuestion, creators map[string]string) importResult {
+	config := p.getConfiguration()
+	result := importResult{Rows: len(imported)}
+	for index, row := range imported {
+		rowNumber := index + 1
+		question := strings.TrimSpace(row.Question)
+		if question == "" {
+			result.Invalid = append(result.Invalid, fmt.Sprintf("Row %d: The question is empty", rowNumber))
+			continue
+		}
+		if len(question) > config.getMaxQuestionLength() {
+			result.Invalid = append(result.Invalid, fmt.Sprintf("Row %d: The question is longer than %d characters", rowNumber, config.getMaxQuestionLength()))
+			continue
+		}
+		if row.ChannelID != "" && row.TeamID == "" {
+			result.Invalid = append(result.Invalid, fmt.Sprintf("Row %d: Questions of a channel need a team", rowNumber))
+			continue
+		}
+
+		newQuestion := Question{
+			Question:  question,
+			Category:  normalizeLabel(row.Category),
+			TeamID:    row.TeamID,
+			ChannelID: row.ChannelID,
+		}
+		if newQuestion.Category == "" {
+			newQuestion.Category = defaultCategory
+		}
+		for _, tag := range row.Tags {
+			newQuestion.Tags = append(newQuestion.Tags, normalizeLabel(tag))
+		}
+		if containsQuestion(data.Questions, &newQuestion) {
+			result.Duplicates++
+			continue
+		}
+		if len(data.Questions) > config.getMaxQuestions() {
+			result.Invalid = append(result.Invalid, fmt.Sprintf("Row %d: There are already more than %d questions", rowNumber, config.getMaxQuestions()))
+			continue
+		}
+
+		newQuestion.Creator = creators[row.Creator]
+		newQuestion.ID = newQuestionID(data)
+
+		data.Questions = append(data.Questions, newQuestion)
+		result.Added++
+	}
+	return result
+}
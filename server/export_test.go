@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/mattermost/mattermost-server/v5/plugin/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuestionsCSV(t *testing.T) {
+	questions := []ExportedQuestion{
+		ExportedQuestion{Question: "How do you do?", Creator: "test_user", Category: "mild", Tags: []string{"food", "fun"}, RecentlyAsked: 2},
+		ExportedQuestion{Question: "Commas, \"quotes\"\nand lines?", Creator: "test_user", TeamID: "TestTeam", ChannelID: "TestChannel"},
+	}
+
+	content, err := encodeQuestionsCSV(questions)
+	require.NoError(t, err)
+	assert.Equal(t, "question,creator,category,tags,team_id,channel_id,recently_asked\n"+
+		"How do you do?,test_user,mild,food fun,,,2\n"+
+		"\"Commas, \"\"quotes\"\"\nand lines?\",test_user,,,TestTeam,TestChannel,0\n", string(content))
+
+	decoded, err := decodeQuestionsCSV(content)
+	require.NoError(t, err)
+	questions[0].RecentlyAsked = 0 //stats are not imported
+	questions[1].Tags = []string{}
+	assert.Equal(t, questions, decoded)
+
+	decoded, err = decodeQuestionsCSV([]byte("Category,Question\nwork,What do you do?\n"))
+	require.NoError(t, err)
+	assert.Equal(t, []ExportedQuestion{ExportedQuestion{Question: "What do you do?", Category: "work", Tags: []string{}}}, decoded)
+
+	_, err = decodeQuestionsCSV([]byte("category\nwork\n"))
+	assert.Error(t, err)
+}
+
+func TestExportCommand(t *testing.T) {
+	icebreakerData := IceBreakerData{
+		Questions: []Question{
+			Question{Creator: "TestUser", Question: "How do you do?", Category: "mild"},
+		},
+		LastQuestions: []Question{
+			Question{Creator: "TestUser", Question: "How do you do?", Category: "mild"},
+		},
+	}
+	dataBytes, err := json.Marshal(icebreakerData)
+	require.NoError(t, err)
+
+	api, _ := newFakeKVStore(map[string][]byte{KVKEY: dataBytes})
+	api.On("GetUser", "TestUser").Return(&model.User{Id: "TestUser", Username: "test_user", Roles: model.SYSTEM_ADMIN_ROLE_ID}, nil)
+	api.On("GetDirectChannel", "BotUser", "TestUser").Return(&model.Channel{Id: "DirectChannel"}, nil)
+	api.On("UploadFile", mock.AnythingOfType("[]uint8"), "DirectChannel", "icebreaker-questions.json").Return(&model.FileInfo{Id: "JSONFile"}, nil).Run(func(args mock.Arguments) {
+		exported := []ExportedQuestion{}
+		require.NoError(t, json.Unmarshal(args.Get(0).([]byte), &exported))
+		assert.Equal(t, []ExportedQuestion{ExportedQuestion{Question: "How do you do?", Creator: "test_user", Category: "mild", RecentlyAsked: 1}}, exported)
+	})
+	api.On("UploadFile", []byte("question,creator,category,tags,team_id,channel_id,recently_asked\nHow do you do?,test_user,mild,,,,1\n"), "DirectChannel", "icebreaker-questions.csv").Return(&model.FileInfo{Id: "CSVFile"}, nil)
+	api.On("CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+		return post.ChannelId == "DirectChannel" && assert.ObjectsAreEqual(model.StringArray{"JSONFile", "CSVFile"}, post.FileIds)
+	})).Return(nil, nil)
+	plugin := &Plugin{botID: "BotUser"}
+	plugin.SetAPI(api)
+
+	response, _ := plugin.ExecuteCommand(nil, &model.CommandArgs{Command: "/icebreaker admin export", UserId: "TestUser"})
+	assert.Equal(t, "Exported 1 questions, I sent you the files as a direct message.", response.Text)
+	api.AssertNumberOfCalls(t, "UploadFile", 2)
+	api.AssertNumberOfCalls(t, "CreatePost", 1)
+}
+
+func TestImportCommand(t *testing.T) {
+	setup := func(t *testing.T, fileName string, content string) (*Plugin, *plugintest.API) {
+		icebreakerData := IceBreakerData{
+			Questions: []Question{Question{ID: "q1", Creator: "TestUser", Question: "How do you do?"}},
+		}
+		dataBytes, err := json.Marshal(icebreakerData)
+		require.NoError(t, err)
+
+		api, _ := newFakeKVStore(map[string][]byte{KVKEY: dataBytes})
+		api.On("GetUser", "TestUser").Return(&model.User{Id: "TestUser", Username: "test_user", Roles: model.SYSTEM_ADMIN_ROLE_ID}, nil)
+		api.On("GetFileInfo", "ImportFile").Return(&model.FileInfo{Id: "ImportFile", PostId: "ImportPost", Name: fileName}, nil)
+		api.On("GetFileInfo", "ImportPost").Return(nil, &model.AppError{})
+		api.On("GetPost", "ImportPost").Return(&model.Post{Id: "ImportPost", ChannelId: "ImportChannel", FileIds: model.StringArray{"ImportFile"}}, nil)
+		api.On("HasPermissionToChannel", "TestUser", "ImportChannel", model.PERMISSION_READ_CHANNEL).Return(true)
+		api.On("GetFile", "ImportFile").Return([]byte(content), nil)
+		api.On("GetUserByUsername", "other_user").Return(&model.User{Id: "OtherUser"}, nil)
+		api.On("GetUserByUsername", mock.AnythingOfType("string")).Return(nil, &model.AppError{})
+		plugin := &Plugin{}
+		plugin.SetAPI(api)
+		return plugin, api
+	}
+	csvContent := "question,creator,category,tags\n" +
+		"How do you do?,test_user,,\n" +
+		"What do you do?,other_user,Work,#job\n" +
+		",test_user,,\n" +
+		"Where are you from?,unknown_user,,\n"
+
+	t.Run("Dry run", func(t *testing.T) {
+		plugin, _ := setup(t, "questions.csv", csvContent)
+
+		response, _ := plugin.ExecuteCommand(nil, &model.CommandArgs{Command: "/icebreaker admin import --dry-run ImportFile", UserId: "TestUser"})
+		assert.Equal(t, "Dry run of importing questions.csv: 4 rows, 2 would be added, 1 duplicates would be skipped, 1 invalid rows would be skipped.\n* Row 3: The question is empty", response.Text)
+		assert.Len(t, readData(t, plugin).Questions, 1)
+	})
+	t.Run("Import CSV by post ID", func(t *testing.T) {
+		plugin, _ := setup(t, "questions.csv", csvContent)
+
+		response, _ := plugin.ExecuteCommand(nil, &model.CommandArgs{Command: "/icebreaker admin import ImportPost", UserId: "TestUser"})
+		assert.Equal(t, "Imported questions.csv: 4 rows, 2 added, 1 duplicates skipped, 1 invalid rows skipped.\n* Row 3: The question is empty", response.Text)
+		questions := readData(t, plugin).Questions
+		assert.Equal(t, []Question{
+			Question{ID: "q1", Creator: "TestUser", Question: "How do you do?"},
+			Question{Creator: "OtherUser", Question: "What do you do?", Category: "work", Tags: []string{"job"}},
+			Question{Creator: "TestUser", Question: "Where are you from?", Category: defaultCategory},
+		}, append(questions[:1], clearQuestionIDs(t, questions[1:])...))
+	})
+	t.Run("Import JSON", func(t *testing.T) {
+		plugin, _ := setup(t, "questions.json", `[{"question": "What do you do?", "creator": "other_user", "team_id": "TestTeam"}]`)
+
+		response, _ := plugin.ExecuteCommand(nil, &model.CommandArgs{Command: "/icebreaker admin import ImportFile", UserId: "TestUser"})
+		assert.Equal(t, "Imported questions.json: 1 rows, 1 added, 0 duplicates skipped, 0 invalid rows skipped.", response.Text)
+		assert.Equal(t, []Question{Question{Creator: "OtherUser", Question: "What do you do?", TeamID: "TestTeam", Category: defaultCategory}}, clearQuestionIDs(t, readData(t, plugin).Questions[1:]))
+	})
+	t.Run("Unsupported file", func(t *testing.T) {
+		plugin, _ := setup(t, "questions.txt", "How do you do?")
+
+		response, _ := plugin.ExecuteCommand(nil, &model.CommandArgs{Command: "/icebreaker admin import ImportFile", UserId: "TestUser"})
+		assert.Equal(t, "Error: Cannot import questions.txt: unsupported file type of questions.txt, use a .json or .csv file", response.Text)
+	})
+}
@@ -2,144 +2,612 @@ package main
 
 import (
 	"fmt"
-	"math"
-	"strconv"
 	"strings"
+	"time"
+	"unicode"
 
 	"github.com/mattermost/mattermost-server/v5/model"
-	"github.com/mroth/weightedrand"
+	"github.com/pkg/errors"
 )
 
+var (
+	errNoQuestions = errors.New("there are no questions to ask")
+	errNoUser      = errors.New("there is no user to ask a question for")
+
+	errNoMatchingQuestions = errors.New("there are no questions matching the filter")
+	errCreatePost          = errors.New("failed to create post")
+	errGetUsers            = errors.New("failed to get the users of the channel")
+)
+
+// getUsersErrorID identifies the error returned by GetRandomUser if the users of the channel cannot be read
+const getUsersErrorID = "icebreaker.get_users.app_error"
+
+// defaultCategory is the category of questions that have been added without a category
+const defaultCategory = "uncategorized"
+
+// questionIDLength is the length of the question IDs, short enough to be typed in commands
+const questionIDLength = 6
+
+// questionFilter limits the questions to a category or a tag. An empty filter matches all questions
+type questionFilter struct {
+	Category string
+	Tag      string
+}
+
+// parseQuestionFilter reads a filter like `work` (category) or `#food` (tag) from the given text
+func parseQuestionFilter(text string) questionFilter {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return questionFilter{}
+	}
+	if strings.HasPrefix(fields[0], "#") {
+		return questionFilter{Tag: normalizeLabel(fields[0])}
+	}
+	return questionFilter{Category: normalizeLabel(fields[0])}
+}
+
+// matches returns true if the question is part of the filtered category and carries the filtered tag
+func (f questionFilter) matches(question *Question) bool {
+	if f.Category != "" && question.getCategory() != f.Category {
+		return false
+	}
+	if f.Tag != "" {
+		for _, tag := range question.Tags {
+			if tag == f.Tag {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// String returns the filter as entered by the user
+func (f questionFilter) String() string {
+	if f.Tag != "" {
+		return "#" + f.Tag
+	}
+	return f.Category
+}
+
+// normalizeLabel makes sure categories and tags are compared case-insensitive and without the leading `#`
+func normalizeLabel(label string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(label), "#"))
+}
+
 // GetRandomUser returns a random user that is found in the given channel and that is not a bot
-// This function is limited to 1000 users per channel
+// This function is limited to the configured number of users per channel
 func (p *Plugin) GetRandomUser(channelID string, userIDToIgnore string) (*model.User, *model.AppError) {
+	config := p.getConfiguration()
+
 	//get a random user that is not a bot
-	users, _ := p.API.GetUsersInChannel(channelID, "username", 0, 1000)
-	weightedUsers := []weightedrand.Choice{} //list of users, sorted by weight
+	users, appErr := p.API.GetUsersInChannel(channelID, "username", 0, config.getMaxChannelUsers())
+	if appErr != nil {
+		return nil, &model.AppError{
+			Id:      getUsersErrorID,
+			Message: "Failed to get the users of the channel: " + appErr.Error(),
+		}
+	}
 
-	//read the users data for weightedrandom
-	data := p.ReadFromStorage()
+	data, readErr := p.ReadFromStorage()
+	if readErr != nil {
+		return nil, &model.AppError{
+			Message: "Failed to read the icebreaker data: " + readErr.Error(),
+		}
+	}
 
+	candidates := []interface{}{}
+	keys := []string{}
 	for _, user := range users {
-		if user.IsBot {
+		if user.Id == userIDToIgnore {
 			continue
 		}
-		if user.Id == userIDToIgnore {
+		if p.getUnaskableReason(user, channelID, &data, config) != "" {
 			continue
 		}
-		status, err := p.API.GetUserStatus(user.Id)
-		if (err != nil) || (status.Status == "offline") || (status.Status == "dnd") {
+		candidates = append(candidates, user)
+		keys = append(keys, user.Id)
+	}
+
+	strategyName := data.getStrategyName(channelID, config)
+	history := getStrategyHistory(strategyName, data.LastUsers, data.UserRotations[channelID])
+	if picked, ok := pickWeighted(p.getRandom(), candidates, getSelectionStrategy(strategyName, config).getWeights(keys, history)); ok {
+		return picked.(*model.User), nil
+	}
+
+	return nil, &model.AppError{
+		Message: "There is no user I can ask a question for...",
+	}
+}
+
+// getUnaskableReason returns why the user cannot be asked a question in the given channel,
+// or an empty string if the user can be asked
+func (p *Plugin) getUnaskableReason(user *model.User, channelID string, data *IceBreakerData, config *configuration) string {
+	if user.IsBot {
+		return fmt.Sprintf("@%s is a bot", user.Username)
+	}
+	if !data.isUserAskable(user.Id, channelID) {
+		return fmt.Sprintf("@%s does not want to be asked icebreaker questions in this channel", user.Username)
+	}
+	if data.usesWorkingHours(channelID, config) {
+		if window, err := config.getWorkingWindow(); err == nil && !window.isWithinWorkingHours(user, time.Now()) {
+			return fmt.Sprintf("@%s is outside of their working hours right now", user.Username)
+		}
+	}
+	status, err := p.API.GetUserStatus(user.Id)
+	if err != nil {
+		return fmt.Sprintf("The status of @%s is unknown", user.Username)
+	}
+	if config.isSkippedStatus(status.Status) {
+		return fmt.Sprintf("@%s is %s right now", user.Username, status.Status)
+	}
+	return ""
+}
+
+// GetRandomQuestion returns a random question that hasn't been asked recently.
+// The question is drawn from the union of the global, team and channel pools that apply to the given channel
+func (p *Plugin) GetRandomQuestion(teamID string, channelID string, filter questionFilter) (*Question, *model.AppError) {
+	config := p.getConfiguration()
+
+	data, readErr := p.ReadFromStorage()
+	if readErr != nil {
+		return nil, &model.AppError{
+			Message: "Failed to read the icebreaker data: " + readErr.Error(),
+		}
+	}
+
+	candidates := []interface{}{}
+	keys := []string{}
+	for _, question := range data.Questions {
+		if !question.appliesTo(teamID, channelID) || !filter.matches(&question) {
 			continue
 		}
+		candidates = append(candidates, question)
+		keys = append(keys, question.getHistoryKey())
+	}
 
-		//check if the user has already been asked lately. Add him with a weight according to how recent the asking has been
-		//by iterating in reverse we make sure that users that appear multiple times in the list will not mess up the weights
-		isNewUser := true
-		if data.LastUsers != nil {
-			for index := len(data.LastUsers) - 1; index >= 0; index-- {
-				currentUserID := data.LastUsers[index]
-				if currentUserID == user.Id {
-					userWeight := uint(math.Abs(float64(index - len(data.LastUsers))))
-					weightedUsers = append(weightedUsers, weightedrand.Choice{Weight: userWeight, Item: user})
-					isNewUser = false
-					break
-				}
-			}
-			if !isNewUser { //if the user has been found within our data.LastUsers we can continue the loop
-				continue
+	//questions asked before the IDs were introduced are recognized by their text, see isSameAs
+	history := make([]string, len(data.LastQuestions))
+	for index := range data.LastQuestions {
+		history[index] = data.LastQuestions[index].getHistoryKey()
+		for _, candidate := range candidates {
+			question := candidate.(Question)
+			if question.isSameAs(&data.LastQuestions[index]) {
+				history[index] = question.getHistoryKey()
+				break
 			}
 		}
-
-		//Finally... this is a brand-new user that has never asked a question. Add him with a very high weight, so he'll be chosen with a high possibility
-		weightedUsers = append(weightedUsers, weightedrand.Choice{Weight: 1000, Item: user})
 	}
 
-	if len(weightedUsers) > 0 {
-		chooser := weightedrand.NewChooser(weightedUsers...)
-		user, ok := chooser.Pick().(*model.User)
-		if ok {
-			return user, nil
+	strategyName := data.getStrategyName(channelID, config)
+	history = getStrategyHistory(strategyName, history, data.QuestionRotations[channelID])
+	weights := getSelectionStrategy(strategyName, config).getWeights(keys, history)
+	if strategyName == strategyWeighted {
+		//votes only fine-tune the weights, questions can still be chosen if they cannot be read
+		allStats, _ := p.getQuestionStats()
+		for index, candidate := range candidates {
+			weights[index] = applyVotes(weights[index], allStats[candidate.(Question).ID])
 		}
 	}
 
+	if picked, ok := pickWeighted(p.getRandom(), candidates, weights); ok {
+		question := picked.(Question)
+		return &question, nil
+	}
+
 	return nil, &model.AppError{
-		Message: "There is no user I can ask a question for...",
+		Message: "There is no question to ask...",
 	}
 }
 
-// GetRandomQuestion returns a random question that hasn't been asked recently
-func (p *Plugin) GetRandomQuestion() (*Question, *model.AppError) {
-	weightedQuestions := []weightedrand.Choice{} //list of questions, sorted by weight
+// askIcebreaker picks a random user and question and posts the icebreaker to the given channel.
+// It is used by the `/icebreaker` command as well as by the scheduled icebreakers.
+func (p *Plugin) askIcebreaker(teamID string, channelID string, rootID string, userIDToIgnore string, filter questionFilter) error {
+	data, readErr := p.ReadFromStorage()
+	if readErr != nil {
+		return readErr
+	}
 
-	//read the question data for weightedrandom
-	data := p.ReadFromStorage()
+	if err := checkQuestionsAvailable(&data, teamID, channelID, filter); err != nil {
+		return err
+	}
 
-	for _, question := range data.Questions {
-		//check if the question has already been asked lately. Add it with a weight according to how recent the question has been asked
-		//by iterating in reverse we make sure that questions that appear multiple times in the list will not mess up the weights
-		isNewQuestion := true
-		if data.LastQuestions != nil {
-			for index := len(data.LastQuestions) - 1; index >= 0; index-- {
-				currentQuestion := data.LastQuestions[index]
-				if currentQuestion.Question == question.Question {
-					questionWeight := uint(math.Abs(float64(index - len(data.LastQuestions))))
-					weightedQuestions = append(weightedQuestions, weightedrand.Choice{Weight: questionWeight, Item: currentQuestion})
-					isNewQuestion = false
-					break
-				}
-			}
-			if !isNewQuestion { //if the question has been found within our data.LastQuestions we can continue the loop
-				continue
-			}
+	//get a random user that is not a bot
+	user, err := p.GetRandomUser(channelID, userIDToIgnore)
+	if err != nil {
+		if err.Id == getUsersErrorID {
+			return errors.Wrap(errGetUsers, err.Message)
 		}
+		return errNoUser
+	}
 
-		//Finally... this is a brand-new question that has never been asked. Add it with a very high weight, so it'll be chosen with a high possibility
-		weightedQuestions = append(weightedQuestions, weightedrand.Choice{Weight: 1000, Item: question})
+	//build the question and ask it
+	question, err := p.GetRandomQuestion(teamID, channelID, filter)
+	if err != nil {
+		return errNoQuestions
 	}
 
-	if len(weightedQuestions) > 0 {
-		chooser := weightedrand.NewChooser(weightedQuestions...)
-		question, ok := chooser.Pick().(Question)
-		if ok {
-			return &question, nil
+	return p.postIcebreaker(teamID, channelID, rootID, user, question, filter)
+}
+
+// askUsers asks all given users the same random question. Users that cannot be asked are skipped,
+// the reasons are returned to let the user who triggered the command know about them
+func (p *Plugin) askUsers(teamID string, channelID string, rootID string, usernames []string, filter questionFilter) ([]string, error) {
+	data, readErr := p.ReadFromStorage()
+	if readErr != nil {
+		return nil, readErr
+	}
+	if err := checkQuestionsAvailable(&data, teamID, channelID, filter); err != nil {
+		return nil, err
+	}
+
+	config := p.getConfiguration()
+	users := []*model.User{}
+	skipped := []string{}
+	asked := map[string]bool{}
+	for _, username := range usernames {
+		user, appErr := p.API.GetUserByUsername(username)
+		if appErr != nil {
+			skipped = append(skipped, fmt.Sprintf("Cannot find user @%s", username))
+			continue
 		}
+		if asked[user.Id] {
+			continue
+		}
+		if _, appErr := p.API.GetChannelMember(channelID, user.Id); appErr != nil {
+			skipped = append(skipped, fmt.Sprintf("@%s is not a member of this channel", user.Username))
+			continue
+		}
+		if reason := p.getUnaskableReason(user, channelID, &data, config); reason != "" {
+			skipped = append(skipped, reason)
+			continue
+		}
+		asked[user.Id] = true
+		users = append(users, user)
+	}
+	if len(users) == 0 {
+		return skipped, errNoUser
 	}
 
-	return nil, &model.AppError{
-		Message: "There is no question to ask...",
+	question, err := p.GetRandomQuestion(teamID, channelID, filter)
+	if err != nil {
+		return skipped, errNoQuestions
+	}
+	for _, user := range users {
+		if err := p.postIcebreaker(teamID, channelID, rootID, user, question, filter); err != nil {
+			return skipped, err
+		}
 	}
+	return skipped, nil
 }
 
-func requireAdminUser(sourceUser *model.User) *model.CommandResponse {
-	if !sourceUser.IsSystemAdmin() { //TODO: Check for Channel owner instead of System Admin
-		return &model.CommandResponse{
-			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
-			Text:         "Error: You need to be admin in order to clear all proposed questions",
+// checkQuestionsAvailable returns an error if there are no questions for the channel that match the filter
+func checkQuestionsAvailable(data *IceBreakerData, teamID string, channelID string, filter questionFilter) error {
+	questions := getQuestionsForChannel(data.Questions, teamID, channelID)
+	if len(questions) == 0 {
+		return errNoQuestions
+	}
+	if !hasMatchingQuestion(questions, filter) {
+		return errNoMatchingQuestions
+	}
+	return nil
+}
+
+// postIcebreaker asks the given user the given question in the given channel.
+// The filter is used when someone asks for another question using the buttons of the post
+func (p *Plugin) postIcebreaker(teamID string, channelID string, rootID string, user *model.User, question *Question, filter questionFilter) error {
+	message := fmt.Sprintf("Hey @%s! %s", user.GetDisplayName(""), question.Question)
+	post := &model.Post{
+		ChannelId: channelID,
+		RootId:    rootID,
+		UserId:    p.botID,
+		Message:   message,
+	}
+	post.AddProp(askedUserProp, user.Id)
+	if question.ID != "" {
+		post.AddProp(questionIDProp, question.ID)
+	}
+	model.ParseSlackAttachment(post, []*model.SlackAttachment{
+		&model.SlackAttachment{
+			Actions: getIcebreakerActions(&icebreakerContext{
+				TeamID:     teamID,
+				ChannelID:  channelID,
+				UserID:     user.Id,
+				QuestionID: question.ID,
+				Question:   question.Question,
+				Category:   filter.Category,
+				Tag:        filter.Tag,
+			}),
+		},
+	})
+
+	createdPost, err := p.API.CreatePost(post)
+	if err != nil {
+		p.API.LogError("Error: Failed to create post", "err", err.Error())
+		return errCreatePost
+	}
+
+	//store the user and question so we avoid asking the same users and same questions over and over.
+	//This happens only once the post has been created, a failed post must not count as asked
+	config := p.getConfiguration()
+	historyLength := config.getHistoryLength()
+	updateErr := p.updateData(func(data *IceBreakerData) error {
+		//remove the oldest elements, all of them beyond the history length in case it has been lowered
+		data.LastUsers = append(data.LastUsers, user.Id)
+		if len(data.LastUsers) > historyLength {
+			data.LastUsers = data.LastUsers[len(data.LastUsers)-historyLength:]
+		}
+		data.LastQuestions = append(data.LastQuestions, *question)
+		if len(data.LastQuestions) > historyLength {
+			data.LastQuestions = data.LastQuestions[len(data.LastQuestions)-historyLength:]
+		}
+		if usesRotation(data.getStrategyName(channelID, config)) {
+			data.UserRotations = addToRotation(data.UserRotations, channelID, user.Id)
+			data.QuestionRotations = addToRotation(data.QuestionRotations, channelID, question.getHistoryKey())
 		}
+		return nil
+	})
+	if updateErr != nil {
+		p.API.LogError("Failed to store the icebreaker history", "err", updateErr.Error())
 	}
+
+	p.countQuestionAsked(question.ID)
+
+	//remember the question so the reply of the user can be recorded as answer
+	if createdPost != nil {
+		if err := p.trackPendingAnswer(user.Id, createdPost, question); err != nil {
+			p.API.LogError("Failed to track icebreaker answer", "user", user.Id, "err", err.Error())
+		}
+	}
+
 	return nil
 }
 
-func getIndex(command string, givenArray []Question) (int, *model.CommandResponse) {
-	commandFields := strings.Fields(command)
+// appliesTo returns true if the question is part of a pool that applies to the given channel
+func (q *Question) appliesTo(teamID string, channelID string) bool {
+	if q.ChannelID != "" {
+		return q.ChannelID == channelID
+	}
+	if q.TeamID != "" {
+		return q.TeamID == teamID
+	}
+	return true
+}
 
-	for _, field := range commandFields {
-		index, err := strconv.Atoi(field)
-		if err != nil {
-			//the word we got is not a valid index, but perhaps the next fits...
-			continue
+// getScope returns the name of the pool the question belongs to
+func (q *Question) getScope() string {
+	if q.ChannelID != "" {
+		return scopeChannel
+	}
+	if q.TeamID != "" {
+		return scopeTeam
+	}
+	return scopeGlobal
+}
+
+// getCategory returns the category of the question, questions without a category are part of the default category
+func (q *Question) getCategory() string {
+	if q.Category == "" {
+		return defaultCategory
+	}
+	return q.Category
+}
+
+// getLabels returns the scope, category and tags of the question for displaying them
+func (q *Question) getLabels() string {
+	labels := []string{}
+	if q.getScope() != scopeGlobal {
+		labels = append(labels, q.getScope())
+	}
+	if q.getCategory() != defaultCategory {
+		labels = append(labels, q.getCategory())
+	}
+	for _, tag := range q.Tags {
+		labels = append(labels, "#"+tag)
+	}
+	if len(labels) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (%s)", strings.Join(labels, ", "))
+}
+
+// getHistoryKey identifies the question among the recently asked ones, by its ID or by its text if it has none
+func (q *Question) getHistoryKey() string {
+	if q.ID != "" {
+		return q.ID
+	}
+	return q.Question
+}
+
+// isSameAs returns true if both are the same question. Questions are compared by their ID,
+// the text is only compared if one of them has been stored before IDs have been introduced
+func (q *Question) isSameAs(other *Question) bool {
+	if q.ID != "" && other.ID != "" {
+		return q.ID == other.ID
+	}
+	return q.Question == other.Question
+}
+
+// containsQuestion returns true if the question is already part of the same pool
+func containsQuestion(questions []Question, newQuestion *Question) bool {
+	for _, question := range questions {
+		if question.Question == newQuestion.Question && question.TeamID == newQuestion.TeamID && question.ChannelID == newQuestion.ChannelID {
+			return true
 		}
-		if (len(givenArray) <= index) || (index < 0) {
-			return -1, &model.CommandResponse{
-				ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
-				Text:         fmt.Sprintf("Error: Your given index of %d is not valid", index),
+	}
+	return false
+}
+
+// hasMatchingQuestion returns true if at least one of the questions matches the filter
+func hasMatchingQuestion(questions []Question, filter questionFilter) bool {
+	for index := range questions {
+		if filter.matches(&questions[index]) {
+			return true
+		}
+	}
+	return false
+}
+
+// getQuestionsForChannel returns all questions of the pools that apply to the given channel
+func getQuestionsForChannel(questions []Question, teamID string, channelID string) []Question {
+	result := []Question{}
+	for _, question := range questions {
+		if question.appliesTo(teamID, channelID) {
+			result = append(result, question)
+		}
+	}
+	return result
+}
+
+// extractFlags removes all occurrences of `--name value` in front of the text of the given command and returns their values
+func extractFlags(command string, name string) (string, []string) {
+	values := []string{}
+	for {
+		var value string
+		var found bool
+		command, value, found = extractFlag(command, name)
+		if !found {
+			return command, values
+		}
+		values = append(values, value)
+	}
+}
+
+// switchNames are the flags that are not followed by a value
+var switchNames = map[string]bool{"mine": true, "pair": true, "dry-run": true}
+
+// commandFlag is a flag in front of the text of a command. Start and end are the byte offsets of the flag,
+// end being the start of the field following its value, so it can be cut out without touching the text
+type commandFlag struct {
+	name  string
+	value string
+	start int
+	end   int
+}
+
+// getLeadingFlags returns the flags in front of the text of the command. Flags are only parsed up to the first
+// field that is neither a flag nor its value, everything after it is text that is kept as it has been typed
+func getLeadingFlags(command string) []commandFlag {
+	offsets := getFieldOffsets(command)
+	flags := []commandFlag{}
+	for index := 0; index < len(offsets); {
+		field := command[offsets[index][0]:offsets[index][1]]
+		if !strings.HasPrefix(field, "--") || len(field) == 2 {
+			break
+		}
+		flag := commandFlag{name: strings.TrimPrefix(field, "--"), start: offsets[index][0]}
+		index++
+		if !switchNames[flag.name] {
+			if index >= len(offsets) {
+				break
 			}
+			flag.value = command[offsets[index][0]:offsets[index][1]]
+			index++
+		}
+		flag.end = len(command)
+		if index < len(offsets) {
+			flag.end = offsets[index][0]
 		}
-		return index, nil
+		flags = append(flags, flag)
 	}
+	return flags
+}
 
-	return -1, &model.CommandResponse{
-		ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
-		Text:         "Error: Please enter a valid index",
+// getFieldOffsets returns the start and end offsets of the whitespace separated fields of the command
+func getFieldOffsets(command string) [][2]int {
+	offsets := [][2]int{}
+	start := -1
+	for index, character := range command {
+		switch {
+		case unicode.IsSpace(character) && start >= 0:
+			offsets = append(offsets, [2]int{start, index})
+			start = -1
+		case !unicode.IsSpace(character) && start < 0:
+			start = index
+		}
+	}
+	if start >= 0 {
+		offsets = append(offsets, [2]int{start, len(command)})
+	}
+	return offsets
+}
+
+// extractFlag removes `--name value` in front of the text of the given command and returns the value of the flag.
+// The last return value is false if the flag is not part of the command.
+func extractFlag(command string, name string) (string, string, bool) {
+	if switchNames[name] {
+		return command, "", false
+	}
+	for _, flag := range getLeadingFlags(command) {
+		if flag.name == name {
+			return command[:flag.start] + command[flag.end:], flag.value, true
+		}
+	}
+	return command, "", false
+}
+
+// extractSwitch removes `--name` in front of the text of the given command. The last return value is false if the switch is not part of the command
+func extractSwitch(command string, name string) (string, bool) {
+	for _, flag := range getLeadingFlags(command) {
+		if flag.name == name && switchNames[name] {
+			return command[:flag.start] + command[flag.end:], true
+		}
+	}
+	return command, false
+}
+
+// getQuestionIndex returns the index of the question whose ID is given to the command,
+// or an error response if the command does not contain the ID of one of the given questions
+func getQuestionIndex(command string, questions []Question) (int, *model.CommandResponse) {
+	commandFields := strings.Fields(command)
+	if len(commandFields) == 0 {
+		return -1, &model.CommandResponse{
+			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+			Text:         "Error: Please enter the ID of a question, as per `/icebreaker list`",
+		}
+	}
+
+	id := strings.ToLower(strings.Trim(commandFields[0], "`"))
+	index := findQuestionByID(questions, id)
+	if index < 0 {
+		return -1, &model.CommandResponse{
+			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+			Text:         fmt.Sprintf("Error: There is no question with the ID %s", id),
+		}
+	}
+	return index, nil
+}
+
+// findQuestionByID returns the index of the question with the given ID, -1 if there is no such question
+func findQuestionByID(questions []Question, id string) int {
+	for index := range questions {
+		if questions[index].ID == id {
+			return index
+		}
+	}
+	return -1
+}
+
+// newQuestionID returns a short random ID that is not used by any of the stored questions yet
+func newQuestionID(data *IceBreakerData) string {
+	for {
+		id := model.NewId()[:questionIDLength]
+		if findQuestionByID(data.Questions, id) < 0 && findQuestionByID(data.PendingQuestions, id) < 0 {
+			return id
+		}
+	}
+}
+
+// assignQuestionIDs gives an ID to all questions that do not have one yet
+func assignQuestionIDs(data *IceBreakerData) {
+	for index := range data.Questions {
+		if data.Questions[index].ID == "" {
+			data.Questions[index].ID = newQuestionID(data)
+		}
+	}
+	for index := range data.PendingQuestions {
+		if data.PendingQuestions[index].ID == "" {
+			data.PendingQuestions[index].ID = newQuestionID(data)
+		}
 	}
 }
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+const (
+	//questionsPerPage is the number of questions shown on a single page of the list and search commands
+	questionsPerPage = 25
+
+	//minFuzzySearchLength is the length from which searched words may contain a typo
+	minFuzzySearchLength = 4
+)
+
+// listOptions describes which questions are shown by the list and search commands
+type listOptions struct {
+	Filter    questionFilter
+	Scope     string
+	CreatorID string
+	Search    string
+	Sort      string
+	Page      int
+
+	//pageCommand is the command without the page number, used to point to the next page
+	pageCommand string
+}
+
+func (o *listOptions) matches(question *Question) bool {
+	if o.Scope != "" && question.getScope() != o.Scope {
+		return false
+	}
+	if o.CreatorID != "" && question.Creator != o.CreatorID {
+		return false
+	}
+	if o.Search != "" && !matchesSearch(question.Question, o.Search) {
+		return false
+	}
+	return o.Filter.matches(question)
+}
+
+// parseListOptions parses the flags shared by the list and search commands and the page number.
+// The page number is the last word of the command. If the command needs a text, e.g. the one to search for,
+// a number is only taken as page if some text remains. The text that remains after removing all flags is returned
+func (p *Plugin) parseListOptions(args *model.CommandArgs, command string, needsText bool) (*listOptions, string, *model.CommandResponse) {
+	options := &listOptions{Page: 1}
+	fields := strings.Fields(strings.TrimPrefix(args.Command, fmt.Sprintf("/%s", command)))
+
+	text, scope, hasScope := extractFlag(strings.Join(fields, " "), "scope")
+	if hasScope && !isValidScope(scope) {
+		return nil, "", &model.CommandResponse{
+			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+			Text:         fmt.Sprintf("Error: Unknown scope '%s', use one of: global, team, channel", scope),
+		}
+	}
+	options.Scope = scope
+
+	text, sortOrder, hasSort := extractFlag(text, "sort")
+	if hasSort && sortOrder != sortPopular {
+		return nil, "", &model.CommandResponse{
+			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+			Text:         fmt.Sprintf("Error: Unknown sort order '%s', use: %s", sortOrder, sortPopular),
+		}
+	}
+	options.Sort = sortOrder
+
+	text, mine := extractSwitch(text, "mine")
+	text, creator, hasCreator := extractFlag(text, "by")
+	switch {
+	case mine && hasCreator:
+		return nil, "", &model.CommandResponse{
+			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+			Text:         "Error: Use either --mine or --by, not both",
+		}
+	case mine:
+		options.CreatorID = args.UserId
+	case hasCreator:
+		username := strings.TrimPrefix(creator, "@")
+		user, appErr := p.API.GetUserByUsername(username)
+		if appErr != nil {
+			return nil, "", &model.CommandResponse{
+				ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+				Text:         fmt.Sprintf("Error: Cannot find user @%s", username),
+			}
+		}
+		options.CreatorID = user.Id
+	}
+
+	//the flags are in front of the text, so the last word of the text is the last word of the command
+	textFields := strings.Fields(text)
+	if len(textFields) > 1 || (len(textFields) == 1 && !needsText) {
+		if page, err := strconv.Atoi(textFields[len(textFields)-1]); err == nil {
+			if page < 1 {
+				return nil, "", &model.CommandResponse{
+					ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+					Text:         "Error: The first page is page 1",
+				}
+			}
+			options.Page = page
+			fields = fields[:len(fields)-1]
+			textFields = textFields[:len(textFields)-1]
+		}
+	}
+	options.pageCommand = strings.TrimSpace(fmt.Sprintf("/%s %s", command, strings.Join(fields, " ")))
+	return options, strings.Join(textFields, " "), nil
+}
+
+func (p *Plugin) executeCommandIcebreakerList(args *model.CommandArgs) *model.CommandResponse {
+	options, givenFilter, errResponse := p.parseListOptions(args, commandIcebreakerList, false)
+	if errResponse != nil {
+		return errResponse
+	}
+	options.Filter = parseQuestionFilter(givenFilter)
+	return p.listQuestions(args, options, "Questions", "There are no questions...")
+}
+
+func (p *Plugin) executeCommandIcebreakerSearch(args *model.CommandArgs) *model.CommandResponse {
+	options, search, errResponse := p.parseListOptions(args, commandIcebreakerSearch, true)
+	if errResponse != nil {
+		return errResponse
+	}
+	if search == "" {
+		return &model.CommandResponse{
+			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+			Text:         "Error: Please enter the text to search for",
+		}
+	}
+	options.Search = search
+	return p.listQuestions(args, options, fmt.Sprintf("Questions matching '%s'", search), fmt.Sprintf("There are no questions matching '%s'...", search))
+}
+
+// listQuestions shows a single page of the questions of the channel that match the given options
+func (p *Plugin) listQuestions(args *model.CommandArgs, options *listOptions, title string, emptyText string) *model.CommandResponse {
+	data, err := p.ReadFromStorage()
+	if err != nil {
+		return p.getStorageErrorResponse(err)
+	}
+
+	questions := []Question{}
+	for _, question := range data.Questions {
+		if question.appliesTo(args.TeamId, args.ChannelId) && options.matches(&question) {
+			questions = append(questions, question)
+		}
+	}
+	if len(questions) == 0 {
+		return &model.CommandResponse{
+			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+			Text:         emptyText,
+		}
+	}
+
+	var allStats map[string]*QuestionStats
+	if options.Sort == sortPopular {
+		if allStats, err = p.getQuestionStats(); err != nil {
+			return p.getStorageErrorResponse(err)
+		}
+		sortByPopularity(questions, allStats)
+	}
+
+	numPages := (len(questions) + questionsPerPage - 1) / questionsPerPage
+	if options.Page > numPages {
+		return &model.CommandResponse{
+			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+			Text:         fmt.Sprintf("Error: There are only %d pages of questions", numPages),
+		}
+	}
+	start := (options.Page - 1) * questionsPerPage
+	end := start + questionsPerPage
+	if end > len(questions) {
+		end = len(questions)
+	}
+	questions = questions[start:end]
+
+	//look up every creator of the page only once
+	creatorIDs := []string{}
+	for _, question := range questions {
+		creatorIDs = append(creatorIDs, question.Creator)
+	}
+	creators := p.getDisplayNames(creatorIDs)
+
+	message := title + ":\n"
+	if numPages > 1 {
+		message = fmt.Sprintf("%s (page %d of %d):\n", title, options.Page, numPages)
+	}
+	for _, question := range questions {
+		message = message + fmt.Sprintf("`%s`\t@%s:\t%s%s", question.ID, creators[question.Creator], question.Question, question.getLabels())
+		if allStats != nil {
+			message = message + fmt.Sprintf("\t(%s)", allStats[question.ID])
+		}
+		message = message + "\n"
+	}
+	if options.Page < numPages {
+		message = message + fmt.Sprintf("Use `%s %d` to see the next page.\n", options.pageCommand, options.Page+1)
+	}
+
+	return &model.CommandResponse{
+		ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+		Username:     "icebreaker",
+		Text:         message,
+	}
+}
+
+// matchesSearch returns true if the question contains the searched text, ignoring the case.
+// Otherwise all searched words need to be part of the question in any order, longer words may contain a single typo
+func matchesSearch(question string, search string) bool {
+	question = strings.ToLower(question)
+	search = strings.ToLower(strings.TrimSpace(search))
+	if strings.Contains(question, search) {
+		return true
+	}
+
+	questionWords := strings.FieldsFunc(question, isWordSeparator)
+	searchWords := strings.FieldsFunc(search, isWordSeparator)
+	if len(searchWords) == 0 {
+		return false
+	}
+	for _, searchWord := range searchWords {
+		if !containsSimilarWord(questionWords, searchWord) {
+			return false
+		}
+	}
+	return true
+}
+
+func isWordSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+}
+
+func containsSimilarWord(words []string, searchWord string) bool {
+	for _, word := range words {
+		if strings.Contains(word, searchWord) {
+			return true
+		}
+		if len([]rune(searchWord)) >= minFuzzySearchLength && isWithinOneEdit(word, searchWord) {
+			return true
+		}
+	}
+	return false
+}
+
+// isWithinOneEdit returns true if one word can be turned into the other by inserting, removing or replacing a single character
+func isWithinOneEdit(a string, b string) bool {
+	shorter, longer := []rune(a), []rune(b)
+	if len(shorter) > len(longer) {
+		shorter, longer = longer, shorter
+	}
+	if len(longer)-len(shorter) > 1 {
+		return false
+	}
+
+	index := 0
+	for index < len(shorter) && shorter[index] == longer[index] {
+		index++
+	}
+	if index == len(shorter) {
+		return true
+	}
+	if len(shorter) == len(longer) {
+		return string(shorter[index+1:]) == string(longer[index+1:])
+	}
+	return string(shorter[index:]) == string(longer[index+1:])
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/mattermost/mattermost-server/v5/plugin/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func setupListTest(t *testing.T, questions []Question) (*Plugin, *plugintest.API) {
+	reqBodyBytes := new(bytes.Buffer)
+	json.NewEncoder(reqBodyBytes).Encode(&IceBreakerData{Questions: questions})
+
+	api, _ := newFakeKVStore(map[string][]byte{KVKEY: reqBodyBytes.Bytes()})
+	api.On("GetUser", "Creator").Return(&model.User{Id: "Creator", Username: "creator"}, nil)
+	api.On("GetUser", "OtherUser").Return(&model.User{Id: "OtherUser", Username: "other_user"}, nil)
+	api.On("GetUserByUsername", "other_user").Return(&model.User{Id: "OtherUser", Username: "other_user"}, nil)
+	api.On("GetUserByUsername", mock.AnythingOfType("string")).Return(nil, &model.AppError{})
+	plugin := &Plugin{}
+	plugin.SetAPI(api)
+	return plugin, api
+}
+
+func TestListCommand_pages(t *testing.T) {
+	questions := []Question{}
+	for i := 0; i < questionsPerPage*2+1; i++ {
+		questions = append(questions, Question{ID: fmt.Sprintf("q%d", i), Creator: "Creator", Question: fmt.Sprintf("Question %d?", i)})
+	}
+	plugin, api := setupListTest(t, questions)
+	execute := func(command string) string {
+		response, _ := plugin.ExecuteCommand(nil, &model.CommandArgs{Command: command, UserId: "Creator"})
+		return response.Text
+	}
+
+	firstPage := execute("/icebreaker list")
+	assert.True(t, strings.HasPrefix(firstPage, "Questions (page 1 of 3):\n`q0`\t@creator:\tQuestion 0?\n"), firstPage)
+	assert.True(t, strings.HasSuffix(firstPage, "\nUse `/icebreaker list 2` to see the next page.\n"), firstPage)
+	assert.Equal(t, questionsPerPage+2, strings.Count(firstPage, "\n"))
+
+	lastPage := execute("/icebreaker list 3")
+	assert.Equal(t, fmt.Sprintf("Questions (page 3 of 3):\n`q%d`\t@creator:\tQuestion %d?\n", questionsPerPage*2, questionsPerPage*2), lastPage)
+
+	//the creator is looked up only once for all questions and pages
+	api.AssertNumberOfCalls(t, "GetUser", 1)
+
+	assert.Equal(t, "Error: There are only 3 pages of questions", execute("/icebreaker list 4"))
+	assert.Equal(t, "Error: The first page is page 1", execute("/icebreaker list 0"))
+}
+
+func TestListCommand_creator(t *testing.T) {
+	plugin, _ := setupListTest(t, []Question{
+		Question{ID: "q1", Creator: "Creator", Question: "How do you do?", Category: "mild"},
+		Question{ID: "q2", Creator: "OtherUser", Question: "What do you do?", Category: "mild"},
+		Question{ID: "q3", Creator: "OtherUser", Question: "Where do you work?", Category: "work"},
+	})
+	execute := func(command string) string {
+		response, _ := plugin.ExecuteCommand(nil, &model.CommandArgs{Command: command, UserId: "Creator"})
+		return response.Text
+	}
+
+	assert.Equal(t, "Questions:\n`q1`\t@creator:\tHow do you do? (mild)\n", execute("/icebreaker list --mine"))
+	assert.Equal(t, "Questions:\n`q2`\t@other_user:\tWhat do you do? (mild)\n", execute("/icebreaker list --by @other_user mild"))
+	assert.Equal(t, "There are no questions...", execute("/icebreaker list --mine work"))
+	assert.Equal(t, "Error: Cannot find user @nobody", execute("/icebreaker list --by @nobody"))
+	assert.Equal(t, "Error: Use either --mine or --by, not both", execute("/icebreaker list --mine --by @other_user"))
+}
+
+func TestSearchCommand(t *testing.T) {
+	plugin, _ := setupListTest(t, []Question{
+		Question{ID: "q1", Creator: "Creator", Question: "What is your favourite color?"},
+		Question{ID: "q2", Creator: "OtherUser", Question: "What did you eat for breakfast?"},
+		Question{ID: "q3", Creator: "OtherUser", Question: "Which color does your car have?"},
+	})
+	execute := func(command string) string {
+		response, _ := plugin.ExecuteCommand(nil, &model.CommandArgs{Command: command, UserId: "Creator"})
+		return response.Text
+	}
+
+	assert.Equal(t, "Questions matching 'COLOR':\n`q1`\t@creator:\tWhat is your favourite color?\n`q3`\t@other_user:\tWhich color does your car have?\n", execute("/icebreaker search COLOR"))
+	assert.Equal(t, "Questions matching 'color':\n`q3`\t@other_user:\tWhich color does your car have?\n", execute("/icebreaker search --by @other_user color"))
+	assert.Equal(t, "Questions matching 'breakfest':\n`q2`\t@other_user:\tWhat did you eat for breakfast?\n", execute("/icebreaker search breakfest"))
+	assert.Equal(t, "There are no questions matching 'holiday'...", execute("/icebreaker search holiday"))
+	assert.Equal(t, "Error: Please enter the text to search for", execute("/icebreaker search --mine"))
+
+	//a number is only the page if there is text to search for
+	assert.Equal(t, "There are no questions matching '10'...", execute("/icebreaker search 10"))
+	assert.Equal(t, "Questions matching 'color':\n`q1`\t@creator:\tWhat is your favourite color?\n`q3`\t@other_user:\tWhich color does your car have?\n", execute("/icebreaker search color 1"))
+}
+
+func TestMatchesSearch(t *testing.T) {
+	for _, test := range []struct {
+		Search   string
+		Expected bool
+	}{
+		{Search: "favourite color", Expected: true},
+		{Search: "FAVOURITE", Expected: true},
+		{Search: "color favourite", Expected: true},
+		{Search: "favorite", Expected: true},
+		{Search: "colour", Expected: true},
+		{Search: "your?", Expected: true},
+		{Search: "favourite food", Expected: false},
+		{Search: "cat", Expected: false},
+		{Search: "colr", Expected: true},
+		{Search: "clr", Expected: false},
+		{Search: "?!", Expected: false},
+	} {
+		assert.Equal(t, test.Expected, matchesSearch("What is your favourite color?", test.Search), test.Search)
+	}
+}
@@ -19,14 +19,202 @@ const manifestStr = `
   "support_url": "https://github.com/monsdar/mattermost-icebreaker-plugin/issues",
   "release_notes_url": "https://github.com/monsdar/mattermost-icebreaker-plugin/releases",
   "version": "2.2.2",
-  "min_server_version": "5.12.0",
+  "min_server_version": "5.30.0",
   "server": {
     "executables": {
-      "linux-amd64": "server/dist/plugin-linux-amd64",
       "darwin-amd64": "server/dist/plugin-darwin-amd64",
+      "linux-amd64": "server/dist/plugin-linux-amd64",
       "windows-amd64": "server/dist/plugin-windows-amd64.exe"
     },
     "executable": ""
+  },
+  "settings_schema": {
+    "header": "",
+    "footer": "",
+    "settings": [
+      {
+        "key": "QuestionsPermission",
+        "display_name": "Manage questions:",
+        "type": "radio",
+        "help_text": "Who is allowed to remove single questions, review pending and flagged ones and list the backups. Clearing, resetting, exporting, importing and restoring all questions is limited to System Admins.",
+        "placeholder": "",
+        "default": "system_admin",
+        "options": [
+          {
+            "display_name": "System Admins",
+            "value": "system_admin"
+          },
+          {
+            "display_name": "Team Admins",
+            "value": "team_admin"
+          },
+          {
+            "display_name": "Channel Admins",
+            "value": "channel_admin"
+          }
+        ]
+      },
+      {
+        "key": "ChannelSettingsPermission",
+        "display_name": "Manage channel settings:",
+        "type": "radio",
+        "help_text": "Who is allowed to change the icebreaker settings of a channel, like its schedules or the opt-in only mode. System Admins are always allowed.",
+        "placeholder": "",
+        "default": "channel_admin",
+        "options": [
+          {
+            "display_name": "System Admins",
+            "value": "system_admin"
+          },
+          {
+            "display_name": "Team Admins",
+            "value": "team_admin"
+          },
+          {
+            "display_name": "Channel Admins",
+            "value": "channel_admin"
+          }
+        ]
+      },
+      {
+        "key": "HistoryLength",
+        "display_name": "History length:",
+        "type": "number",
+        "help_text": "How many of the recently asked users and questions are remembered. They are less likely to be asked again.",
+        "placeholder": "",
+        "default": 50
+      },
+      {
+        "key": "MaxQuestionLength",
+        "display_name": "Maximum question length:",
+        "type": "number",
+        "help_text": "The maximum number of characters of a question. Must not be larger than 4000.",
+        "placeholder": "",
+        "default": 200
+      },
+      {
+        "key": "MaxQuestions",
+        "display_name": "Maximum number of questions:",
+        "type": "number",
+        "help_text": "No more questions can be added once this number has been reached.",
+        "placeholder": "",
+        "default": 1000
+      },
+      {
+        "key": "MaxChannelUsers",
+        "display_name": "Maximum number of channel members:",
+        "type": "number",
+        "help_text": "How many members of a channel are considered when choosing who to ask.",
+        "placeholder": "",
+        "default": 1000
+      },
+      {
+        "key": "NewItemWeight",
+        "display_name": "Weight of new users and questions:",
+        "type": "number",
+        "help_text": "How much more likely users and questions that have not been asked lately are chosen. Recently asked ones are weighted by their position in the history, so this must be larger than the history length.",
+        "placeholder": "",
+        "default": 1000
+      },
+      {
+        "key": "SelectionStrategy",
+        "display_name": "Choose users and questions:",
+        "type": "radio",
+        "help_text": "How users and questions are chosen in channels that do not set their own strategy with the admin strategy command.",
+        "placeholder": "",
+        "default": "weighted",
+        "options": [
+          {
+            "display_name": "Prefer the ones not asked lately",
+            "value": "weighted"
+          },
+          {
+            "display_name": "Uniformly at random",
+            "value": "uniform"
+          },
+          {
+            "display_name": "Round-robin, everyone once before anyone twice",
+            "value": "roundrobin"
+          },
+          {
+            "display_name": "The ones asked the longest time ago",
+            "value": "leastrecent"
+          }
+        ]
+      },
+      {
+        "key": "SkippedStatuses",
+        "display_name": "Skipped statuses:",
+        "type": "text",
+        "help_text": "Comma separated list of statuses, users with one of these statuses are never asked. Possible values: online, away, offline, dnd.",
+        "placeholder": "",
+        "default": "offline,dnd"
+      },
+      {
+        "key": "RequireApproval",
+        "display_name": "Require approval of new questions:",
+        "type": "bool",
+        "help_text": "When true, questions added by users are only asked after a moderator approved them. Moderators get a direct message for every new question.",
+        "placeholder": "",
+        "default": false
+      },
+      {
+        "key": "Moderators",
+        "display_name": "Moderators:",
+        "type": "text",
+        "help_text": "Comma separated list of usernames that approve new questions. If empty, all System Admins are moderators.",
+        "placeholder": "",
+        "default": ""
+      },
+      {
+        "key": "FlagThreshold",
+        "display_name": "Down votes to flag a question:",
+        "type": "number",
+        "help_text": "Users vote on questions by reacting with thumbs up or thumbs down to the icebreaker posts. A question with this many more down votes than up votes is flagged and the moderators are asked to review it.",
+        "placeholder": "",
+        "default": 3
+      },
+      {
+        "key": "WelcomeDelay",
+        "display_name": "Welcome delay:",
+        "type": "number",
+        "help_text": "How many seconds after joining a channel that welcomes new members they are asked an icebreaker. With 0 they are asked right away.",
+        "placeholder": "",
+        "default": 60
+      },
+      {
+        "key": "WelcomeSkipGuests",
+        "display_name": "Do not welcome guests:",
+        "type": "bool",
+        "help_text": "When true, guests joining a channel that welcomes new members are not asked an icebreaker.",
+        "placeholder": "",
+        "default": false
+      },
+      {
+        "key": "WorkingHours",
+        "display_name": "Working hours:",
+        "type": "text",
+        "help_text": "The hours users are working in their own timezone, e.g. 9-17 for 9:00 until 17:00. Channels that only ask during working hours skip everyone outside of them. Users without a timezone are always asked.",
+        "placeholder": "",
+        "default": "9-17"
+      },
+      {
+        "key": "WorkingDays",
+        "display_name": "Working days:",
+        "type": "text",
+        "help_text": "The days of the week users are working, as range or comma separated list with 0 being Sunday, e.g. 1-5 for Monday to Friday.",
+        "placeholder": "",
+        "default": "1-5"
+      },
+      {
+        "key": "OnlyWorkingHours",
+        "display_name": "Only ask during working hours in all channels:",
+        "type": "bool",
+        "help_text": "When true, users are only asked during their working hours in every channel. Otherwise this is enabled per channel by its admins.",
+        "placeholder": "",
+        "default": false
+      }
+    ]
   }
 }
 `
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+const (
+	actionApprove = "approve"
+	actionReject  = "reject"
+
+	//maxModerators limits how many System Admins are notified about new questions if no moderators are configured
+	maxModerators = 100
+
+	//maxPendingQuestions and maxPendingQuestionsPerUser limit how many questions can wait for approval,
+	//so the moderators are not flooded with direct messages
+	maxPendingQuestions        = 100
+	maxPendingQuestionsPerUser = 5
+)
+
+// countPendingQuestions returns how many of the questions waiting for approval have been added by the given user
+func countPendingQuestions(data *IceBreakerData, userID string) int {
+	count := 0
+	for _, question := range data.PendingQuestions {
+		if question.Creator == userID {
+			count++
+		}
+	}
+	return count
+}
+
+// getModeratorIDs returns the IDs of the users that approve new questions. These are the configured moderators,
+// or the System Admins if none are configured
+func (p *Plugin) getModeratorIDs() []string {
+	moderatorIDs := []string{}
+
+	usernames := p.getConfiguration().getModerators()
+	if len(usernames) > 0 {
+		users, appErr := p.API.GetUsersByUsernames(usernames)
+		if appErr != nil {
+			p.API.LogError("Failed to get the icebreaker moderators", "err", appErr.Error())
+		}
+		for _, user := range users {
+			moderatorIDs = append(moderatorIDs, user.Id)
+		}
+		if len(moderatorIDs) > 0 {
+			return moderatorIDs
+		}
+	}
+
+	users, appErr := p.API.GetUsers(&model.UserGetOptions{Role: model.SYSTEM_ADMIN_ROLE_ID, Page: 0, PerPage: maxModerators})
+	if appErr != nil {
+		p.API.LogError("Failed to get the System Admins", "err", appErr.Error())
+	}
+	for _, user := range users {
+		moderatorIDs = append(moderatorIDs, user.Id)
+	}
+	return moderatorIDs
+}
+
+// isModerator checks whether the given user is allowed to approve or reject questions
+func (p *Plugin) isModerator(userID string) bool {
+	user, appErr := p.API.GetUser(userID)
+	if appErr != nil {
+		return false
+	}
+	if user.IsSystemAdmin() {
+		return true
+	}
+	return containsString(p.getConfiguration().getModerators(), user.Username)
+}
+
+// getModerationActions returns the buttons that are added to the messages sent to the moderators
+func getModerationActions(context *icebreakerContext) []*model.PostAction {
+	return []*model.PostAction{
+		&model.PostAction{
+			Id:    actionApprove,
+			Type:  model.POST_ACTION_TYPE_BUTTON,
+			Name:  "Approve",
+			Style: "good",
+			Integration: &model.PostActionIntegration{
+				URL:     fmt.Sprintf("/plugins/%s%s/%s", manifest.Id, actionsPath, actionApprove),
+				Context: context.toMap(),
+			},
+		},
+		&model.PostAction{
+			Id:    actionReject,
+			Type:  model.POST_ACTION_TYPE_BUTTON,
+			Name:  "Reject",
+			Style: "danger",
+			Integration: &model.PostActionIntegration{
+				URL:     fmt.Sprintf("/plugins/%s%s/%s", manifest.Id, actionsPath, actionReject),
+				Context: context.toMap(),
+			},
+		},
+	}
+}
+
+// notifyModerators sends a direct message with Approve/Reject buttons for the given question to every moderator
+func (p *Plugin) notifyModerators(question *Question) {
+	creator := p.getDisplayName(question.Creator)
+	for _, moderatorID := range p.getModeratorIDs() {
+		post := &model.Post{
+			UserId:  p.botID,
+			Message: fmt.Sprintf("@%s proposed a new icebreaker question: %s%s", creator, question.Question, question.getLabels()),
+		}
+		model.ParseSlackAttachment(post, []*model.SlackAttachment{
+			&model.SlackAttachment{
+				Actions: getModerationActions(&icebreakerContext{
+					TeamID:     question.TeamID,
+					ChannelID:  question.ChannelID,
+					UserID:     question.Creator,
+					QuestionID: question.ID,
+					Question:   question.Question,
+				}),
+			},
+		})
+		if err := p.sendDirectMessage(moderatorID, post); err != nil {
+			p.API.LogError("Failed to notify moderator", "user", moderatorID, "err", err.Error())
+		}
+	}
+}
+
+// sendDirectMessage sends the given post from the bot to the given user
+func (p *Plugin) sendDirectMessage(userID string, post *model.Post) *model.AppError {
+	channel, appErr := p.API.GetDirectChannel(p.botID, userID)
+	if appErr != nil {
+		return appErr
+	}
+	post.ChannelId = channel.Id
+	post.UserId = p.botID
+	_, appErr = p.API.CreatePost(post)
+	return appErr
+}
+
+// handleApprove moves a pending question into the pool of questions that are asked
+func (p *Plugin) handleApprove(userID string, request *model.PostActionIntegrationRequest) *model.PostActionIntegrationResponse {
+	return p.handleModeration(userID, request, true)
+}
+
+// handleReject removes a pending question
+func (p *Plugin) handleReject(userID string, request *model.PostActionIntegrationRequest) *model.PostActionIntegrationResponse {
+	return p.handleModeration(userID, request, false)
+}
+
+func (p *Plugin) handleModeration(userID string, request *model.PostActionIntegrationRequest, approve bool) *model.PostActionIntegrationResponse {
+	if !p.isModerator(userID) {
+		return &model.PostActionIntegrationResponse{EphemeralText: "Only moderators can approve or reject questions."}
+	}
+	context := icebreakerContextFromMap(request.Context)
+
+	post, errResponse := p.lockIcebreakerPost(request.PostId)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	pending := &Question{ID: context.QuestionID, Question: context.Question, TeamID: context.TeamID, ChannelID: context.ChannelID}
+	found := false
+	maxQuestions := p.getConfiguration().getMaxQuestions()
+	var errText string
+	err := p.updateData(func(data *IceBreakerData) error {
+		found = false
+		errText = ""
+		for index, question := range data.PendingQuestions {
+			if !question.isSameAs(pending) || question.TeamID != pending.TeamID || question.ChannelID != pending.ChannelID {
+				continue
+			}
+			found = true
+			*pending = question
+			if approve && len(data.Questions) > maxQuestions {
+				errText = fmt.Sprintf("There are already more than %d questions. Clean up before approving more questions.", maxQuestions)
+				return errSkipUpdate
+			}
+
+			data.PendingQuestions = append(data.PendingQuestions[:index], data.PendingQuestions[index+1:]...)
+			if approve && !containsQuestion(data.Questions, pending) {
+				data.Questions = append(data.Questions, *pending)
+			}
+			return nil
+		}
+		return errSkipUpdate
+	})
+	if err != nil || errText != "" {
+		p.unlockIcebreakerPost(request.PostId)
+		if errText == "" {
+			p.API.LogError("Failed to moderate question", "err", err.Error())
+			errText = "Error: Failed to access the icebreaker data, please try again."
+		}
+		return &model.PostActionIntegrationResponse{EphemeralText: errText}
+	}
+
+	if !found {
+		p.closeIcebreakerPost(post, "This question has already been handled by another moderator.")
+		return &model.PostActionIntegrationResponse{}
+	}
+
+	moderator := p.getDisplayName(userID)
+	decision := "rejected"
+	if approve {
+		decision = "approved"
+	}
+	p.closeIcebreakerPost(post, fmt.Sprintf("@%s %s this question.", moderator, decision))
+
+	//let the submitter know what happened to their question
+	notification := &model.Post{Message: fmt.Sprintf("Your icebreaker question '%s' has been %s by @%s.", pending.Question, decision, moderator)}
+	if appErr := p.sendDirectMessage(pending.Creator, notification); appErr != nil {
+		p.API.LogError("Failed to notify submitter", "user", pending.Creator, "err", appErr.Error())
+	}
+	return &model.PostActionIntegrationResponse{}
+}
+
+func (p *Plugin) executeCommandIcebreakerPending(args *model.CommandArgs) *model.CommandResponse {
+	data, err := p.ReadFromStorage()
+	if err != nil {
+		return p.getStorageErrorResponse(err)
+	}
+
+	if len(data.PendingQuestions) == 0 {
+		return &model.CommandResponse{
+			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+			Text:         "There are no questions waiting for approval...",
+		}
+	}
+
+	message := "Questions waiting for approval:\n"
+	for index, question := range data.PendingQuestions {
+		message = message + fmt.Sprintf("%d.\t@%s:\t%s%s\n", index+1, p.getDisplayName(question.Creator), question.Question, question.getLabels())
+	}
+	return &model.CommandResponse{
+		ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+		Username:     "icebreaker",
+		Text:         message,
+	}
+}
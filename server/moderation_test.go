@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/mattermost/mattermost-server/v5/plugin/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func setupModerationTest(t *testing.T) (*Plugin, *plugintest.API) {
+	api, _ := newFakeKVStore(nil)
+	api.On("GetUser", "Submitter").Return(&model.User{Id: "Submitter", Username: "submitter", Roles: model.SYSTEM_USER_ROLE_ID}, nil)
+	api.On("GetUser", "Moderator").Return(&model.User{Id: "Moderator", Username: "moderator", Roles: model.SYSTEM_USER_ROLE_ID}, nil)
+	api.On("GetUsersByUsernames", []string{"moderator"}).Return([]*model.User{&model.User{Id: "Moderator", Username: "moderator"}}, nil)
+	api.On("GetDirectChannel", "BotUser", mock.AnythingOfType("string")).Return(
+		func(botID string, userID string) *model.Channel { return &model.Channel{Id: "DM_" + userID} },
+		func(botID string, userID string) *model.AppError { return nil })
+	api.On("GetPost", "IcebreakerPost").Return(
+		func(postID string) *model.Post {
+			return &model.Post{Id: "IcebreakerPost", UserId: "BotUser", Message: "New question"}
+		},
+		func(postID string) *model.AppError { return nil })
+	api.On("UpdatePost", mock.AnythingOfType("*model.Post")).Return(nil, nil)
+
+	plugin := &Plugin{botID: "BotUser"}
+	plugin.SetAPI(api)
+	plugin.setConfiguration(&configuration{RequireApproval: true, Moderators: "@moderator"})
+	return plugin, api
+}
+
+func TestModeration_add(t *testing.T) {
+	plugin, api := setupModerationTest(t)
+	api.On("CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+		return post.ChannelId == "DM_Moderator" && strings.HasPrefix(post.Message, "@submitter proposed a new icebreaker question: How do you do?") && len(post.Attachments()) == 1
+	})).Return(nil, nil)
+
+	response, _ := plugin.ExecuteCommand(nil, &model.CommandArgs{Command: "/icebreaker add How do you do?", UserId: "Submitter"})
+	assert.Equal(t, "Thanks submitter! Your question 'How do you do?' will be asked once a moderator approved it.", response.Text)
+	api.AssertNumberOfCalls(t, "CreatePost", 1)
+
+	data := readData(t, plugin)
+	assert.Empty(t, data.Questions)
+	require.Len(t, data.PendingQuestions, 1)
+	assert.Equal(t, "How do you do?", data.PendingQuestions[0].Question)
+
+	//pending questions are never asked
+	_, appErr := plugin.GetRandomQuestion("TestTeam", "TestChannel", questionFilter{})
+	assert.NotNil(t, appErr)
+
+	//pending questions cannot be added twice
+	response, _ = plugin.ExecuteCommand(nil, &model.CommandArgs{Command: "/icebreaker add How do you do?", UserId: "Submitter"})
+	assert.Equal(t, "Error: Your question has already been added", response.Text)
+}
+
+func TestModeration_pendingLimits(t *testing.T) {
+	plugin, api := setupModerationTest(t)
+	api.On("CreatePost", mock.AnythingOfType("*model.Post")).Return(nil, nil)
+	add := func(question string) string {
+		response, _ := plugin.ExecuteCommand(nil, &model.CommandArgs{Command: "/icebreaker add " + question, UserId: "Submitter"})
+		return response.Text
+	}
+
+	//every user can only have a few questions waiting for approval...
+	for i := 0; i < maxPendingQuestionsPerUser; i++ {
+		assert.Equal(t, fmt.Sprintf("Thanks submitter! Your question 'Question %d?' will be asked once a moderator approved it.", i), add(fmt.Sprintf("Question %d?", i)))
+	}
+	assert.Equal(t, "Your question has not been added: 5 of your questions are already waiting for approval. Please wait until a moderator approved them.", add("One too many?"))
+	assert.Len(t, readData(t, plugin).PendingQuestions, maxPendingQuestionsPerUser)
+	api.AssertNumberOfCalls(t, "CreatePost", maxPendingQuestionsPerUser)
+
+	//...and there is a limit for all users
+	require.NoError(t, plugin.updateData(func(data *IceBreakerData) error {
+		data.PendingQuestions = nil
+		for i := 0; i < maxPendingQuestions; i++ {
+			data.PendingQuestions = append(data.PendingQuestions, Question{Creator: fmt.Sprintf("User%d", i), Question: fmt.Sprintf("Question %d?", i)})
+		}
+		return nil
+	}))
+	assert.Equal(t, "Your question has not been added: There are already 100 questions waiting for approval. Please try again later.", add("Anyone there?"))
+	assert.Len(t, readData(t, plugin).PendingQuestions, maxPendingQuestions)
+	api.AssertNumberOfCalls(t, "CreatePost", maxPendingQuestionsPerUser)
+}
+
+func TestModeration_decisions(t *testing.T) {
+	context := &icebreakerContext{UserID: "Submitter", Question: "How do you do?"}
+	addPending := func(t *testing.T, plugin *Plugin) {
+		require.NoError(t, plugin.updateData(func(data *IceBreakerData) error {
+			data.PendingQuestions = []Question{Question{Creator: "Submitter", Question: "How do you do?"}}
+			return nil
+		}))
+	}
+
+	t.Run("Only moderators can decide", func(t *testing.T) {
+		plugin, _ := setupModerationTest(t)
+		addPending(t, plugin)
+
+		response := sendAction(t, plugin, "Submitter", actionApprove, context)
+		assert.Equal(t, "Only moderators can approve or reject questions.", response.EphemeralText)
+		assert.Len(t, readData(t, plugin).PendingQuestions, 1)
+	})
+	t.Run("Approve", func(t *testing.T) {
+		plugin, api := setupModerationTest(t)
+		api.On("CreatePost", &model.Post{ChannelId: "DM_Submitter", UserId: "BotUser", Message: "Your icebreaker question 'How do you do?' has been approved by @moderator."}).Return(nil, nil)
+		addPending(t, plugin)
+
+		response := sendAction(t, plugin, "Moderator", actionApprove, context)
+		assert.Equal(t, "", response.EphemeralText)
+		data := readData(t, plugin)
+		assert.Empty(t, data.PendingQuestions)
+		assert.Equal(t, []Question{Question{Creator: "Submitter", Question: "How do you do?"}}, data.Questions)
+		api.AssertNumberOfCalls(t, "CreatePost", 1)
+	})
+	t.Run("Reject", func(t *testing.T) {
+		plugin, api := setupModerationTest(t)
+		api.On("CreatePost", &model.Post{ChannelId: "DM_Submitter", UserId: "BotUser", Message: "Your icebreaker question 'How do you do?' has been rejected by @moderator."}).Return(nil, nil)
+		addPending(t, plugin)
+
+		sendAction(t, plugin, "Moderator", actionReject, context)
+		data := readData(t, plugin)
+		assert.Empty(t, data.PendingQuestions)
+		assert.Empty(t, data.Questions)
+		api.AssertNumberOfCalls(t, "CreatePost", 1)
+	})
+}
+
+func TestPendingCommand(t *testing.T) {
+	plugin, api := setupModerationTest(t)
+	api.On("GetUser", "AdminUser").Return(&model.User{Id: "AdminUser", Roles: model.SYSTEM_ADMIN_ROLE_ID}, nil)
+
+	response, _ := plugin.ExecuteCommand(nil, &model.CommandArgs{Command: "/icebreaker admin pending", UserId: "AdminUser"})
+	assert.Equal(t, "There are no questions waiting for approval...", response.Text)
+
+	require.NoError(t, plugin.updateData(func(data *IceBreakerData) error {
+		data.PendingQuestions = []Question{Question{Creator: "Submitter", Question: "How do you do?", Category: "mild"}}
+		return nil
+	}))
+	response, _ = plugin.ExecuteCommand(nil, &model.CommandArgs{Command: "/icebreaker admin pending", UserId: "AdminUser"})
+	assert.Equal(t, "Questions waiting for approval:\n1.\t@submitter:\tHow do you do? (mild)\n", response.Text)
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	//pairHistoryKeyPrefix is the prefix of the keys the past pairings of a channel are stored under, followed by the channel ID
+	pairHistoryKeyPrefix = "IceBreakerPairs_"
+
+	//maxPairHistory limits how many past pairs are remembered per channel
+	maxPairHistory = 1000
+
+	//pairingAttempts sets how many random pairings are tried to find one that repeats as few past pairs as possible
+	pairingAttempts = 50
+)
+
+var errNotEnoughUsers = errors.New("there are not enough users to pair")
+
+// pairUsers splits the users of the channel into pairs, with a trio for an odd number of users, and starts a group
+// message with a starter question for each of them. Past pairings of the channel are avoided if possible
+func (p *Plugin) pairUsers(teamID string, channelID string) ([][]*model.User, error) {
+	users, err := p.getPairableUsers(channelID)
+	if err != nil {
+		return nil, err
+	}
+	if len(users) < 2 {
+		return nil, errNotEnoughUsers
+	}
+
+	history, err := p.getPairHistory(channelID)
+	if err != nil {
+		return nil, err
+	}
+	groups := findPairing(p.getRandom(), users, history)
+
+	channelName := ""
+	if channel, appErr := p.API.GetChannel(channelID); appErr == nil {
+		channelName = channel.Name
+	}
+	for _, group := range groups {
+		question, _ := p.GetRandomQuestion(teamID, channelID, questionFilter{})
+		if err := p.startPairChat(group, question, channelName); err != nil {
+			p.API.LogError("Failed to start icebreaker pair chat", "channel", channelID, "err", err.Error())
+		}
+	}
+
+	//remember the pairs so they are not repeated next time
+	err = p.updateKey(pairHistoryKeyPrefix+channelID, func(oldValue []byte) ([]byte, error) {
+		history, err := decodePairHistory(oldValue)
+		if err != nil {
+			return nil, err
+		}
+		for _, group := range groups {
+			history = append(history, getPairKeys(group)...)
+		}
+		if len(history) > maxPairHistory {
+			history = history[len(history)-maxPairHistory:]
+		}
+		return json.Marshal(history)
+	})
+	if err != nil {
+		p.API.LogError("Failed to store the icebreaker pairs", "channel", channelID, "err", err.Error())
+	}
+
+	message := "Time for a chat! I paired you up and sent every group a message:\n"
+	for _, group := range groups {
+		message = message + fmt.Sprintf("* %s\n", strings.Join(getMentions(group), " & "))
+	}
+	if _, appErr := p.API.CreatePost(&model.Post{ChannelId: channelID, UserId: p.botID, Message: message}); appErr != nil {
+		p.API.LogError("Error: Failed to create post", "err", appErr.Error())
+		return groups, errCreatePost
+	}
+	return groups, nil
+}
+
+// getPairableUsers returns all users of the channel that can be paired. Unlike asking a question the status of the
+// users does not matter, as they can answer the group message whenever they are back
+func (p *Plugin) getPairableUsers(channelID string) ([]*model.User, error) {
+	data, err := p.ReadFromStorage()
+	if err != nil {
+		return nil, err
+	}
+
+	users, appErr := p.API.GetUsersInChannel(channelID, "username", 0, p.getConfiguration().getMaxChannelUsers())
+	if appErr != nil {
+		return nil, errors.Wrap(appErr, "failed to get the users of the channel")
+	}
+	pairable := []*model.User{}
+	for _, user := range users {
+		if user.IsBot || user.DeleteAt != 0 || !data.isUserAskable(user.Id, channelID) {
+			continue
+		}
+		pairable = append(pairable, user)
+	}
+	return pairable, nil
+}
+
+// findPairing tries multiple random pairings and returns the one with the least pairs that are part of the history
+func findPairing(random *rand.Rand, users []*model.User, history []string) [][]*model.User {
+	pastPairs := map[string]int{}
+	for _, pairKey := range history {
+		pastPairs[pairKey]++
+	}
+
+	var bestGroups [][]*model.User
+	bestRepeats := -1
+	for attempt := 0; attempt < pairingAttempts && bestRepeats != 0; attempt++ {
+		shuffled := make([]*model.User, len(users))
+		copy(shuffled, users)
+		random.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+
+		groups := groupUsers(shuffled)
+		repeats := 0
+		for _, group := range groups {
+			for _, pairKey := range getPairKeys(group) {
+				repeats += pastPairs[pairKey]
+			}
+		}
+		if bestRepeats < 0 || repeats < bestRepeats {
+			bestGroups = groups
+			bestRepeats = repeats
+		}
+	}
+	return bestGroups
+}
+
+// groupUsers splits the users into pairs in the given order. For an odd number of users the last group is a trio
+func groupUsers(users []*model.User) [][]*model.User {
+	groups := [][]*model.User{}
+	for index := 0; index+1 < len(users); index += 2 {
+		groups = append(groups, []*model.User{users[index], users[index+1]})
+	}
+	if len(users)%2 == 1 && len(groups) > 0 {
+		groups[len(groups)-1] = append(groups[len(groups)-1], users[len(users)-1])
+	}
+	return groups
+}
+
+// getPairKeys returns a key for every pair of users within the group, independent of the order of the users
+func getPairKeys(group []*model.User) []string {
+	keys := []string{}
+	for i := 0; i < len(group); i++ {
+		for j := i + 1; j < len(group); j++ {
+			pair := []string{group[i].Id, group[j].Id}
+			sort.Strings(pair)
+			keys = append(keys, strings.Join(pair, "-"))
+		}
+	}
+	return keys
+}
+
+func getMentions(users []*model.User) []string {
+	mentions := []string{}
+	for _, user := range users {
+		mentions = append(mentions, "@"+user.GetDisplayName(""))
+	}
+	return mentions
+}
+
+// startPairChat creates a group message for the given users and the bot and posts the starter question to it
+func (p *Plugin) startPairChat(group []*model.User, question *Question, channelName string) error {
+	userIDs := []string{p.botID}
+	for _, user := range group {
+		userIDs = append(userIDs, user.Id)
+	}
+	channel, appErr := p.API.GetGroupChannel(userIDs)
+	if appErr != nil {
+		return errors.Wrap(appErr, "failed to get the group channel")
+	}
+
+	mentions := getMentions(group)
+	message := fmt.Sprintf("Hey %s and %s! You have been paired for a chat", strings.Join(mentions[:len(mentions)-1], ", "), mentions[len(mentions)-1])
+	if channelName != "" {
+		message = message + fmt.Sprintf(" in ~%s", channelName)
+	}
+	message = message + "."
+	if question != nil {
+		message = message + fmt.Sprintf(" Here is a question to get you started: %s", question.Question)
+	}
+
+	if _, appErr := p.API.CreatePost(&model.Post{ChannelId: channel.Id, UserId: p.botID, Message: message}); appErr != nil {
+		return errors.Wrap(appErr, "failed to post the starter question")
+	}
+	return nil
+}
+
+// getPairHistory returns the pairs of users that have been paired in the channel before, the oldest pair first
+func (p *Plugin) getPairHistory(channelID string) ([]string, error) {
+	kvData, appErr := p.API.KVGet(pairHistoryKeyPrefix + channelID)
+	if appErr != nil {
+		return nil, errors.Wrapf(appErr, "failed to read %s", pairHistoryKeyPrefix+channelID)
+	}
+	return decodePairHistory(kvData)
+}
+
+func decodePairHistory(kvData []byte) ([]string, error) {
+	history := []string{}
+	if len(kvData) == 0 {
+		return history, nil
+	}
+	if err := json.Unmarshal(kvData, &history); err != nil {
+		return nil, errors.Wrap(err, "failed to decode the pair history")
+	}
+	return history, nil
+}
+
+func (p *Plugin) executeCommandIcebreakerPair(args *model.CommandArgs) *model.CommandResponse {
+	_, err := p.pairUsers(args.TeamId, args.ChannelId)
+	switch errors.Cause(err) {
+	case nil:
+		return &model.CommandResponse{}
+	case errNotEnoughUsers:
+		return &model.CommandResponse{
+			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+			Text:         "Error: There need to be at least two users in this channel who did not opt out to pair them.",
+		}
+	case errCreatePost:
+		return &model.CommandResponse{
+			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+			Text:         "Error: Failed to create post",
+		}
+	default:
+		return p.getStorageErrorResponse(err)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/mattermost/mattermost-server/v5/plugin/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestUsers(ids ...string) []*model.User {
+	users := []*model.User{}
+	for _, id := range ids {
+		users = append(users, &model.User{Id: id, Username: strings.ToLower(id)})
+	}
+	return users
+}
+
+func setupPairingTest(t *testing.T, users []*model.User) (*Plugin, *plugintest.API, *fakeKVStore) {
+	icebreakerData := &IceBreakerData{
+		Questions: []Question{Question{ID: "q1", Creator: "TestUser", Question: "How do you do?"}},
+	}
+	reqBodyBytes := new(bytes.Buffer)
+	json.NewEncoder(reqBodyBytes).Encode(icebreakerData)
+
+	api, store := newFakeKVStore(map[string][]byte{KVKEY: reqBodyBytes.Bytes()})
+	api.On("GetUsersInChannel", "TestChannel", "username", 0, defaultMaxChannelUsers).Return(users, nil)
+	api.On("GetChannel", "TestChannel").Return(&model.Channel{Id: "TestChannel", Name: "test-channel"}, nil)
+	api.On("GetGroupChannel", mock.AnythingOfType("[]string")).Return(
+		func(userIDs []string) *model.Channel {
+			return &model.Channel{Id: "GM_" + strings.Join(userIDs[1:], "_")}
+		},
+		func(userIDs []string) *model.AppError { return nil })
+	api.On("CreatePost", mock.AnythingOfType("*model.Post")).Return(nil, nil)
+	api.On("GetUser", "AdminUser").Return(&model.User{Id: "AdminUser", Roles: model.SYSTEM_ADMIN_ROLE_ID}, nil)
+	plugin := &Plugin{botID: "BotUser"}
+	plugin.SetAPI(api)
+	return plugin, api, store
+}
+
+func TestGroupUsers(t *testing.T) {
+	assert.Empty(t, groupUsers(newTestUsers("A")))
+	assert.Equal(t, [][]*model.User{newTestUsers("A", "B")}, groupUsers(newTestUsers("A", "B")))
+	assert.Equal(t, [][]*model.User{newTestUsers("A", "B", "C")}, groupUsers(newTestUsers("A", "B", "C")))
+	assert.Equal(t, [][]*model.User{newTestUsers("A", "B"), newTestUsers("C", "D", "E")}, groupUsers(newTestUsers("A", "B", "C", "D", "E")))
+}
+
+func TestFindPairing_avoidsRepeats(t *testing.T) {
+	users := newTestUsers("A", "B", "C", "D")
+	history := []string{"A-B", "C-D", "A-C", "B-D"}
+
+	random := newRandom(1337)
+	for i := 0; i < 10; i++ {
+		groups := findPairing(random, users, history)
+		require.Len(t, groups, 2)
+		keys := append(getPairKeys(groups[0]), getPairKeys(groups[1])...)
+		assert.ElementsMatch(t, []string{"A-D", "B-C"}, keys)
+	}
+}
+
+func TestPairCommand(t *testing.T) {
+	t.Run("Pairs with a trio", func(t *testing.T) {
+		users := append(newTestUsers("A", "B", "C"), &model.User{Id: "Bot", IsBot: true}, &model.User{Id: "Deactivated", DeleteAt: 1})
+		plugin, api, store := setupPairingTest(t, users)
+
+		response, _ := plugin.ExecuteCommand(nil, &model.CommandArgs{Command: "/icebreaker pair", UserId: "AdminUser", TeamId: "TestTeam", ChannelId: "TestChannel"})
+		assert.Equal(t, &model.CommandResponse{}, response)
+
+		api.AssertNumberOfCalls(t, "GetGroupChannel", 1)
+		api.AssertCalled(t, "CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+			return strings.HasPrefix(post.ChannelId, "GM_") && strings.HasSuffix(post.Message, "! You have been paired for a chat in ~test-channel. Here is a question to get you started: How do you do?")
+		}))
+		api.AssertCalled(t, "CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+			return post.ChannelId == "TestChannel" && strings.HasPrefix(post.Message, "Time for a chat! I paired you up and sent every group a message:\n* @")
+		}))
+
+		history := []string{}
+		require.NoError(t, json.Unmarshal(store.get(pairHistoryKeyPrefix+"TestChannel"), &history))
+		assert.ElementsMatch(t, []string{"A-B", "A-C", "B-C"}, history)
+	})
+	t.Run("Opted out users are not paired", func(t *testing.T) {
+		plugin, _, _ := setupPairingTest(t, newTestUsers("A", "B"))
+		require.NoError(t, plugin.updateData(func(data *IceBreakerData) error {
+			data.UserPreferences = map[string]*UserPreferences{"B": &UserPreferences{OptedOut: true}}
+			return nil
+		}))
+
+		response, _ := plugin.ExecuteCommand(nil, &model.CommandArgs{Command: "/icebreaker pair", UserId: "AdminUser", TeamId: "TestTeam", ChannelId: "TestChannel"})
+		assert.Equal(t, "Error: There need to be at least two users in this channel who did not opt out to pair them.", response.Text)
+	})
+	t.Run("Only admins pair", func(t *testing.T) {
+		plugin, api, _ := setupPairingTest(t, newTestUsers("A", "B"))
+		api.On("GetUser", "A").Return(&model.User{Id: "A", Roles: model.SYSTEM_USER_ROLE_ID}, nil)
+		api.On("HasPermissionToChannel", "A", "TestChannel", model.PERMISSION_MANAGE_CHANNEL_ROLES).Return(false)
+
+		response, _ := plugin.ExecuteCommand(nil, &model.CommandArgs{Command: "/icebreaker pair", UserId: "A", TeamId: "TestTeam", ChannelId: "TestChannel"})
+		assert.Equal(t, "Error: You need to be Channel Admin in order to use this command", response.Text)
+		api.AssertNotCalled(t, "GetGroupChannel", mock.Anything)
+	})
+}
+
+func TestRunSchedules_pairing(t *testing.T) {
+	plugin, api, _ := setupPairingTest(t, newTestUsers("A", "B"))
+	require.NoError(t, plugin.updateData(func(data *IceBreakerData) error {
+		data.Schedules = []Schedule{Schedule{ID: "weekly", TeamID: "TestTeam", ChannelID: "TestChannel", Cron: "0 9 * * 1", Mode: scheduleModePair}}
+		return nil
+	}))
+
+	plugin.runSchedules(time.Date(2021, time.March, 1, 9, 0, 0, 0, time.UTC)) //Monday
+	api.AssertCalled(t, "GetGroupChannel", mock.MatchedBy(func(userIDs []string) bool {
+		return assert.ObjectsAreEqual([]string{"BotUser", "A", "B"}, userIDs) || assert.ObjectsAreEqual([]string{"BotUser", "B", "A"}, userIDs)
+	}))
+	api.AssertNumberOfCalls(t, "CreatePost", 2)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+const (
+	//roles that can be configured as minimum role for the admin commands
+	roleSystemAdmin  = "system_admin"
+	roleTeamAdmin    = "team_admin"
+	roleChannelAdmin = "channel_admin"
+
+	//permissionQuestions is needed to manage the questions
+	permissionQuestions = "questions"
+
+	//permissionChannelSettings is needed to change the settings of a channel
+	permissionChannelSettings = "channel_settings"
+)
+
+// adminCommandPermissions maps every admin command to the permission that is needed to execute it.
+// Commands that are missing here can only be executed by System Admins. This includes the commands acting on
+// the questions of all teams at once (clearall, reset, export, import, restore and undo), as Team and Channel Admins
+// are only checked for the team and channel the command is executed in
+var adminCommandPermissions = map[string]string{
+	commandIcebreakerRemove:         permissionQuestions,
+	commandIcebreakerPending:        permissionQuestions,
+	commandIcebreakerFlagged:        permissionQuestions,
+	commandIcebreakerUnflag:         permissionQuestions,
+	commandIcebreakerBackups:        permissionQuestions,
+	commandIcebreakerPair:           permissionChannelSettings,
+	commandIcebreakerScheduleAdd:    permissionChannelSettings,
+	commandIcebreakerScheduleRemove: permissionChannelSettings,
+	commandIcebreakerOptInOnly:      permissionChannelSettings,
+	commandIcebreakerOptOuts:        permissionChannelSettings,
+	commandIcebreakerWelcome:        permissionChannelSettings,
+	commandIcebreakerWorkingHours:   permissionChannelSettings,
+	commandIcebreakerStrategy:       permissionChannelSettings,
+}
+
+// getRequiredRole returns the minimum role configured for the given permission
+func (c *configuration) getRequiredRole(permission string) string {
+	switch permission {
+	case permissionQuestions:
+		if isValidRole(c.QuestionsPermission) {
+			return c.QuestionsPermission
+		}
+		return roleSystemAdmin
+	case permissionChannelSettings:
+		if isValidRole(c.ChannelSettingsPermission) {
+			return c.ChannelSettingsPermission
+		}
+		return roleChannelAdmin
+	default:
+		return roleSystemAdmin
+	}
+}
+
+func isValidRole(role string) bool {
+	return role == roleSystemAdmin || role == roleTeamAdmin || role == roleChannelAdmin
+}
+
+func getRoleDisplayName(role string) string {
+	switch role {
+	case roleTeamAdmin:
+		return "Team Admin"
+	case roleChannelAdmin:
+		return "Channel Admin"
+	default:
+		return "System Admin"
+	}
+}
+
+// requirePermission checks whether the user executing the command has the given permission.
+// Returns nil if that's the case, else a response telling the user what's missing
+func (p *Plugin) requirePermission(args *model.CommandArgs, permission string) *model.CommandResponse {
+	//System Admins are always allowed, this does not need any permission lookup
+	sourceUser, appErr := p.API.GetUser(args.UserId)
+	if appErr == nil && sourceUser.IsSystemAdmin() {
+		return nil
+	}
+
+	role := p.getConfiguration().getRequiredRole(permission)
+	if p.hasRole(args.UserId, args.TeamId, args.ChannelId, role) {
+		return nil
+	}
+
+	return &model.CommandResponse{
+		ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+		Text:         fmt.Sprintf("Error: You need to be %s in order to use this command", getRoleDisplayName(role)),
+	}
+}
+
+// hasRole checks whether the user has at least the given role in the given team or channel.
+// Team Admins are Channel Admins of every channel in their team as well
+func (p *Plugin) hasRole(userID string, teamID string, channelID string, role string) bool {
+	switch role {
+	case roleTeamAdmin:
+		return teamID != "" && p.API.HasPermissionToTeam(userID, teamID, model.PERMISSION_MANAGE_TEAM)
+	case roleChannelAdmin:
+		return channelID != "" && p.API.HasPermissionToChannel(userID, channelID, model.PERMISSION_MANAGE_CHANNEL_ROLES)
+	default:
+		return false
+	}
+}
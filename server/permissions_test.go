@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/mattermost/mattermost-server/v5/plugin/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequirePermission(t *testing.T) {
+	//the users and what they are allowed to do in TestTeam/TestChannel
+	setupAPI := func() *plugintest.API {
+		api := &plugintest.API{}
+		api.On("GetUser", "SystemAdmin").Return(&model.User{Id: "SystemAdmin", Roles: model.SYSTEM_ADMIN_ROLE_ID}, nil)
+		for _, userID := range []string{"TeamAdmin", "ChannelAdmin", "Member"} {
+			api.On("GetUser", userID).Return(&model.User{Id: userID, Roles: model.SYSTEM_USER_ROLE_ID}, nil)
+		}
+		api.On("HasPermissionToTeam", "TeamAdmin", "TestTeam", model.PERMISSION_MANAGE_TEAM).Return(true)
+		api.On("HasPermissionToTeam", mock.AnythingOfType("string"), "TestTeam", model.PERMISSION_MANAGE_TEAM).Return(false)
+		api.On("HasPermissionToChannel", "TeamAdmin", "TestChannel", model.PERMISSION_MANAGE_CHANNEL_ROLES).Return(true)
+		api.On("HasPermissionToChannel", "ChannelAdmin", "TestChannel", model.PERMISSION_MANAGE_CHANNEL_ROLES).Return(true)
+		api.On("HasPermissionToChannel", mock.AnythingOfType("string"), "TestChannel", model.PERMISSION_MANAGE_CHANNEL_ROLES).Return(false)
+		return api
+	}
+
+	tests := []struct {
+		name    string
+		role    string
+		allowed []string
+		denied  []string
+	}{
+		{name: "System Admins only", role: roleSystemAdmin, allowed: []string{"SystemAdmin"}, denied: []string{"TeamAdmin", "ChannelAdmin", "Member"}},
+		{name: "Team Admins", role: roleTeamAdmin, allowed: []string{"SystemAdmin", "TeamAdmin"}, denied: []string{"ChannelAdmin", "Member"}},
+		{name: "Channel Admins", role: roleChannelAdmin, allowed: []string{"SystemAdmin", "TeamAdmin", "ChannelAdmin"}, denied: []string{"Member"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			plugin := &Plugin{}
+			plugin.SetAPI(setupAPI())
+			plugin.setConfiguration(&configuration{QuestionsPermission: test.role})
+
+			for _, userID := range test.allowed {
+				args := &model.CommandArgs{UserId: userID, TeamId: "TestTeam", ChannelId: "TestChannel"}
+				assert.Nil(t, plugin.requirePermission(args, permissionQuestions), userID)
+			}
+			for _, userID := range test.denied {
+				args := &model.CommandArgs{UserId: userID, TeamId: "TestTeam", ChannelId: "TestChannel"}
+				response := plugin.requirePermission(args, permissionQuestions)
+				if assert.NotNil(t, response, userID) {
+					assert.Equal(t, "Error: You need to be "+getRoleDisplayName(test.role)+" in order to use this command", response.Text)
+				}
+			}
+		})
+	}
+}
+
+func TestGetRequiredRole(t *testing.T) {
+	defaultConfig := &configuration{}
+	assert.Equal(t, roleSystemAdmin, defaultConfig.getRequiredRole(permissionQuestions))
+	assert.Equal(t, roleChannelAdmin, defaultConfig.getRequiredRole(permissionChannelSettings))
+	assert.Equal(t, roleSystemAdmin, defaultConfig.getRequiredRole("unknown"))
+
+	config := &configuration{QuestionsPermission: roleTeamAdmin, ChannelSettingsPermission: "invalid"}
+	assert.Equal(t, roleTeamAdmin, config.getRequiredRole(permissionQuestions))
+	assert.Equal(t, roleChannelAdmin, config.getRequiredRole(permissionChannelSettings))
+}
+
+func TestAdminCommand_channelAdmin(t *testing.T) {
+	api, _ := newFakeKVStore(nil)
+	api.On("GetUser", "ChannelAdmin").Return(&model.User{Id: "ChannelAdmin", Roles: model.SYSTEM_USER_ROLE_ID}, nil)
+	api.On("HasPermissionToChannel", "ChannelAdmin", "TestChannel", model.PERMISSION_MANAGE_CHANNEL_ROLES).Return(true)
+	plugin := &Plugin{}
+	plugin.SetAPI(api)
+
+	//channel settings are allowed by default...
+	response, _ := plugin.ExecuteCommand(nil, &model.CommandArgs{Command: "/icebreaker admin optinonly on", UserId: "ChannelAdmin", ChannelId: "TestChannel"})
+	assert.Equal(t, []string{"TestChannel"}, readData(t, plugin).OptInOnlyChannels, response.Text)
+
+	//...managing the questions is not
+	response, _ = plugin.ExecuteCommand(nil, &model.CommandArgs{Command: "/icebreaker admin clearall", UserId: "ChannelAdmin", ChannelId: "TestChannel"})
+	assert.Equal(t, "Error: You need to be System Admin in order to use this command", response.Text)
+}
+
+func TestAdminCommand_otherTeams(t *testing.T) {
+	data := &IceBreakerData{
+		Questions: []Question{
+			Question{ID: "q1", Creator: "TestUser", Question: "Global question?"},
+			Question{ID: "q2", Creator: "TestUser", Question: "Question of another team?", TeamID: "OtherTeam"},
+			Question{ID: "q3", Creator: "TestUser", Question: "Question of another channel?", TeamID: "OtherTeam", ChannelID: "OtherChannel"},
+		},
+	}
+	dataBytes, err := json.Marshal(data)
+	require.NoError(t, err)
+
+	api, _ := newFakeKVStore(map[string][]byte{KVKEY: dataBytes})
+	api.On("GetUser", "TeamAdmin").Return(&model.User{Id: "TeamAdmin", Roles: model.SYSTEM_USER_ROLE_ID}, nil)
+	api.On("HasPermissionToTeam", "TeamAdmin", "TestTeam", model.PERMISSION_MANAGE_TEAM).Return(true)
+	plugin := &Plugin{}
+	plugin.SetAPI(api)
+	plugin.setConfiguration(&configuration{QuestionsPermission: roleTeamAdmin})
+
+	//the questions of other teams must not be touched by the admin of this team
+	for _, command := range []string{"admin clearall", "admin reset questions", "admin export", "admin import ImportFile", "admin restore 1", "admin undo"} {
+		response, _ := plugin.ExecuteCommand(nil, &model.CommandArgs{Command: "/icebreaker " + command, UserId: "TeamAdmin", TeamId: "TestTeam", ChannelId: "TestChannel"})
+		assert.Equal(t, "Error: You need to be System Admin in order to use this command", response.Text, command)
+	}
+	assert.Equal(t, data.Questions, readData(t, plugin).Questions)
+
+	//managing single questions is still up to the configured role
+	response, _ := plugin.ExecuteCommand(nil, &model.CommandArgs{Command: "/icebreaker admin pending", UserId: "TeamAdmin", TeamId: "TestTeam", ChannelId: "TestChannel"})
+	assert.Equal(t, "There are no questions waiting for approval...", response.Text)
+}
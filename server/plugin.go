@@ -22,12 +22,46 @@ type Plugin struct {
 	// configuration is the active plugin configuration. Consult getConfiguration and
 	// setConfiguration for usage.
 	configuration *configuration
+
+	// displayNames caches the display names of the users shown in lists
+	displayNames displayNameCache
+
+	// schedulerStop and schedulerDone are used to stop the background job posting the scheduled icebreakers
+	schedulerStop chan struct{}
+	schedulerDone chan struct{}
+
+	// randomLock synchronizes access to the random generator, consult getRandom and setRandom for usage
+	randomLock sync.Mutex
+
+	// random chooses the users, questions and pairs. It is seeded unpredictably unless set by tests
+	random *rand.Rand
 }
 
 //Question stores information about a icebreaker question
 type Question struct {
+	//ID is a short identifier of the question that stays the same when other questions are added or removed
+	ID string `json:"id,omitempty"`
+
 	Creator  string `json:"creator"`
 	Question string `json:"question"`
+
+	//TeamID and ChannelID limit the question to a team or channel pool, questions without them are part of the global pool
+	TeamID    string `json:"team_id,omitempty"`
+	ChannelID string `json:"channel_id,omitempty"`
+
+	//Category and Tags allow to only ask questions of a certain kind, e.g. `/icebreaker ask work` or `/icebreaker ask #food`
+	Category string   `json:"category,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+
+	//Edits contains the previous versions of the question, the oldest one first
+	Edits []QuestionEdit `json:"edits,omitempty"`
+}
+
+// QuestionEdit stores a previous version of an edited question
+type QuestionEdit struct {
+	Question string `json:"question"`
+	EditorID string `json:"editor_id"`
+	EditedAt int64  `json:"edited_at"`
 }
 
 //IceBreakerData contains all data necessary to be stored for the Icebreaker Plugin
@@ -35,20 +69,37 @@ type IceBreakerData struct {
 	Questions     []Question `json:"Questions"`
 	LastUsers     []string   `json:"LastUsers"`
 	LastQuestions []Question `json:"LastQuestions"`
+	Schedules     []Schedule `json:"Schedules,omitempty"`
+
+	PendingQuestions []Question `json:"PendingQuestions,omitempty"`
+
+	UserPreferences   map[string]*UserPreferences `json:"UserPreferences,omitempty"`
+	OptInOnlyChannels []string                    `json:"OptInOnlyChannels,omitempty"`
+
+	WelcomeChannels      []string `json:"WelcomeChannels,omitempty"`
+	WorkingHoursChannels []string `json:"WorkingHoursChannels,omitempty"`
+
+	//ChannelStrategies maps channel IDs to the strategy choosing users and questions, if it differs from the configured one
+	ChannelStrategies map[string]string `json:"ChannelStrategies,omitempty"`
+
+	//UserRotations and QuestionRotations map channel IDs to the users and questions chosen there by the round-robin and least-recent strategies
+	UserRotations     map[string]*ChannelRotation `json:"UserRotations,omitempty"`
+	QuestionRotations map[string]*ChannelRotation `json:"QuestionRotations,omitempty"`
 }
 
-//LenHistory sets how many LastUsers/LastQuestions are stored to avoid asking the same users or same questions over and over
+//LenHistory sets how many LastUsers/LastQuestions are stored to avoid asking the same users or same questions over and over,
+//unless configured otherwise
 const LenHistory int = 50
 
 // OnActivate is invoked when the plugin is activated.
 func (p *Plugin) OnActivate() error {
-	//init the rand
-	rand.Seed(1337)
-
-	//add default set of questions in case the list is empty
-	data := p.ReadFromStorage()
-	if len(data.Questions) == 0 {
-		p.FillDefaultQuestions()
+	//upgrade the stored data to the latest schema version and add default set of questions in case the list is empty.
+	//Corrupted data must not stop the plugin from activating, admins need the commands to start over
+	if err := p.initData(); err != nil {
+		if errors.Cause(err) != errCorruptedData {
+			return err
+		}
+		p.API.LogError("Stored icebreaker data is corrupted, use `/icebreaker admin reset questions` to start over", "err", err.Error())
 	}
 
 	//register all our commands
@@ -67,6 +118,33 @@ func (p *Plugin) OnActivate() error {
 	}
 	p.botID = botID
 
+	//start posting the scheduled icebreakers
+	p.startScheduler()
+
+	return nil
+}
+
+// initData migrates the stored data and fills in the default questions if there are none
+func (p *Plugin) initData() error {
+	if err := p.runMigrations(); err != nil {
+		return errors.Wrap(err, "failed to migrate stored data")
+	}
+
+	data, err := p.ReadFromStorage()
+	if err != nil {
+		return err
+	}
+	if len(data.Questions) == 0 {
+		if err := p.FillDefaultQuestions(); err != nil {
+			return errors.Wrap(err, "failed to fill in the default questions")
+		}
+	}
+	return nil
+}
+
+// OnDeactivate is invoked when the plugin is deactivated.
+func (p *Plugin) OnDeactivate() error {
+	p.stopScheduler()
 	return nil
 }
 
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+// UserPreferences stores whether a user wants to be asked icebreaker questions.
+// Channel specific preferences take precedence over the global one
+type UserPreferences struct {
+	OptedOut         bool     `json:"opted_out,omitempty"`
+	OptedOutChannels []string `json:"opted_out_channels,omitempty"`
+	OptedInChannels  []string `json:"opted_in_channels,omitempty"`
+}
+
+// isAskable checks whether the user wants to be asked questions in the given channel.
+// Users are only asked in opt-in only channels if they explicitly opted in for that channel
+func (u *UserPreferences) isAskable(channelID string, optInOnly bool) bool {
+	if u == nil {
+		return !optInOnly
+	}
+	if containsString(u.OptedOutChannels, channelID) {
+		return false
+	}
+	if containsString(u.OptedInChannels, channelID) {
+		return true
+	}
+	return !u.OptedOut && !optInOnly
+}
+
+// isUserAskable checks the preferences of the given user for the given channel
+func (d *IceBreakerData) isUserAskable(userID string, channelID string) bool {
+	return d.UserPreferences[userID].isAskable(channelID, containsString(d.OptInOnlyChannels, channelID))
+}
+
+func containsString(values []string, value string) bool {
+	for _, currentValue := range values {
+		if currentValue == value {
+			return true
+		}
+	}
+	return false
+}
+
+func removeString(values []string, value string) []string {
+	result := []string{}
+	for _, currentValue := range values {
+		if currentValue != value {
+			result = append(result, currentValue)
+		}
+	}
+	return result
+}
+
+// parsePreferenceScope returns whether the given command text asks for a channel specific preference
+func parsePreferenceScope(text string) (bool, *model.CommandResponse) {
+	switch strings.TrimSpace(text) {
+	case "", scopeGlobal:
+		return false, nil
+	case scopeChannel:
+		return true, nil
+	default:
+		return false, &model.CommandResponse{
+			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+			Text:         fmt.Sprintf("Error: Unknown scope '%s', use one of: global, channel", strings.TrimSpace(text)),
+		}
+	}
+}
+
+func (p *Plugin) executeCommandIcebreakerOptOut(args *model.CommandArgs) *model.CommandResponse {
+	forChannel, errResponse := parsePreferenceScope(strings.TrimPrefix(args.Command, fmt.Sprintf("/%s", commandIcebreakerOptOut)))
+	if errResponse != nil {
+		return errResponse
+	}
+
+	err := p.updateUserPreferences(args.UserId, func(preferences *UserPreferences) {
+		if forChannel {
+			preferences.OptedInChannels = removeString(preferences.OptedInChannels, args.ChannelId)
+			if !containsString(preferences.OptedOutChannels, args.ChannelId) {
+				preferences.OptedOutChannels = append(preferences.OptedOutChannels, args.ChannelId)
+			}
+			return
+		}
+		preferences.OptedOut = true
+	})
+	if err != nil {
+		return p.getStorageErrorResponse(err)
+	}
+
+	text := "You will not be asked any icebreaker questions anymore. Use `/icebreaker optin` to change your mind."
+	if forChannel {
+		text = "You will not be asked any icebreaker questions in this channel anymore. Use `/icebreaker optin channel` to change your mind."
+	}
+	return &model.CommandResponse{
+		ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+		Text:         text,
+	}
+}
+
+func (p *Plugin) executeCommandIcebreakerOptIn(args *model.CommandArgs) *model.CommandResponse {
+	forChannel, errResponse := parsePreferenceScope(strings.TrimPrefix(args.Command, fmt.Sprintf("/%s", commandIcebreakerOptIn)))
+	if errResponse != nil {
+		return errResponse
+	}
+
+	err := p.updateUserPreferences(args.UserId, func(preferences *UserPreferences) {
+		if forChannel {
+			preferences.OptedOutChannels = removeString(preferences.OptedOutChannels, args.ChannelId)
+			if !containsString(preferences.OptedInChannels, args.ChannelId) {
+				preferences.OptedInChannels = append(preferences.OptedInChannels, args.ChannelId)
+			}
+			return
+		}
+		preferences.OptedOut = false
+	})
+	if err != nil {
+		return p.getStorageErrorResponse(err)
+	}
+
+	text := "You will be asked icebreaker questions again."
+	if forChannel {
+		text = "You will be asked icebreaker questions in this channel."
+	}
+	return &model.CommandResponse{
+		ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+		Text:         text,
+	}
+}
+
+// updateUserPreferences changes the preferences of the given user, users without any preferences left are removed
+func (p *Plugin) updateUserPreferences(userID string, update func(preferences *UserPreferences)) error {
+	return p.updateData(func(data *IceBreakerData) error {
+		if data.UserPreferences == nil {
+			data.UserPreferences = map[string]*UserPreferences{}
+		}
+		preferences, ok := data.UserPreferences[userID]
+		if !ok {
+			preferences = &UserPreferences{}
+		}
+		update(preferences)
+
+		if !preferences.OptedOut && len(preferences.OptedOutChannels) == 0 && len(preferences.OptedInChannels) == 0 {
+			delete(data.UserPreferences, userID)
+			return nil
+		}
+		data.UserPreferences[userID] = preferences
+		return nil
+	})
+}
+
+func (p *Plugin) executeCommandIcebreakerOptInOnly(args *model.CommandArgs) *model.CommandResponse {
+	mode := strings.TrimSpace(strings.TrimPrefix(args.Command, fmt.Sprintf("/%s", commandIcebreakerOptInOnly)))
+	if mode != "on" && mode != "off" {
+		return &model.CommandResponse{
+			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+			Text:         "Error: Please use `/icebreaker admin optinonly on` or `/icebreaker admin optinonly off`",
+		}
+	}
+
+	err := p.updateData(func(data *IceBreakerData) error {
+		data.OptInOnlyChannels = removeString(data.OptInOnlyChannels, args.ChannelId)
+		if mode == "on" {
+			data.OptInOnlyChannels = append(data.OptInOnlyChannels, args.ChannelId)
+		}
+		return nil
+	})
+	if err != nil {
+		return p.getStorageErrorResponse(err)
+	}
+
+	text := "This channel is opt-in only now: only users who used `/icebreaker optin channel` will be asked questions here."
+	if mode == "off" {
+		text = "Everyone in this channel can be asked questions again, unless they opted out."
+	}
+	return &model.CommandResponse{
+		ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+		Text:         text,
+	}
+}
+
+// executeCommandIcebreakerOptOuts shows how many users opted out, without telling who did
+func (p *Plugin) executeCommandIcebreakerOptOuts(args *model.CommandArgs) *model.CommandResponse {
+	data, err := p.ReadFromStorage()
+	if err != nil {
+		return p.getStorageErrorResponse(err)
+	}
+
+	optedOut := 0
+	optedOutOfChannel := 0
+	optedInToChannel := 0
+	for _, preferences := range data.UserPreferences {
+		if preferences.OptedOut {
+			optedOut++
+		}
+		if containsString(preferences.OptedOutChannels, args.ChannelId) {
+			optedOutOfChannel++
+		}
+		if containsString(preferences.OptedInChannels, args.ChannelId) {
+			optedInToChannel++
+		}
+	}
+
+	message := fmt.Sprintf("%d users opted out of all icebreaker questions, %d users opted out of this channel.", optedOut, optedOutOfChannel)
+	if containsString(data.OptInOnlyChannels, args.ChannelId) {
+		message = message + fmt.Sprintf(" This channel is opt-in only, %d users opted in.", optedInToChannel)
+	}
+	return &model.CommandResponse{
+		ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+		Text:         message,
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsAskable(t *testing.T) {
+	var noPreferences *UserPreferences
+	assert.True(t, noPreferences.isAskable("TestChannel", false))
+	assert.False(t, noPreferences.isAskable("TestChannel", true))
+
+	optedOut := &UserPreferences{OptedOut: true, OptedInChannels: []string{"OptInChannel"}}
+	assert.False(t, optedOut.isAskable("TestChannel", false))
+	assert.True(t, optedOut.isAskable("OptInChannel", false))
+	assert.True(t, optedOut.isAskable("OptInChannel", true))
+
+	optedOutOfChannel := &UserPreferences{OptedOutChannels: []string{"OptOutChannel"}}
+	assert.True(t, optedOutOfChannel.isAskable("TestChannel", false))
+	assert.False(t, optedOutOfChannel.isAskable("OptOutChannel", false))
+}
+
+func TestOptOutCommands(t *testing.T) {
+	api, _ := newFakeKVStore(nil)
+	api.On("GetUser", "AdminUser").Return(&model.User{Id: "AdminUser", Roles: model.SYSTEM_ADMIN_ROLE_ID}, nil)
+	plugin := &Plugin{}
+	plugin.SetAPI(api)
+
+	execute := func(userID string, command string) string {
+		response, _ := plugin.ExecuteCommand(nil, &model.CommandArgs{Command: command, UserId: userID, ChannelId: "TestChannel"})
+		return response.Text
+	}
+
+	execute("User1", "/icebreaker optout")
+	execute("User2", "/icebreaker optout channel")
+	execute("User3", "/icebreaker optout")
+	execute("User3", "/icebreaker optin")
+	execute("User4", "/icebreaker optin channel")
+	assert.Equal(t, "Error: Unknown scope 'team', use one of: global, channel", execute("User5", "/icebreaker optout team"))
+
+	data := readData(t, plugin)
+	assert.Equal(t, map[string]*UserPreferences{
+		"User1": &UserPreferences{OptedOut: true},
+		"User2": &UserPreferences{OptedOutChannels: []string{"TestChannel"}},
+		"User4": &UserPreferences{OptedInChannels: []string{"TestChannel"}},
+	}, data.UserPreferences)
+
+	assert.Equal(t, "1 users opted out of all icebreaker questions, 1 users opted out of this channel.", execute("AdminUser", "/icebreaker admin optouts"))
+	execute("AdminUser", "/icebreaker admin optinonly on")
+	assert.Equal(t, "1 users opted out of all icebreaker questions, 1 users opted out of this channel. This channel is opt-in only, 1 users opted in.", execute("AdminUser", "/icebreaker admin optouts"))
+	assert.Equal(t, []string{"TestChannel"}, readData(t, plugin).OptInOnlyChannels)
+}
+
+func TestGetRandomUser_preferences(t *testing.T) {
+	users := []*model.User{
+		&model.User{Id: "OptedOut", Username: "opted_out"},
+		&model.User{Id: "OptedIn", Username: "opted_in"},
+		&model.User{Id: "Default", Username: "default"},
+	}
+	setup := func(data *IceBreakerData) *Plugin {
+		reqBodyBytes := new(bytes.Buffer)
+		json.NewEncoder(reqBodyBytes).Encode(data)
+
+		api, _ := newFakeKVStore(map[string][]byte{KVKEY: reqBodyBytes.Bytes()})
+		api.On("GetUsersInChannel", "TestChannel", mock.AnythingOfType("string"), mock.AnythingOfType("int"), mock.AnythingOfType("int")).Return(users, nil)
+		api.On("GetUserStatus", mock.AnythingOfType("string")).Return(&model.Status{Status: "online"}, nil)
+		plugin := &Plugin{}
+		plugin.SetAPI(api)
+		return plugin
+	}
+	preferences := map[string]*UserPreferences{
+		"OptedOut": &UserPreferences{OptedOut: true},
+		"OptedIn":  &UserPreferences{OptedInChannels: []string{"TestChannel"}},
+	}
+
+	t.Run("Opted out users are not asked", func(t *testing.T) {
+		plugin := setup(&IceBreakerData{UserPreferences: preferences})
+		for i := 0; i < 20; i++ {
+			user, err := plugin.GetRandomUser("TestChannel", "")
+			require.Nil(t, err)
+			assert.NotEqual(t, "OptedOut", user.Id)
+		}
+	})
+	t.Run("Opt-in only channel", func(t *testing.T) {
+		plugin := setup(&IceBreakerData{UserPreferences: preferences, OptInOnlyChannels: []string{"TestChannel"}})
+		for i := 0; i < 20; i++ {
+			user, err := plugin.GetRandomUser("TestChannel", "")
+			require.Nil(t, err)
+			assert.Equal(t, "OptedIn", user.Id)
+		}
+	})
+}
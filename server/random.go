@@ -0,0 +1,61 @@
+package main
+
+import (
+	cryptorand "crypto/rand"
+	"encoding/binary"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// lockedSource makes a random source safe for concurrent use, as hooks and the scheduler pick users at the same time
+type lockedSource struct {
+	lock   sync.Mutex
+	source rand.Source
+}
+
+func (s *lockedSource) Int63() int64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.source.Int63()
+}
+
+func (s *lockedSource) Seed(seed int64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.source.Seed(seed)
+}
+
+// newRandom returns a random generator that is safe for concurrent use. The same seed always produces the same sequence
+func newRandom(seed int64) *rand.Rand {
+	return rand.New(&lockedSource{source: rand.NewSource(seed)})
+}
+
+// newRandomSeed returns an unpredictable seed, so restarts of the plugin do not repeat the same choices
+func newRandomSeed() int64 {
+	var seed [8]byte
+	if _, err := cryptorand.Read(seed[:]); err != nil {
+		return time.Now().UnixNano()
+	}
+	return int64(binary.LittleEndian.Uint64(seed[:]))
+}
+
+// getRandom returns the random generator of the plugin. Unless one has been set, e.g. with a fixed seed by tests,
+// a generator with an unpredictable seed is created on first use
+func (p *Plugin) getRandom() *rand.Rand {
+	p.randomLock.Lock()
+	defer p.randomLock.Unlock()
+
+	if p.random == nil {
+		p.random = newRandom(newRandomSeed())
+	}
+	return p.random
+}
+
+// setRandom replaces the random generator of the plugin
+func (p *Plugin) setRandom(random *rand.Rand) {
+	p.randomLock.Lock()
+	defer p.randomLock.Unlock()
+
+	p.random = random
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRandom_deterministic(t *testing.T) {
+	first, second := newRandom(42), newRandom(42)
+	for i := 0; i < 100; i++ {
+		assert.Equal(t, first.Int63(), second.Int63())
+	}
+
+	assert.NotEqual(t, newRandomSeed(), newRandomSeed())
+}
+
+func TestGetRandomUser_injectedRandom(t *testing.T) {
+	users := []*model.User{}
+	for i := 0; i < 10; i++ {
+		users = append(users, &model.User{Id: fmt.Sprintf("User%d", i), Username: fmt.Sprintf("user%d", i)})
+	}
+	dataBytes, err := json.Marshal(IceBreakerData{LastUsers: []string{"User3", "User5"}})
+	require.NoError(t, err)
+
+	askUsers := func(seed int64) []string {
+		api, _ := newFakeKVStore(map[string][]byte{KVKEY: dataBytes})
+		api.On("GetUsersInChannel", "TestChannel", mock.AnythingOfType("string"), mock.AnythingOfType("int"), mock.AnythingOfType("int")).Return(users, nil)
+		api.On("GetUserStatus", mock.AnythingOfType("string")).Return(&model.Status{Status: "online"}, nil)
+		plugin := &Plugin{}
+		plugin.SetAPI(api)
+		plugin.setRandom(newRandom(seed))
+
+		asked := []string{}
+		for i := 0; i < 20; i++ {
+			user, appErr := plugin.GetRandomUser("TestChannel", "")
+			require.Nil(t, appErr)
+			asked = append(asked, user.Id)
+		}
+		return asked
+	}
+
+	assert.Equal(t, askUsers(1337), askUsers(1337))
+	assert.NotEqual(t, askUsers(1337), askUsers(1338))
+}
+
+// countRepeats simulates asking the candidates over and over with the given strategy, remembering the
+// chosen ones like postIcebreaker does. It returns how often the same candidate was chosen twice in a row
+// and how often a candidate was chosen again within the given number of picks
+func countRepeats(t *testing.T, strategy SelectionStrategy, candidates []string, picks int, window int) (int, int) {
+	random := newRandom(1337)
+	items := []interface{}{}
+	for _, candidate := range candidates {
+		items = append(items, candidate)
+	}
+
+	history := []string{}
+	immediateRepeats, windowRepeats := 0, 0
+	for i := 0; i < picks; i++ {
+		picked, ok := pickWeighted(random, items, strategy.getWeights(candidates, history))
+		require.True(t, ok)
+		for index := len(history) - 1; index >= 0 && index >= len(history)-window; index-- {
+			if history[index] == picked {
+				windowRepeats++
+				if index == len(history)-1 {
+					immediateRepeats++
+				}
+				break
+			}
+		}
+		history = append(history, picked.(string))
+		if len(history) > LenHistory {
+			history = history[len(history)-LenHistory:]
+		}
+	}
+	return immediateRepeats, windowRepeats
+}
+
+func TestWeightedHistoryStrategy_reducesRepeats(t *testing.T) {
+	candidates := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+	picks := 4000
+	weighted := &weightedHistoryStrategy{newItemWeight: defaultNewItemWeight}
+
+	uniformImmediate, uniformWindow := countRepeats(t, &uniformStrategy{}, candidates, picks, 3)
+	weightedImmediate, weightedWindow := countRepeats(t, weighted, candidates, picks, 3)
+
+	//uniformly, the same candidate is chosen twice in a row in 1 of 8 picks, and again within 3 picks in about a third
+	assert.InDelta(t, float64(picks)/8, float64(uniformImmediate), float64(picks)/40)
+	assert.InDelta(t, float64(picks)*(1-7.0/8*7.0/8*7.0/8), float64(uniformWindow), float64(picks)/20)
+
+	//the history weighting has to make repeats much rarer
+	assert.Less(t, weightedImmediate*3, uniformImmediate, "weighted %d, uniform %d repeats in a row", weightedImmediate, uniformImmediate)
+	assert.Less(t, weightedWindow*2, uniformWindow, "weighted %d, uniform %d repeats within 3 picks", weightedWindow, uniformWindow)
+
+	//and with a short history new candidates are almost always preferred
+	weightedImmediate, _ = countRepeats(t, weighted, append(candidates, "i", "j"), 10, 10)
+	assert.Zero(t, weightedImmediate)
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	//scheduleCheckInterval sets how often the scheduler checks if a scheduled icebreaker is due
+	scheduleCheckInterval = 20 * time.Second
+
+	//scheduleLockPrefix is the prefix for the keys used to make sure that a scheduled icebreaker is only posted once,
+	//even if multiple server nodes are running the plugin
+	scheduleLockPrefix = "IceBreakerScheduleRun_"
+
+	//scheduleLockExpiry sets how long the lock for a single run of a schedule is kept in the KVStore
+	scheduleLockExpiry = int64(24 * 60 * 60)
+
+	//scheduleModePair is the mode of schedules that pair the users of the channel instead of asking a single user
+	scheduleModePair = "pair"
+)
+
+// Schedule stores a recurring icebreaker for a channel
+type Schedule struct {
+	ID        string `json:"id"`
+	TeamID    string `json:"team_id"`
+	ChannelID string `json:"channel_id"`
+	Creator   string `json:"creator"`
+	Cron      string `json:"cron"`
+	Mode      string `json:"mode,omitempty"`
+}
+
+// getDescription describes what the schedule posts
+func (s *Schedule) getDescription() string {
+	if s.Mode == scheduleModePair {
+		return "a pairing"
+	}
+	return "an icebreaker"
+}
+
+// cronField stores the allowed values of a single field of a cron expression
+type cronField map[int]bool
+
+// cronExpression is a parsed cron-like expression: minute, hour, day of month, month and day of week
+type cronExpression struct {
+	minute     cronField
+	hour       cronField
+	dayOfMonth cronField
+	month      cronField
+	dayOfWeek  cronField
+
+	//remember if the day fields are restricted, both are or-ed together like in the classic cron
+	dayOfMonthRestricted bool
+	dayOfWeekRestricted  bool
+}
+
+// parseCron parses a cron-like expression with the five fields `minute hour day-of-month month day-of-week`.
+// Every field supports `*`, single values, ranges (`1-5`), lists (`1,3,5`) and steps (`*/15`, `0-30/10`).
+func parseCron(expression string) (*cronExpression, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, errors.Errorf("expected 5 fields (minute hour day-of-month month day-of-week), got %d", len(fields))
+	}
+
+	limits := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 6}}
+	names := [5]string{"minute", "hour", "day-of-month", "month", "day-of-week"}
+	parsed := [5]cronField{}
+	for index, field := range fields {
+		values, err := parseCronField(field, limits[index][0], limits[index][1])
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid %s field '%s'", names[index], field)
+		}
+		parsed[index] = values
+	}
+
+	return &cronExpression{
+		minute:               parsed[0],
+		hour:                 parsed[1],
+		dayOfMonth:           parsed[2],
+		month:                parsed[3],
+		dayOfWeek:            parsed[4],
+		dayOfMonthRestricted: fields[2] != "*",
+		dayOfWeekRestricted:  fields[4] != "*",
+	}, nil
+}
+
+func parseCronField(field string, min int, max int) (cronField, error) {
+	values := cronField{}
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if stepIndex := strings.Index(part, "/"); stepIndex >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[stepIndex+1:])
+			if err != nil || step <= 0 {
+				return nil, errors.Errorf("invalid step in '%s'", part)
+			}
+			part = part[:stepIndex]
+		}
+
+		start, end := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			start, err = strconv.Atoi(bounds[0])
+			if err != nil {
+				return nil, errors.Errorf("invalid value '%s'", bounds[0])
+			}
+			end = start
+			if len(bounds) == 2 {
+				end, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					return nil, errors.Errorf("invalid value '%s'", bounds[1])
+				}
+			}
+		}
+		if start < min || end > max || start > end {
+			return nil, errors.Errorf("'%s' is out of range %d-%d", part, min, max)
+		}
+
+		for value := start; value <= end; value += step {
+			values[value] = true
+		}
+	}
+	return values, nil
+}
+
+// matches returns true if the given time (with minute precision) is part of the expression
+func (c *cronExpression) matches(t time.Time) bool {
+	if !c.minute[t.Minute()] || !c.hour[t.Hour()] || !c.month[int(t.Month())] {
+		return false
+	}
+
+	dayOfMonth := c.dayOfMonth[t.Day()]
+	dayOfWeek := c.dayOfWeek[int(t.Weekday())]
+	if c.dayOfMonthRestricted && c.dayOfWeekRestricted {
+		return dayOfMonth || dayOfWeek
+	}
+	return dayOfMonth && dayOfWeek
+}
+
+// startScheduler starts the background job that posts the scheduled icebreakers and welcomes new channel members
+func (p *Plugin) startScheduler() {
+	p.schedulerStop = make(chan struct{})
+	p.schedulerDone = make(chan struct{})
+
+	go func(stop <-chan struct{}, done chan<- struct{}) {
+		defer close(done)
+
+		ticker := time.NewTicker(scheduleCheckInterval)
+		defer ticker.Stop()
+
+		lastChecked := time.Time{}
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				p.runWelcomes(now)
+
+				//only check once per minute, the ticker is faster to not miss any minute
+				minute := now.UTC().Truncate(time.Minute)
+				if !minute.After(lastChecked) {
+					continue
+				}
+				lastChecked = minute
+				p.runSchedules(minute)
+			}
+		}
+	}(p.schedulerStop, p.schedulerDone)
+}
+
+// stopScheduler stops the background job and waits until it has finished
+func (p *Plugin) stopScheduler() {
+	if p.schedulerStop == nil {
+		return
+	}
+	close(p.schedulerStop)
+	<-p.schedulerDone
+	p.schedulerStop = nil
+	p.schedulerDone = nil
+}
+
+// runSchedules posts an icebreaker for every schedule that is due at the given minute
+func (p *Plugin) runSchedules(minute time.Time) {
+	data, err := p.ReadFromStorage()
+	if err != nil {
+		p.API.LogError("Failed to read the icebreaker schedules", "err", err.Error())
+		return
+	}
+	for _, schedule := range data.Schedules {
+		expression, err := parseCron(schedule.Cron)
+		if err != nil {
+			p.API.LogError("Invalid icebreaker schedule", "schedule", schedule.ID, "err", err.Error())
+			continue
+		}
+		if !expression.matches(minute) {
+			continue
+		}
+
+		//make sure that only a single server node posts the icebreaker for this schedule
+		lockKey := fmt.Sprintf("%s%s_%d", scheduleLockPrefix, schedule.ID, minute.Unix())
+		acquired, appErr := p.API.KVSetWithOptions(lockKey, []byte(schedule.ChannelID), model.PluginKVSetOptions{
+			Atomic:          true,
+			OldValue:        nil,
+			ExpireInSeconds: scheduleLockExpiry,
+		})
+		if appErr != nil {
+			p.API.LogError("Failed to lock icebreaker schedule", "schedule", schedule.ID, "err", appErr.Error())
+			continue
+		}
+		if !acquired {
+			continue
+		}
+
+		if schedule.Mode == scheduleModePair {
+			if _, err := p.pairUsers(schedule.TeamID, schedule.ChannelID); err != nil {
+				p.API.LogWarn("Failed to pair users for scheduled icebreaker", "schedule", schedule.ID, "channel", schedule.ChannelID, "err", err.Error())
+			}
+			continue
+		}
+		if err := p.askIcebreaker(schedule.TeamID, schedule.ChannelID, "", "", questionFilter{}); err != nil {
+			p.API.LogWarn("Failed to post scheduled icebreaker", "schedule", schedule.ID, "channel", schedule.ChannelID, "err", err.Error())
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/mattermost/mattermost-server/v5/plugin/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestParseCron(t *testing.T) {
+	t.Run("Invalid expressions", func(t *testing.T) {
+		for _, expression := range []string{"", "* * * *", "* * * * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 7", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+			_, err := parseCron(expression)
+			assert.Error(t, err, expression)
+		}
+	})
+	t.Run("Weekdays at nine", func(t *testing.T) {
+		expression, err := parseCron("0 9 * * 1-5")
+		assert.NoError(t, err)
+		assert.True(t, expression.matches(time.Date(2021, time.March, 1, 9, 0, 0, 0, time.UTC)))   //Monday
+		assert.True(t, expression.matches(time.Date(2021, time.March, 5, 9, 0, 0, 0, time.UTC)))   //Friday
+		assert.False(t, expression.matches(time.Date(2021, time.March, 6, 9, 0, 0, 0, time.UTC)))  //Saturday
+		assert.False(t, expression.matches(time.Date(2021, time.March, 1, 9, 1, 0, 0, time.UTC)))  //wrong minute
+		assert.False(t, expression.matches(time.Date(2021, time.March, 1, 10, 0, 0, 0, time.UTC))) //wrong hour
+	})
+	t.Run("Steps and lists", func(t *testing.T) {
+		expression, err := parseCron("*/15 8,16 * * *")
+		assert.NoError(t, err)
+		assert.True(t, expression.matches(time.Date(2021, time.March, 1, 8, 45, 0, 0, time.UTC)))
+		assert.True(t, expression.matches(time.Date(2021, time.March, 1, 16, 0, 0, 0, time.UTC)))
+		assert.False(t, expression.matches(time.Date(2021, time.March, 1, 8, 20, 0, 0, time.UTC)))
+		assert.False(t, expression.matches(time.Date(2021, time.March, 1, 12, 0, 0, 0, time.UTC)))
+	})
+	t.Run("Day of month or day of week", func(t *testing.T) {
+		expression, err := parseCron("0 12 1 * 5")
+		assert.NoError(t, err)
+		assert.True(t, expression.matches(time.Date(2021, time.March, 1, 12, 0, 0, 0, time.UTC)))  //1st of month
+		assert.True(t, expression.matches(time.Date(2021, time.March, 12, 12, 0, 0, 0, time.UTC))) //Friday
+		assert.False(t, expression.matches(time.Date(2021, time.March, 2, 12, 0, 0, 0, time.UTC)))
+	})
+}
+
+func TestRunSchedules(t *testing.T) {
+	icebreakerData := &IceBreakerData{
+		Questions: []Question{
+			Question{Creator: "TestUser", Question: "How do you do?"},
+		},
+		Schedules: []Schedule{
+			Schedule{ID: "daily", ChannelID: "DailyChannel", Cron: "0 9 * * *"},
+			Schedule{ID: "weekly", ChannelID: "WeeklyChannel", Cron: "0 9 * * 1"},
+		},
+	}
+	reqBodyBytes := new(bytes.Buffer)
+	json.NewEncoder(reqBodyBytes).Encode(icebreakerData)
+
+	users := []*model.User{
+		&model.User{Id: "SuccessUser", Username: "success_user"},
+	}
+
+	t.Run("Only due schedules are posted", func(t *testing.T) {
+		plugin := &Plugin{}
+		api := &plugintest.API{}
+		api.On("KVGet", mock.AnythingOfType("string")).Return(reqBodyBytes.Bytes(), nil)
+		api.On("KVCompareAndSet", mock.AnythingOfType("string"), mock.AnythingOfType("[]uint8"), mock.AnythingOfType("[]uint8")).Return(true, nil)
+		api.On("KVSetWithOptions", "IceBreakerScheduleRun_daily_1614675600", []byte("DailyChannel"), mock.AnythingOfType("model.PluginKVSetOptions")).Return(true, nil)
+		api.On("GetUsersInChannel", "DailyChannel", mock.AnythingOfType("string"), mock.AnythingOfType("int"), mock.AnythingOfType("int")).Return(users, nil)
+		api.On("GetUserStatus", "SuccessUser").Return(&model.Status{Status: "online"}, nil)
+		api.On("CreatePost", matchIcebreakerPost("DailyChannel", "", "Hey @success_user! How do you do?")).Return(nil, nil)
+		plugin.SetAPI(api)
+
+		plugin.runSchedules(time.Date(2021, time.March, 2, 9, 0, 0, 0, time.UTC)) //Tuesday
+		api.AssertNumberOfCalls(t, "CreatePost", 1)
+	})
+	t.Run("Schedule already posted by another node", func(t *testing.T) {
+		plugin := &Plugin{}
+		api := &plugintest.API{}
+		api.On("KVGet", mock.AnythingOfType("string")).Return(reqBodyBytes.Bytes(), nil)
+		api.On("KVSetWithOptions", mock.AnythingOfType("string"), mock.AnythingOfType("[]uint8"), mock.AnythingOfType("model.PluginKVSetOptions")).Return(false, nil)
+		plugin.SetAPI(api)
+
+		plugin.runSchedules(time.Date(2021, time.March, 1, 9, 0, 0, 0, time.UTC)) //Monday
+		api.AssertNotCalled(t, "CreatePost", mock.Anything)
+		api.AssertNumberOfCalls(t, "KVSetWithOptions", 2)
+	})
+}
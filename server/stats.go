@@ -0,0 +1,230 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	//questionStatsKey is the key of the statistics of all questions, stored apart from the questions
+	//so counting does not compete with changes to the questions
+	questionStatsKey = "IceBreakerQuestionStats"
+
+	//sortPopular sorts questions by how often they have been answered rather than passed on
+	sortPopular = "popular"
+
+	//statsListLength is the number of most and least popular questions shown by `/icebreaker stats`
+	statsListLength = 5
+)
+
+// QuestionStats counts how a question has been received
+type QuestionStats struct {
+	Asked    int `json:"asked"`
+	Answered int `json:"answered"`
+
+	//Passed counts how often the asked user passed on the question or someone asked for another question instead
+	Passed int `json:"passed"`
+
+	LastAskedAt int64 `json:"last_asked_at,omitempty"`
+
+	//Upvotes and Downvotes count the thumbs up and thumbs down reactions to the icebreaker posts asking the question
+	Upvotes   int `json:"upvotes,omitempty"`
+	Downvotes int `json:"downvotes,omitempty"`
+
+	//FlaggedAt is set when the question got too many down votes and needs to be reviewed by an admin
+	FlaggedAt int64 `json:"flagged_at,omitempty"`
+}
+
+// getPopularity scores how much the question is liked, answers count in favor and passes against it
+func (s *QuestionStats) getPopularity() int {
+	if s == nil {
+		return 0
+	}
+	return s.Answered - s.Passed
+}
+
+func (s *QuestionStats) String() string {
+	if s == nil {
+		return "never asked"
+	}
+	text := fmt.Sprintf("asked %d times, answered %d times, passed %d times", s.Asked, s.Answered, s.Passed)
+	if s.LastAskedAt != 0 {
+		text = text + ", last asked " + formatTime(s.LastAskedAt)
+	}
+	if s.Upvotes != 0 || s.Downvotes != 0 {
+		text = text + fmt.Sprintf(", %d up and %d down votes", s.Upvotes, s.Downvotes)
+	}
+	if s.FlaggedAt != 0 {
+		text = text + ", flagged for review"
+	}
+	return text
+}
+
+// updateQuestionStats changes the statistics of the question with the given ID. Questions without an ID,
+// e.g. ones asked before the IDs were introduced, are not counted
+func (p *Plugin) updateQuestionStats(questionID string, update func(stats *QuestionStats)) error {
+	if questionID == "" {
+		return nil
+	}
+	return p.updateKey(questionStatsKey, func(oldValue []byte) ([]byte, error) {
+		allStats, err := decodeQuestionStats(oldValue)
+		if err != nil {
+			return nil, err
+		}
+		stats := allStats[questionID]
+		if stats == nil {
+			stats = &QuestionStats{}
+			allStats[questionID] = stats
+		}
+		update(stats)
+		return json.Marshal(allStats)
+	})
+}
+
+// countQuestion updates the statistics of the question, failing to count must not fail the action that is counted
+func (p *Plugin) countQuestion(questionID string, update func(stats *QuestionStats)) {
+	if err := p.updateQuestionStats(questionID, update); err != nil {
+		p.API.LogError("Failed to update the icebreaker question statistics", "question", questionID, "err", err.Error())
+	}
+}
+
+func (p *Plugin) countQuestionAsked(questionID string) {
+	now := model.GetMillis()
+	p.countQuestion(questionID, func(stats *QuestionStats) {
+		stats.Asked++
+		stats.LastAskedAt = now
+	})
+}
+
+func (p *Plugin) countQuestionAnswered(questionID string) {
+	p.countQuestion(questionID, func(stats *QuestionStats) {
+		stats.Answered++
+	})
+}
+
+func (p *Plugin) countQuestionPassed(questionID string) {
+	p.countQuestion(questionID, func(stats *QuestionStats) {
+		stats.Passed++
+	})
+}
+
+// getQuestionStats returns the statistics of all questions that have been asked, by question ID
+func (p *Plugin) getQuestionStats() (map[string]*QuestionStats, error) {
+	kvData, appErr := p.API.KVGet(questionStatsKey)
+	if appErr != nil {
+		return nil, errors.Wrapf(appErr, "failed to read %s", questionStatsKey)
+	}
+	return decodeQuestionStats(kvData)
+}
+
+func decodeQuestionStats(kvData []byte) (map[string]*QuestionStats, error) {
+	allStats := map[string]*QuestionStats{}
+	if len(kvData) == 0 {
+		return allStats, nil
+	}
+	if err := json.Unmarshal(kvData, &allStats); err != nil {
+		return nil, errors.Wrap(err, "failed to decode the question statistics")
+	}
+	return allStats, nil
+}
+
+// sortByPopularity sorts the questions from the most to the least popular one. Questions that are equally
+// popular are sorted by how often they have been asked, so new questions come last
+func sortByPopularity(questions []Question, allStats map[string]*QuestionStats) {
+	sort.SliceStable(questions, func(i, j int) bool {
+		statsI, statsJ := allStats[questions[i].ID], allStats[questions[j].ID]
+		if statsI.getPopularity() != statsJ.getPopularity() {
+			return statsI.getPopularity() > statsJ.getPopularity()
+		}
+		return getAskedCount(statsI) > getAskedCount(statsJ)
+	})
+}
+
+func getAskedCount(stats *QuestionStats) int {
+	if stats == nil {
+		return 0
+	}
+	return stats.Asked
+}
+
+func (p *Plugin) executeCommandIcebreakerStats(args *model.CommandArgs) *model.CommandResponse {
+	data, err := p.ReadFromStorage()
+	if err != nil {
+		return p.getStorageErrorResponse(err)
+	}
+	allStats, err := p.getQuestionStats()
+	if err != nil {
+		return p.getStorageErrorResponse(err)
+	}
+
+	questions := getQuestionsForChannel(data.Questions, args.TeamId, args.ChannelId)
+	if id := strings.TrimSpace(strings.TrimPrefix(args.Command, fmt.Sprintf("/%s", commandIcebreakerStats))); id != "" {
+		index, errResponse := getQuestionIndex(id, questions)
+		if errResponse != nil {
+			return errResponse
+		}
+		question := questions[index]
+		return &model.CommandResponse{
+			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+			Text:         fmt.Sprintf("`%s`\t%s%s\n%s", question.ID, question.Question, question.getLabels(), allStats[question.ID]),
+		}
+	}
+
+	if len(questions) == 0 {
+		return &model.CommandResponse{
+			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+			Text:         "There are no questions...",
+		}
+	}
+
+	total := QuestionStats{}
+	asked := []Question{}
+	for _, question := range questions {
+		if stats := allStats[question.ID]; stats != nil && stats.Asked > 0 {
+			total.Asked += stats.Asked
+			total.Answered += stats.Answered
+			total.Passed += stats.Passed
+			asked = append(asked, question)
+		}
+	}
+	message := fmt.Sprintf("Question statistics: %d questions, %d of them never asked. Questions have been asked %d times, answered %d times and passed %d times.\n",
+		len(questions), len(questions)-len(asked), total.Asked, total.Answered, total.Passed)
+	if len(asked) == 0 {
+		return &model.CommandResponse{
+			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+			Text:         message,
+		}
+	}
+
+	sortByPopularity(asked, allStats)
+	listLength := statsListLength
+	if listLength > len(asked) {
+		listLength = len(asked)
+	}
+	message = message + "Most popular:\n"
+	for _, question := range asked[:listLength] {
+		message = message + fmt.Sprintf("`%s`\t%s:\t%s\n", question.ID, question.Question, allStats[question.ID])
+	}
+	if len(asked) > statsListLength {
+		if listLength > len(asked)-statsListLength {
+			listLength = len(asked) - statsListLength
+		}
+		message = message + "Least popular:\n"
+		for index := len(asked) - 1; index >= len(asked)-listLength; index-- {
+			question := asked[index]
+			message = message + fmt.Sprintf("`%s`\t%s:\t%s\n", question.ID, question.Question, allStats[question.ID])
+		}
+	}
+	message = message + "Use `/icebreaker list --sort popular` to see all questions by popularity.\n"
+
+	return &model.CommandResponse{
+		ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+		Username:     "icebreaker",
+		Text:         message,
+	}
+}
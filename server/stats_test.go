@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func readQuestionStats(t *testing.T, store *fakeKVStore) map[string]*QuestionStats {
+	allStats, err := decodeQuestionStats(store.get(questionStatsKey))
+	require.NoError(t, err)
+	return allStats
+}
+
+func TestQuestionStats_counting(t *testing.T) {
+	icebreakerData := IceBreakerData{
+		Questions: []Question{Question{ID: "q1", Creator: "TestUser", Question: "How do you do?"}},
+	}
+	dataBytes, err := json.Marshal(icebreakerData)
+	require.NoError(t, err)
+
+	users := []*model.User{
+		&model.User{Id: "AskedUser", Username: "asked_user"},
+		&model.User{Id: "OtherUser", Username: "other_user"},
+	}
+	api, store := newFakeKVStore(map[string][]byte{KVKEY: dataBytes})
+	api.On("GetUser", "AskedUser").Return(users[0], nil)
+	api.On("GetUsersInChannel", "TestChannel", mock.AnythingOfType("string"), mock.AnythingOfType("int"), mock.AnythingOfType("int")).Return(users, nil)
+	api.On("GetUserStatus", mock.AnythingOfType("string")).Return(&model.Status{Status: "online"}, nil)
+	api.On("CreatePost", mock.AnythingOfType("*model.Post")).Return(&model.Post{Id: "IcebreakerPost", ChannelId: "TestChannel"}, nil)
+	api.On("GetPost", "IcebreakerPost").Return(&model.Post{
+		Id:        "IcebreakerPost",
+		UserId:    "BotUser",
+		ChannelId: "TestChannel",
+		Message:   "Hey @asked_user! How do you do?",
+		Props:     model.StringInterface{askedUserProp: "AskedUser", questionIDProp: "q1"},
+	}, nil)
+	api.On("GetChannel", "TestChannel").Return(&model.Channel{Id: "TestChannel", TeamId: "TestTeam"}, nil)
+	api.On("HasPermissionToChannel", "AskedUser", "TestChannel", model.PERMISSION_READ_CHANNEL).Return(true)
+	api.On("UpdatePost", mock.AnythingOfType("*model.Post")).Return(nil, nil)
+	plugin := &Plugin{botID: "BotUser"}
+	plugin.SetAPI(api)
+
+	before := model.GetMillis()
+	require.NoError(t, plugin.postIcebreaker("TestTeam", "TestChannel", "", users[0], &icebreakerData.Questions[0], questionFilter{}))
+	stats := readQuestionStats(t, store)["q1"]
+	require.NotNil(t, stats)
+	assert.Equal(t, 1, stats.Asked)
+	assert.True(t, stats.LastAskedAt >= before)
+
+	plugin.MessageHasBeenPosted(nil, &model.Post{Id: "AnswerPost", UserId: "AskedUser", ChannelId: "TestChannel", RootId: "IcebreakerPost", Message: "Fine, thanks!", CreateAt: model.GetMillis()})
+	assert.Equal(t, 1, readQuestionStats(t, store)["q1"].Answered)
+
+	context := &icebreakerContext{ChannelID: "TestChannel", UserID: "AskedUser", QuestionID: "q1", Question: "How do you do?"}
+	response := sendAction(t, plugin, "AskedUser", actionPass, context)
+	require.Equal(t, "", response.EphemeralText)
+	stats = readQuestionStats(t, store)["q1"]
+	assert.Equal(t, 1, stats.Passed)
+	assert.Equal(t, 2, stats.Asked) //the question has been asked to someone else
+	assert.Equal(t, 1, stats.Answered)
+}
+
+func TestStatsCommand(t *testing.T) {
+	questions := []Question{}
+	allStats := map[string]*QuestionStats{}
+	for i := 1; i <= 8; i++ {
+		id := fmt.Sprintf("q%d", i)
+		questions = append(questions, Question{ID: id, Creator: "TestUser", Question: fmt.Sprintf("Question %d?", i)})
+		if i <= 7 {
+			allStats[id] = &QuestionStats{Asked: 4, Answered: i % 4, Passed: 4 - i%4}
+		}
+	}
+	dataBytes, err := json.Marshal(IceBreakerData{Questions: questions})
+	require.NoError(t, err)
+	statsBytes, err := json.Marshal(allStats)
+	require.NoError(t, err)
+
+	api, _ := newFakeKVStore(map[string][]byte{KVKEY: dataBytes, questionStatsKey: statsBytes})
+	api.On("GetUser", "TestUser").Return(&model.User{Id: "TestUser", Username: "test_user"}, nil)
+	plugin := &Plugin{}
+	plugin.SetAPI(api)
+	execute := func(command string) string {
+		response, _ := plugin.ExecuteCommand(nil, &model.CommandArgs{Command: command, UserId: "TestUser"})
+		return response.Text
+	}
+
+	assert.Equal(t, "Question statistics: 8 questions, 1 of them never asked. Questions have been asked 28 times, answered 12 times and passed 16 times.\n"+
+		"Most popular:\n"+
+		"`q3`\tQuestion 3?:\tasked 4 times, answered 3 times, passed 1 times\n"+
+		"`q7`\tQuestion 7?:\tasked 4 times, answered 3 times, passed 1 times\n"+
+		"`q2`\tQuestion 2?:\tasked 4 times, answered 2 times, passed 2 times\n"+
+		"`q6`\tQuestion 6?:\tasked 4 times, answered 2 times, passed 2 times\n"+
+		"`q1`\tQuestion 1?:\tasked 4 times, answered 1 times, passed 3 times\n"+
+		"Least popular:\n"+
+		"`q4`\tQuestion 4?:\tasked 4 times, answered 0 times, passed 4 times\n"+
+		"`q5`\tQuestion 5?:\tasked 4 times, answered 1 times, passed 3 times\n"+
+		"Use `/icebreaker list --sort popular` to see all questions by popularity.\n", execute("/icebreaker stats"))
+
+	assert.Equal(t, "`q8`\tQuestion 8?\nnever asked", execute("/icebreaker stats q8"))
+	assert.Equal(t, "Error: There is no question with the ID q9", execute("/icebreaker stats q9"))
+
+	list := execute("/icebreaker list --sort popular")
+	assert.Equal(t, "Questions:\n"+
+		"`q3`\t@test_user:\tQuestion 3?\t(asked 4 times, answered 3 times, passed 1 times)\n"+
+		"`q7`\t@test_user:\tQuestion 7?\t(asked 4 times, answered 3 times, passed 1 times)\n"+
+		"`q2`\t@test_user:\tQuestion 2?\t(asked 4 times, answered 2 times, passed 2 times)\n"+
+		"`q6`\t@test_user:\tQuestion 6?\t(asked 4 times, answered 2 times, passed 2 times)\n"+
+		"`q8`\t@test_user:\tQuestion 8?\t(never asked)\n"+
+		"`q1`\t@test_user:\tQuestion 1?\t(asked 4 times, answered 1 times, passed 3 times)\n"+
+		"`q5`\t@test_user:\tQuestion 5?\t(asked 4 times, answered 1 times, passed 3 times)\n"+
+		"`q4`\t@test_user:\tQuestion 4?\t(asked 4 times, answered 0 times, passed 4 times)\n", list)
+	assert.Equal(t, "Error: Unknown sort order 'newest', use: popular", execute("/icebreaker list --sort newest"))
+}
@@ -2,106 +2,351 @@ package main
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/pkg/errors"
 )
 
 const (
 	//KVKEY is the key used for storing the data in the KVStorage
-	//KVKEY = "IceBreakerData" //this is the old key which stored the questions in a way more complex way and allowed for proposing and accepting questions by users
 	KVKEY = "IceBreakerData_v2"
+
+	//KVKEYLegacy is the old key which stored the questions in a way more complex way and allowed for proposing and accepting questions by users.
+	//Its data is imported by migrateLegacyData
+	KVKEYLegacy = "IceBreakerData"
+
+	//KVKEYSchemaVersion is the key used for storing the schema version of the data in the KVStorage
+	KVKEYSchemaVersion = "IceBreakerSchemaVersion"
+
+	//quarantineKeyPrefix is the prefix of the keys corrupted data is backed up to
+	quarantineKeyPrefix = "IceBreakerData_corrupted_"
+
+	//maxUpdateRetries sets how often an update is retried when the data has been changed concurrently
+	maxUpdateRetries = 20
+)
+
+var (
+	// errSkipUpdate can be returned by the update function given to updateData to leave the stored data untouched
+	errSkipUpdate = errors.New("skip update")
+
+	// errCorruptedData is returned when the stored data cannot be decoded
+	errCorruptedData = errors.New("the stored data is corrupted")
 )
 
+// migration upgrades the stored data by a single schema version
+type migration func(p *Plugin) error
+
+// migrations contains all migrations in order: migrations[i] upgrades the data from schema version i to i+1.
+// Never change or remove an existing migration, only append new ones
+var migrations = []migration{
+	migrateLegacyData,
+	migrateQuestionCategories,
+	migrateQuestionIDs,
+}
+
+// legacyIceBreakerData is the format of the data stored under KVKEYLegacy.
+// Questions were proposed and approved per team and channel, mapped as [TeamID][ChannelID]
+type legacyIceBreakerData struct {
+	ProposedQuestions map[string]map[string][]Question `json:"ProposedQuestions"`
+	ApprovedQuestions map[string]map[string][]Question `json:"ApprovedQuestions"`
+}
+
+// getSchemaVersion returns the current schema version of the data in the KVStore, 0 if no version has been stored yet
+func (p *Plugin) getSchemaVersion() (int, error) {
+	kvData, appErr := p.API.KVGet(KVKEYSchemaVersion)
+	if appErr != nil {
+		return 0, errors.Wrap(appErr, "failed to read schema version")
+	}
+	if kvData == nil {
+		return 0, nil
+	}
+	version, err := strconv.Atoi(string(kvData))
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid schema version '%s'", string(kvData))
+	}
+	return version, nil
+}
+
+// runMigrations upgrades the stored data to the latest schema version
+func (p *Plugin) runMigrations() error {
+	version, err := p.getSchemaVersion()
+	if err != nil {
+		return err
+	}
+	if version > len(migrations) {
+		return errors.Errorf("stored schema version %d is newer than the latest known version %d", version, len(migrations))
+	}
+
+	for ; version < len(migrations); version++ {
+		if err := migrations[version](p); err != nil {
+			return errors.Wrapf(err, "failed to migrate from schema version %d to %d", version, version+1)
+		}
+		if appErr := p.API.KVSet(KVKEYSchemaVersion, []byte(strconv.Itoa(version+1))); appErr != nil {
+			return errors.Wrapf(appErr, "failed to store schema version %d", version+1)
+		}
+		p.API.LogInfo("Migrated icebreaker data", "version", version+1)
+	}
+	return nil
+}
+
+// migrateLegacyData imports the approved questions stored under KVKEYLegacy as channel questions.
+// Proposed questions have never been approved and are therefore not imported
+func migrateLegacyData(p *Plugin) error {
+	kvData, appErr := p.API.KVGet(KVKEYLegacy)
+	if appErr != nil {
+		return errors.Wrap(appErr, "failed to read legacy data")
+	}
+	if kvData == nil {
+		return nil
+	}
+	legacyData := legacyIceBreakerData{}
+	if err := json.Unmarshal(kvData, &legacyData); err != nil {
+		return errors.Wrap(err, "failed to decode legacy data")
+	}
+
+	numImported := 0
+	err := p.updateData(func(data *IceBreakerData) error {
+		numImported = 0
+		for teamID, channels := range legacyData.ApprovedQuestions {
+			for channelID, questions := range channels {
+				for _, question := range questions {
+					question.TeamID = teamID
+					question.ChannelID = channelID
+					if containsQuestion(data.Questions, &question) {
+						continue
+					}
+					data.Questions = append(data.Questions, question)
+					numImported++
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	p.API.LogInfo("Imported legacy icebreaker questions", "imported", numImported)
+	return nil
+}
+
+// migrateQuestionCategories puts all stored questions without a category into the default category
+func migrateQuestionCategories(p *Plugin) error {
+	return p.updateData(func(data *IceBreakerData) error {
+		for index := range data.Questions {
+			if data.Questions[index].Category == "" {
+				data.Questions[index].Category = defaultCategory
+			}
+		}
+		return nil
+	})
+}
+
+// migrateQuestionIDs gives all stored questions an ID. The recently asked questions get the ID of the
+// matching question, so the history is kept
+func migrateQuestionIDs(p *Plugin) error {
+	return p.updateData(func(data *IceBreakerData) error {
+		assignQuestionIDs(data)
+		for index := range data.LastQuestions {
+			lastQuestion := &data.LastQuestions[index]
+			for _, question := range data.Questions {
+				if question.Question == lastQuestion.Question && question.TeamID == lastQuestion.TeamID && question.ChannelID == lastQuestion.ChannelID {
+					lastQuestion.ID = question.ID
+					break
+				}
+			}
+		}
+		return nil
+	})
+}
+
 func getDefaultQuestions() []Question {
 	//Curated some of the mild questions from https://teambuildinghero.com/icebreaker-questions/
 	DefaultQuestions := []Question{
 		//mild questions
-		Question{Creator: "Icebreaker", Question: "What did you eat for breakfast?"},
-		Question{Creator: "Icebreaker", Question: "What is your role in the company?"},
-		Question{Creator: "Icebreaker", Question: "What are your favourite pizza toppings?"},
-		Question{Creator: "Icebreaker", Question: "What languages do you speak?"},
-		Question{Creator: "Icebreaker", Question: "Where were you born?"},
-		Question{Creator: "Icebreaker", Question: "Which season is your favourite?"},
-		Question{Creator: "Icebreaker", Question: "Do you play any sports?"},
-		Question{Creator: "Icebreaker", Question: "Do you have any pets?"},
-		Question{Creator: "Icebreaker", Question: "Are you allergic to anything?"},
-		Question{Creator: "Icebreaker", Question: "Do you have any Christmas traditions?"},
-		Question{Creator: "Icebreaker", Question: "Do you play any musical instruments?"},
-		Question{Creator: "Icebreaker", Question: "What was the last movie you attended?"},
-		Question{Creator: "Icebreaker", Question: "Where did you grow up?"},
-		Question{Creator: "Icebreaker", Question: "Have you ever met a celebrity?"},
-		Question{Creator: "Icebreaker", Question: "Do you have any siblings?"},
-		Question{Creator: "Icebreaker", Question: "When you were a kid, what did you want to be when you grow up?"},
-		Question{Creator: "Icebreaker", Question: "Have you ever broken a bone?"},
-		Question{Creator: "Icebreaker", Question: "How many pairs of shoes do you own?"},
-		Question{Creator: "Icebreaker", Question: "What is the farthest distance you have driven?"},
+		Question{Creator: "Icebreaker", Question: "What did you eat for breakfast?", Category: "mild"},
+		Question{Creator: "Icebreaker", Question: "What is your role in the company?", Category: "mild"},
+		Question{Creator: "Icebreaker", Question: "What are your favourite pizza toppings?", Category: "mild"},
+		Question{Creator: "Icebreaker", Question: "What languages do you speak?", Category: "mild"},
+		Question{Creator: "Icebreaker", Question: "Where were you born?", Category: "mild"},
+		Question{Creator: "Icebreaker", Question: "Which season is your favourite?", Category: "mild"},
+		Question{Creator: "Icebreaker", Question: "Do you play any sports?", Category: "mild"},
+		Question{Creator: "Icebreaker", Question: "Do you have any pets?", Category: "mild"},
+		Question{Creator: "Icebreaker", Question: "Are you allergic to anything?", Category: "mild"},
+		Question{Creator: "Icebreaker", Question: "Do you have any Christmas traditions?", Category: "mild"},
+		Question{Creator: "Icebreaker", Question: "Do you play any musical instruments?", Category: "mild"},
+		Question{Creator: "Icebreaker", Question: "What was the last movie you attended?", Category: "mild"},
+		Question{Creator: "Icebreaker", Question: "Where did you grow up?", Category: "mild"},
+		Question{Creator: "Icebreaker", Question: "Have you ever met a celebrity?", Category: "mild"},
+		Question{Creator: "Icebreaker", Question: "Do you have any siblings?", Category: "mild"},
+		Question{Creator: "Icebreaker", Question: "When you were a kid, what did you want to be when you grow up?", Category: "mild"},
+		Question{Creator: "Icebreaker", Question: "Have you ever broken a bone?", Category: "mild"},
+		Question{Creator: "Icebreaker", Question: "How many pairs of shoes do you own?", Category: "mild"},
+		Question{Creator: "Icebreaker", Question: "What is the farthest distance you have driven?", Category: "mild"},
 
 		//medium questions
-		Question{Creator: "Icebreaker", Question: "What’s your favourite show?"},
-		Question{Creator: "Icebreaker", Question: "What book would you recommend other people read?"},
-		Question{Creator: "Icebreaker", Question: "Would you prefer luxury beach vacations or backpacking?"},
-		Question{Creator: "Icebreaker", Question: "What was your first job?"},
-		Question{Creator: "Icebreaker", Question: "What is something you are looking forward to?"},
-		Question{Creator: "Icebreaker", Question: "Has your taste in music changed in the last 10 years?"},
-		Question{Creator: "Icebreaker", Question: "What is something you do that you don’t like to do?"},
-		Question{Creator: "Icebreaker", Question: "What is your favourite fast food restaurant?"},
-		Question{Creator: "Icebreaker", Question: "Who is the greatest cook you know?"},
-		Question{Creator: "Icebreaker", Question: "Do you have a favourite sports team?"},
-		Question{Creator: "Icebreaker", Question: "What game show do you think you could win?"},
-		Question{Creator: "Icebreaker", Question: "If you had to move to another country, where would you move?"},
-		Question{Creator: "Icebreaker", Question: "What is your favorite Christmas movie?"},
-		Question{Creator: "Icebreaker", Question: "What food can you not stand?"},
-		Question{Creator: "Icebreaker", Question: "What is the greatest gift you have ever received?"},
-		Question{Creator: "Icebreaker", Question: "Are you a dog or cat person?"},
-		Question{Creator: "Icebreaker", Question: "Who is your least favorite actor?"},
-		Question{Creator: "Icebreaker", Question: "Do you work better in the morning or at night?"},
-		Question{Creator: "Icebreaker", Question: "What is something you would like to learn?"},
-		Question{Creator: "Icebreaker", Question: "Which is typically better, the book or the movie?"},
-		Question{Creator: "Icebreaker", Question: "Do you have a favourite (childhood) video game?"},
-		Question{Creator: "Icebreaker", Question: "What is one place you have always wanted to visit?"},
-		Question{Creator: "Icebreaker", Question: "If you could drive any car, what car would you drive?"},
-		Question{Creator: "Icebreaker", Question: "Do you enjoy rollercoasters?"},
-		Question{Creator: "Icebreaker", Question: "What is the best TV show of all time?"},
-		Question{Creator: "Icebreaker", Question: "If you could act in any movie, what movie would you be in?"},
-		Question{Creator: "Icebreaker", Question: "Say you were given a kitten, what would you name him/her?"},
-		Question{Creator: "Icebreaker", Question: "If you could collect anything, what would it be?"},
-		Question{Creator: "Icebreaker", Question: "Do you prefer team or individual sports?"},
-		Question{Creator: "Icebreaker", Question: "What is the best concert you have ever been to?"},
-		Question{Creator: "Icebreaker", Question: "If you could start any business in the world, what would you start?"},
-		Question{Creator: "Icebreaker", Question: "Name a piece of technology you wish existed."},
+		Question{Creator: "Icebreaker", Question: "What’s your favourite show?", Category: "medium"},
+		Question{Creator: "Icebreaker", Question: "What book would you recommend other people read?", Category: "medium"},
+		Question{Creator: "Icebreaker", Question: "Would you prefer luxury beach vacations or backpacking?", Category: "medium"},
+		Question{Creator: "Icebreaker", Question: "What was your first job?", Category: "medium"},
+		Question{Creator: "Icebreaker", Question: "What is something you are looking forward to?", Category: "medium"},
+		Question{Creator: "Icebreaker", Question: "Has your taste in music changed in the last 10 years?", Category: "medium"},
+		Question{Creator: "Icebreaker", Question: "What is something you do that you don’t like to do?", Category: "medium"},
+		Question{Creator: "Icebreaker", Question: "What is your favourite fast food restaurant?", Category: "medium"},
+		Question{Creator: "Icebreaker", Question: "Who is the greatest cook you know?", Category: "medium"},
+		Question{Creator: "Icebreaker", Question: "Do you have a favourite sports team?", Category: "medium"},
+		Question{Creator: "Icebreaker", Question: "What game show do you think you could win?", Category: "medium"},
+		Question{Creator: "Icebreaker", Question: "If you had to move to another country, where would you move?", Category: "medium"},
+		Question{Creator: "Icebreaker", Question: "What is your favorite Christmas movie?", Category: "medium"},
+		Question{Creator: "Icebreaker", Question: "What food can you not stand?", Category: "medium"},
+		Question{Creator: "Icebreaker", Question: "What is the greatest gift you have ever received?", Category: "medium"},
+		Question{Creator: "Icebreaker", Question: "Are you a dog or cat person?", Category: "medium"},
+		Question{Creator: "Icebreaker", Question: "Who is your least favorite actor?", Category: "medium"},
+		Question{Creator: "Icebreaker", Question: "Do you work better in the morning or at night?", Category: "medium"},
+		Question{Creator: "Icebreaker", Question: "What is something you would like to learn?", Category: "medium"},
+		Question{Creator: "Icebreaker", Question: "Which is typically better, the book or the movie?", Category: "medium"},
+		Question{Creator: "Icebreaker", Question: "Do you have a favourite (childhood) video game?", Category: "medium"},
+		Question{Creator: "Icebreaker", Question: "What is one place you have always wanted to visit?", Category: "medium"},
+		Question{Creator: "Icebreaker", Question: "If you could drive any car, what car would you drive?", Category: "medium"},
+		Question{Creator: "Icebreaker", Question: "Do you enjoy rollercoasters?", Category: "medium"},
+		Question{Creator: "Icebreaker", Question: "What is the best TV show of all time?", Category: "medium"},
+		Question{Creator: "Icebreaker", Question: "If you could act in any movie, what movie would you be in?", Category: "medium"},
+		Question{Creator: "Icebreaker", Question: "Say you were given a kitten, what would you name him/her?", Category: "medium"},
+		Question{Creator: "Icebreaker", Question: "If you could collect anything, what would it be?", Category: "medium"},
+		Question{Creator: "Icebreaker", Question: "Do you prefer team or individual sports?", Category: "medium"},
+		Question{Creator: "Icebreaker", Question: "What is the best concert you have ever been to?", Category: "medium"},
+		Question{Creator: "Icebreaker", Question: "If you could start any business in the world, what would you start?", Category: "medium"},
+		Question{Creator: "Icebreaker", Question: "Name a piece of technology you wish existed.", Category: "medium"},
 	}
 
 	return DefaultQuestions
 }
 
 // FillDefaultQuestions fills in the default questions of this plugin
-func (p *Plugin) FillDefaultQuestions() {
-	data := p.ReadFromStorage()
-	data.Questions = getDefaultQuestions()
-	p.WriteToStorage(&data)
+func (p *Plugin) FillDefaultQuestions() error {
+	return p.resetData(func(data *IceBreakerData) error {
+		data.Questions = getDefaultQuestions()
+		assignQuestionIDs(data)
+		return nil
+	})
+}
+
+// ReadFromStorage reads IceBreakerData from the KVStore. Returns empty data if nothing has been stored yet
+func (p *Plugin) ReadFromStorage() (IceBreakerData, error) {
+	kvData, appErr := p.API.KVGet(KVKEY)
+	if appErr != nil {
+		return IceBreakerData{}, errors.Wrapf(appErr, "failed to read %s", KVKEY)
+	}
+	return p.decodeData(kvData)
 }
 
-// ReadFromStorage reads IceBreakerData from the KVStore. Makes sure that data is inited for the given team and channel
-func (p *Plugin) ReadFromStorage() IceBreakerData {
+// decodeData decodes the stored IceBreakerData. Corrupted data is quarantined under a backup key instead
+// of being treated as empty data, which would overwrite the stored questions with the next write
+func (p *Plugin) decodeData(kvData []byte) (IceBreakerData, error) {
 	data := IceBreakerData{}
-	kvData, err := p.API.KVGet(KVKEY)
-	if err != nil {
-		//do nothing.. we'll return an empty IceBreakerData then...
+	if kvData == nil {
+		return data, nil
 	}
-	if kvData != nil {
-		json.Unmarshal(kvData, &data)
+	if err := json.Unmarshal(kvData, &data); err != nil {
+		p.API.LogError("Stored icebreaker data is corrupted", "err", err.Error())
+		return IceBreakerData{}, p.quarantineData(kvData)
 	}
+	return data, nil
+}
+
+// quarantineData stores the given corrupted data under a backup key, so it can be restored manually.
+// The returned error contains the backup key and can be checked using errors.Cause(err) == errCorruptedData
+func (p *Plugin) quarantineData(kvData []byte) error {
+	//use a hash of the data as key, so reading the same corrupted data multiple times results in a single backup
+	hash := sha256.Sum256(kvData)
+	backupKey := fmt.Sprintf("%s%x", quarantineKeyPrefix, hash[:8])
 
-	return data
+	_, appErr := p.API.KVSetWithOptions(backupKey, kvData, model.PluginKVSetOptions{Atomic: true, OldValue: nil})
+	if appErr != nil {
+		p.API.LogError("Failed to back up corrupted icebreaker data", "key", backupKey, "err", appErr.Error())
+		return errors.Wrap(errCorruptedData, "failed to back up the data")
+	}
+	return errors.Wrapf(errCorruptedData, "backup stored under key %s", backupKey)
+}
+
+// updateData atomically reads, modifies and writes the IceBreakerData in the KVStore.
+// The update function may be called multiple times in case the data has been changed concurrently,
+// it should therefore not have any side effects besides modifying the given data.
+// Return errSkipUpdate from the update function to leave the stored data untouched.
+func (p *Plugin) updateData(update func(data *IceBreakerData) error) error {
+	_, err := p.modifyData(update, false)
+	return err
+}
+
+// resetData works like updateData, but starts over with empty data in case the stored data is corrupted.
+// Use it for commands that replace the stored data anyway, so admins are able to recover from corrupted data
+func (p *Plugin) resetData(update func(data *IceBreakerData) error) error {
+	_, err := p.modifyData(update, true)
+	return err
+}
+
+// modifyData updates the stored data and returns the data that has been replaced, nil if the update has been skipped
+func (p *Plugin) modifyData(update func(data *IceBreakerData) error, ignoreCorruptedData bool) ([]byte, error) {
+	var replacedValue []byte
+	err := p.updateKey(KVKEY, func(oldValue []byte) ([]byte, error) {
+		replacedValue = nil //the update might be retried
+		data, err := p.decodeData(oldValue)
+		if err != nil && !(ignoreCorruptedData && errors.Cause(err) == errCorruptedData) {
+			return nil, err
+		}
+		if err := update(&data); err != nil {
+			return nil, err
+		}
+
+		newValue := new(bytes.Buffer)
+		if err := json.NewEncoder(newValue).Encode(&data); err != nil {
+			return nil, errors.Wrap(err, "failed to encode data")
+		}
+		replacedValue = oldValue
+		return newValue.Bytes(), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return replacedValue, nil
 }
 
-// WriteToStorage writes the given data to storage
-func (p *Plugin) WriteToStorage(data *IceBreakerData) {
-	reqBodyBytes := new(bytes.Buffer)
-	json.NewEncoder(reqBodyBytes).Encode(data)
-	p.API.KVSet(KVKEY, reqBodyBytes.Bytes())
+// updateKey atomically replaces the value of the given key in the KVStore with the value returned by the
+// update function. In case the value has been changed concurrently, the update is retried with the new value
+func (p *Plugin) updateKey(key string, update func(oldValue []byte) ([]byte, error)) error {
+	for retry := 0; retry < maxUpdateRetries; retry++ {
+		oldValue, appErr := p.API.KVGet(key)
+		if appErr != nil {
+			return errors.Wrapf(appErr, "failed to read %s", key)
+		}
+
+		newValue, err := update(oldValue)
+		if err == errSkipUpdate {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		stored, appErr := p.API.KVCompareAndSet(key, oldValue, newValue)
+		if appErr != nil {
+			return errors.Wrapf(appErr, "failed to write %s", key)
+		}
+		if stored {
+			return nil
+		}
+	}
+	return errors.Errorf("failed to write %s: the data has been changed concurrently too often", key)
 }
 
 // ClearStorage removes all stored data from KVStorage
-func (p *Plugin) ClearStorage() {
-	p.API.KVDelete(KVKEY)
+func (p *Plugin) ClearStorage() error {
+	if appErr := p.API.KVDelete(KVKEY); appErr != nil {
+		return errors.Wrap(appErr, "failed to clear the icebreaker data")
+	}
+	return nil
 }
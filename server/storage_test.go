@@ -0,0 +1,442 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/mattermost/mattermost-server/v5/plugin/plugintest"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeKVStore is an in-memory implementation of the KV functions of the plugin API
+type fakeKVStore struct {
+	sync.Mutex
+	data map[string][]byte
+}
+
+// newFakeKVStore returns an API mock that stores all KV data in memory, starting with the given data
+func newFakeKVStore(initialData map[string][]byte) (*plugintest.API, *fakeKVStore) {
+	store := &fakeKVStore{data: map[string][]byte{}}
+	for key, value := range initialData {
+		store.data[key] = value
+	}
+
+	api := &plugintest.API{}
+	api.On("KVGet", mock.AnythingOfType("string")).Return(
+		func(key string) []byte {
+			store.Lock()
+			defer store.Unlock()
+			return store.data[key]
+		},
+		func(key string) *model.AppError { return nil })
+	api.On("KVSet", mock.AnythingOfType("string"), mock.AnythingOfType("[]uint8")).Return(
+		func(key string, value []byte) *model.AppError {
+			store.Lock()
+			defer store.Unlock()
+			store.data[key] = value
+			return nil
+		})
+	api.On("KVCompareAndSet", mock.AnythingOfType("string"), mock.AnythingOfType("[]uint8"), mock.AnythingOfType("[]uint8")).Return(
+		func(key string, oldValue []byte, newValue []byte) bool {
+			store.Lock()
+			defer store.Unlock()
+			currentValue, exists := store.data[key]
+			if (oldValue == nil && exists) || (oldValue != nil && !bytes.Equal(oldValue, currentValue)) {
+				return false
+			}
+			store.data[key] = newValue
+			return true
+		},
+		func(key string, oldValue []byte, newValue []byte) *model.AppError { return nil })
+	api.On("KVDelete", mock.AnythingOfType("string")).Return(
+		func(key string) *model.AppError {
+			store.Lock()
+			defer store.Unlock()
+			delete(store.data, key)
+			return nil
+		})
+	api.On("KVSetWithOptions", mock.AnythingOfType("string"), mock.AnythingOfType("[]uint8"), mock.AnythingOfType("model.PluginKVSetOptions")).Return(
+		func(key string, value []byte, options model.PluginKVSetOptions) bool {
+			store.Lock()
+			defer store.Unlock()
+			currentValue, exists := store.data[key]
+			if options.Atomic && ((options.OldValue == nil && exists) || (options.OldValue != nil && !bytes.Equal(options.OldValue, currentValue))) {
+				return false
+			}
+			store.data[key] = value
+			return true
+		},
+		func(key string, value []byte, options model.PluginKVSetOptions) *model.AppError { return nil })
+	api.On("LogInfo", mock.Anything, mock.Anything, mock.Anything).Maybe()
+	api.On("LogError", mock.Anything, mock.Anything, mock.Anything).Maybe()
+	return api, store
+}
+
+// get returns the data stored under the given key
+func (s *fakeKVStore) get(key string) []byte {
+	s.Lock()
+	defer s.Unlock()
+	return s.data[key]
+}
+
+// readData reads the stored IceBreakerData and fails the test if that is not possible
+func readData(t *testing.T, plugin *Plugin) IceBreakerData {
+	data, err := plugin.ReadFromStorage()
+	require.NoError(t, err)
+	return data
+}
+
+// clearQuestionIDs removes the generated IDs from the given questions, so they can be compared to the expected ones
+func clearQuestionIDs(t *testing.T, questions []Question) []Question {
+	for index := range questions {
+		assert.Len(t, questions[index].ID, questionIDLength)
+		questions[index].ID = ""
+	}
+	return questions
+}
+
+func readFixture(t *testing.T, name string) []byte {
+	fixture, err := ioutil.ReadFile("testdata/" + name)
+	require.NoError(t, err)
+	return fixture
+}
+
+func TestRunMigrations(t *testing.T) {
+	t.Run("Fresh install", func(t *testing.T) {
+		api, store := newFakeKVStore(nil)
+		plugin := &Plugin{}
+		plugin.SetAPI(api)
+
+		require.NoError(t, plugin.runMigrations())
+		assert.Equal(t, strconv.Itoa(len(migrations)), string(store.get(KVKEYSchemaVersion)))
+		assert.Empty(t, readData(t, plugin).Questions)
+	})
+	t.Run("Schema version 0 with legacy data", func(t *testing.T) {
+		api, store := newFakeKVStore(map[string][]byte{
+			KVKEYLegacy: readFixture(t, "schema_v0_legacy.json"),
+		})
+		plugin := &Plugin{}
+		plugin.SetAPI(api)
+
+		require.NoError(t, plugin.runMigrations())
+		assert.Equal(t, strconv.Itoa(len(migrations)), string(store.get(KVKEYSchemaVersion)))
+		assert.NotNil(t, store.get(KVKEYLegacy), "the legacy data must be kept")
+
+		data := readData(t, plugin)
+		assert.ElementsMatch(t, []Question{
+			Question{Creator: "user1", Question: "What is your favourite color?", TeamID: "team1", ChannelID: "channel1", Category: defaultCategory},
+			Question{Creator: "user2", Question: "Cats or dogs?", TeamID: "team1", ChannelID: "channel1", Category: defaultCategory},
+			Question{Creator: "user1", Question: "What is your favourite color?", TeamID: "team1", ChannelID: "channel2", Category: defaultCategory},
+		}, clearQuestionIDs(t, data.Questions))
+	})
+	t.Run("Schema version 0 with legacy and current data", func(t *testing.T) {
+		api, store := newFakeKVStore(map[string][]byte{
+			KVKEYLegacy: readFixture(t, "schema_v0_legacy.json"),
+			KVKEY:       readFixture(t, "schema_v1.json"),
+		})
+		plugin := &Plugin{}
+		plugin.SetAPI(api)
+
+		require.NoError(t, plugin.runMigrations())
+		assert.Equal(t, strconv.Itoa(len(migrations)), string(store.get(KVKEYSchemaVersion)))
+		assert.Len(t, readData(t, plugin).Questions, 5)
+	})
+	t.Run("Schema version 1", func(t *testing.T) {
+		api, store := newFakeKVStore(map[string][]byte{
+			KVKEY:              readFixture(t, "schema_v1.json"),
+			KVKEYSchemaVersion: []byte("1"),
+		})
+		plugin := &Plugin{}
+		plugin.SetAPI(api)
+
+		require.NoError(t, plugin.runMigrations())
+		assert.Equal(t, strconv.Itoa(len(migrations)), string(store.get(KVKEYSchemaVersion)))
+
+		data := readData(t, plugin)
+		require.Len(t, data.LastQuestions, 1)
+		assert.Equal(t, data.Questions[0].ID, data.LastQuestions[0].ID, "the history must refer to the question by its ID")
+		assert.Equal(t, []Question{
+			Question{Creator: "user1", Question: "What did you eat for breakfast?", Category: defaultCategory},
+			Question{Creator: "user2", Question: "Where were you born?", Category: "mild"},
+		}, clearQuestionIDs(t, data.Questions))
+		assert.Equal(t, []string{"user1", "user2"}, data.LastUsers)
+	})
+	t.Run("Schema version 2", func(t *testing.T) {
+		api, store := newFakeKVStore(map[string][]byte{
+			KVKEY:              readFixture(t, "schema_v2.json"),
+			KVKEYSchemaVersion: []byte("2"),
+		})
+		plugin := &Plugin{}
+		plugin.SetAPI(api)
+
+		require.NoError(t, plugin.runMigrations())
+		assert.Equal(t, strconv.Itoa(len(migrations)), string(store.get(KVKEYSchemaVersion)))
+
+		data := readData(t, plugin)
+		require.Len(t, data.Questions, 3)
+		ids := map[string]bool{}
+		for _, question := range data.Questions {
+			assert.Len(t, question.ID, questionIDLength)
+			ids[question.ID] = true
+		}
+		assert.Len(t, ids, 3, "every question needs its own ID")
+		require.Len(t, data.LastQuestions, 1)
+		assert.Equal(t, data.Questions[2].ID, data.LastQuestions[0].ID, "the history must refer to the question of the same pool")
+		assert.Equal(t, []Question{
+			Question{Creator: "user1", Question: "What did you eat for breakfast?", Category: defaultCategory},
+			Question{Creator: "user2", Question: "Where were you born?", Category: "mild"},
+			Question{Creator: "user2", Question: "Where were you born?", TeamID: "team1", Category: "mild"},
+		}, clearQuestionIDs(t, data.Questions))
+		assert.Equal(t, []string{"user1", "user2"}, data.LastUsers)
+	})
+	t.Run("Latest schema version", func(t *testing.T) {
+		api, store := newFakeKVStore(map[string][]byte{
+			KVKEY:              readFixture(t, "schema_v3.json"),
+			KVKEYSchemaVersion: []byte(strconv.Itoa(len(migrations))),
+		})
+		plugin := &Plugin{}
+		plugin.SetAPI(api)
+
+		require.NoError(t, plugin.runMigrations())
+		assert.Equal(t, readFixture(t, "schema_v3.json"), store.get(KVKEY), "data must not be touched")
+	})
+	t.Run("Unknown schema version", func(t *testing.T) {
+		api, _ := newFakeKVStore(map[string][]byte{
+			KVKEYSchemaVersion: []byte("1000"),
+		})
+		plugin := &Plugin{}
+		plugin.SetAPI(api)
+
+		assert.Error(t, plugin.runMigrations())
+	})
+	t.Run("Corrupted legacy data", func(t *testing.T) {
+		api, store := newFakeKVStore(map[string][]byte{
+			KVKEYLegacy: []byte("{not json"),
+		})
+		plugin := &Plugin{}
+		plugin.SetAPI(api)
+
+		assert.Error(t, plugin.runMigrations())
+		assert.Nil(t, store.get(KVKEYSchemaVersion), "the failed migration must be retried on the next activation")
+	})
+}
+
+func TestUpdateData(t *testing.T) {
+	t.Run("Retry on concurrent change", func(t *testing.T) {
+		api, store := newFakeKVStore(nil)
+		plugin := &Plugin{}
+		plugin.SetAPI(api)
+
+		calls := 0
+		err := plugin.updateData(func(data *IceBreakerData) error {
+			calls++
+			if calls == 1 {
+				//simulate another node changing the data in between reading and writing
+				store.Lock()
+				store.data[KVKEY] = []byte(`{"Questions":[{"creator":"OtherUser","question":"Concurrent question"}]}`)
+				store.Unlock()
+			}
+			data.Questions = append(data.Questions, Question{Creator: "TestUser", Question: "How do you do?"})
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 2, calls)
+		assert.Len(t, readData(t, plugin).Questions, 2)
+	})
+	t.Run("Skip update", func(t *testing.T) {
+		api, _ := newFakeKVStore(nil)
+		plugin := &Plugin{}
+		plugin.SetAPI(api)
+
+		err := plugin.updateData(func(data *IceBreakerData) error {
+			return errSkipUpdate
+		})
+		require.NoError(t, err)
+		api.AssertNotCalled(t, "KVCompareAndSet", mock.Anything, mock.Anything, mock.Anything)
+	})
+	t.Run("Give up after too many concurrent changes", func(t *testing.T) {
+		plugin := &Plugin{}
+		api := &plugintest.API{}
+		api.On("KVGet", mock.AnythingOfType("string")).Return(nil, nil)
+		api.On("KVCompareAndSet", mock.AnythingOfType("string"), mock.AnythingOfType("[]uint8"), mock.AnythingOfType("[]uint8")).Return(false, nil)
+		plugin.SetAPI(api)
+
+		err := plugin.updateData(func(data *IceBreakerData) error {
+			return nil
+		})
+		assert.Error(t, err)
+		api.AssertNumberOfCalls(t, "KVCompareAndSet", maxUpdateRetries)
+	})
+}
+
+func TestCommands_concurrent(t *testing.T) {
+	const numPrefilled = 10
+	const numAdds = 10
+	const numAsks = 10
+
+	initialData := &IceBreakerData{}
+	for i := 0; i < numPrefilled; i++ {
+		initialData.Questions = append(initialData.Questions, Question{ID: fmt.Sprintf("pre%03d", i), Creator: "TestUserId", Question: fmt.Sprintf("Prefilled question %d", i)})
+	}
+	initialBytes := new(bytes.Buffer)
+	json.NewEncoder(initialBytes).Encode(initialData)
+
+	api, _ := newFakeKVStore(map[string][]byte{KVKEY: initialBytes.Bytes()})
+	api.On("GetUser", mock.AnythingOfType("string")).Return(&model.User{Username: "TestUser", Id: "TestUserId", Roles: model.SYSTEM_ADMIN_ROLE_ID}, nil)
+	api.On("GetUsersInChannel", mock.AnythingOfType("string"), mock.AnythingOfType("string"), mock.AnythingOfType("int"), mock.AnythingOfType("int")).
+		Return([]*model.User{&model.User{Id: "SuccessUser", Username: "success_user"}}, nil)
+	api.On("GetUserStatus", "SuccessUser").Return(&model.Status{Status: "online"}, nil)
+	api.On("CreatePost", mock.AnythingOfType("*model.Post")).Return(nil, nil)
+	plugin := &Plugin{}
+	plugin.SetAPI(api)
+
+	//add new questions, remove the prefilled questions and ask questions, all at the same time
+	wg := sync.WaitGroup{}
+	for i := 0; i < numAdds; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			result, _ := plugin.ExecuteCommand(nil, &model.CommandArgs{Command: fmt.Sprintf("/icebreaker add Added question %d", i), UserId: "TestUserId"})
+			assert.Contains(t, result.Text, "Added your question")
+		}(i)
+	}
+	for i := 0; i < numPrefilled; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			result, _ := plugin.ExecuteCommand(nil, &model.CommandArgs{Command: fmt.Sprintf("/icebreaker admin remove pre%03d", i), UserId: "TestUserId"})
+			assert.Equal(t, "Question removed", result.Text)
+		}(i)
+	}
+	for i := 0; i < numAsks; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			plugin.ExecuteCommand(nil, &model.CommandArgs{Command: "/icebreaker", UserId: "TestUserId", ChannelId: "TestChannel"})
+		}()
+	}
+	wg.Wait()
+
+	data := readData(t, plugin)
+	assert.Len(t, data.Questions, numAdds, "every added question must be stored and every prefilled question removed")
+	for _, question := range data.Questions {
+		assert.Contains(t, question.Question, "Added question")
+	}
+	assert.Len(t, data.LastUsers, numAsks, "every asked user must be stored in the history")
+	assert.Len(t, data.LastQuestions, numAsks, "every asked question must be stored in the history")
+}
+
+func TestCorruptedData(t *testing.T) {
+	corruptedData := []byte(`{"Questions":[{"creator":"TestUser","question":"How do`)
+	hash := sha256.Sum256(corruptedData)
+	quarantineKey := fmt.Sprintf("%s%x", quarantineKeyPrefix, hash[:8])
+
+	t.Run("Read is quarantined", func(t *testing.T) {
+		api, store := newFakeKVStore(map[string][]byte{KVKEY: corruptedData})
+		plugin := &Plugin{}
+		plugin.SetAPI(api)
+
+		_, err := plugin.ReadFromStorage()
+		assert.Equal(t, errCorruptedData, errors.Cause(err))
+		assert.Contains(t, err.Error(), quarantineKey)
+		assert.Equal(t, corruptedData, store.get(quarantineKey))
+		assert.Equal(t, corruptedData, store.get(KVKEY), "the corrupted data must be kept in place")
+	})
+	t.Run("Activation does not overwrite the data", func(t *testing.T) {
+		api, store := newFakeKVStore(map[string][]byte{KVKEY: corruptedData})
+		plugin := &Plugin{}
+		plugin.SetAPI(api)
+
+		err := plugin.initData()
+		assert.Equal(t, errCorruptedData, errors.Cause(err))
+		assert.Equal(t, corruptedData, store.get(KVKEY))
+		assert.Equal(t, corruptedData, store.get(quarantineKey))
+	})
+	t.Run("Commands report the corrupted data", func(t *testing.T) {
+		api, store := newFakeKVStore(map[string][]byte{KVKEY: corruptedData})
+		api.On("GetUser", mock.AnythingOfType("string")).Return(&model.User{Username: "TestUser", Id: "TestUserId", Roles: model.SYSTEM_ADMIN_ROLE_ID}, nil)
+		plugin := &Plugin{}
+		plugin.SetAPI(api)
+
+		for _, command := range []string{"/icebreaker", "/icebreaker add How do you do?", "/icebreaker list", "/icebreaker admin remove 0", "/icebreaker schedule add 0 9 * * *"} {
+			result, _ := plugin.ExecuteCommand(nil, &model.CommandArgs{Command: command, UserId: "TestUserId"})
+			assert.Contains(t, result.Text, "Error: The stored icebreaker data is corrupted", command)
+			assert.Contains(t, result.Text, quarantineKey, command)
+		}
+		assert.Equal(t, corruptedData, store.get(KVKEY))
+	})
+	t.Run("Admins can start over", func(t *testing.T) {
+		api, store := newFakeKVStore(map[string][]byte{KVKEY: corruptedData})
+		api.On("GetUser", mock.AnythingOfType("string")).Return(&model.User{Username: "TestUser", Id: "TestUserId", Roles: model.SYSTEM_ADMIN_ROLE_ID}, nil)
+		plugin := &Plugin{}
+		plugin.SetAPI(api)
+
+		result, _ := plugin.ExecuteCommand(nil, &model.CommandArgs{Command: "/icebreaker admin reset questions", UserId: "TestUserId"})
+		assert.Equal(t, "All questions have been reset to the default ones. Beware the pitchforks!", result.Text)
+		assert.Len(t, readData(t, plugin).Questions, len(getDefaultQuestions()))
+		assert.Equal(t, corruptedData, store.get(quarantineKey))
+	})
+}
+
+func TestStorageErrors(t *testing.T) {
+	t.Run("Failed write is reported", func(t *testing.T) {
+		plugin := &Plugin{}
+		api := &plugintest.API{}
+		api.On("GetUser", mock.AnythingOfType("string")).Return(&model.User{Username: "TestUser", Id: "TestUserId"}, nil)
+		api.On("KVGet", mock.AnythingOfType("string")).Return(nil, nil)
+		api.On("KVCompareAndSet", mock.AnythingOfType("string"), mock.AnythingOfType("[]uint8"), mock.AnythingOfType("[]uint8")).Return(false, &model.AppError{Message: "database is gone"})
+		api.On("LogError", mock.Anything, mock.Anything, mock.Anything)
+		plugin.SetAPI(api)
+
+		result := plugin.executeCommandIcebreakerAdd(&model.CommandArgs{Command: "/icebreaker add How do you do?", UserId: "TestUserId"})
+		assert.Equal(t, "Error: Failed to access the icebreaker data, please try again", result.Text)
+	})
+	t.Run("Failed read is reported", func(t *testing.T) {
+		plugin := &Plugin{}
+		api := &plugintest.API{}
+		api.On("KVGet", mock.AnythingOfType("string")).Return(nil, &model.AppError{Message: "database is gone"})
+		api.On("LogError", mock.Anything, mock.Anything, mock.Anything)
+		plugin.SetAPI(api)
+
+		result := plugin.executeCommandIcebreakerList(&model.CommandArgs{Command: "/icebreaker list"})
+		assert.Equal(t, "Error: Failed to access the icebreaker data, please try again", result.Text)
+	})
+	t.Run("Failed user lookups are reported", func(t *testing.T) {
+		dataBytes, err := json.Marshal(IceBreakerData{Questions: []Question{Question{ID: "q1", Creator: "TestUser", Question: "How do you do?"}}})
+		require.NoError(t, err)
+
+		plugin := &Plugin{}
+		api := &plugintest.API{}
+		api.On("GetUser", mock.AnythingOfType("string")).Return(nil, &model.AppError{Message: "database is gone"})
+		api.On("KVGet", mock.AnythingOfType("string")).Return(dataBytes, nil)
+		api.On("GetUsersInChannel", mock.AnythingOfType("string"), mock.AnythingOfType("string"), mock.AnythingOfType("int"), mock.AnythingOfType("int")).
+			Return(nil, &model.AppError{Message: "database is gone"})
+		api.On("LogError", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		api.On("LogError", mock.Anything, mock.Anything, mock.Anything)
+		plugin.SetAPI(api)
+
+		result := plugin.executeCommandIcebreakerAdd(&model.CommandArgs{Command: "/icebreaker add How are you?", UserId: "TestUserId"})
+		assert.Equal(t, "Error: Failed to look up your user, please try again", result.Text)
+
+		result = plugin.executeCommandIcebreaker(&model.CommandArgs{Command: "/icebreaker", TeamId: "TestTeam", ChannelId: "TestChannel", UserId: "TestUserId"})
+		assert.Equal(t, "Error: Failed to get the users of this channel, please try again", result.Text)
+	})
+	t.Run("Failed clear is reported", func(t *testing.T) {
+		plugin := &Plugin{}
+		api := &plugintest.API{}
+		api.On("KVDelete", KVKEY).Return(&model.AppError{Message: "database is gone"})
+		plugin.SetAPI(api)
+
+		assert.Error(t, plugin.ClearStorage())
+	})
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/mroth/weightedrand"
+)
+
+const (
+	//names of the strategies that choose whom to ask and which question to ask
+	strategyWeighted    = "weighted"
+	strategyUniform     = "uniform"
+	strategyRoundRobin  = "roundrobin"
+	strategyLeastRecent = "leastrecent"
+)
+
+// SelectionStrategy decides how likely users or questions are chosen, based on the ones chosen lately
+type SelectionStrategy interface {
+	// getWeights returns the weight of every candidate, given the keys of the recently chosen ones with the oldest first.
+	// Candidates with a weight of 0 are never chosen, all others with a probability proportional to their weight
+	getWeights(candidates []string, history []string) []uint
+}
+
+// weightedHistoryStrategy prefers candidates that have not been chosen lately. Recently chosen ones are weighted by
+// their position in the history, the most recent one getting a weight of 1, all others get newItemWeight
+type weightedHistoryStrategy struct {
+	newItemWeight uint
+}
+
+func (s *weightedHistoryStrategy) getWeights(candidates []string, history []string) []uint {
+	weights := make([]uint, len(candidates))
+	for index, candidate := range candidates {
+		weights[index] = s.newItemWeight
+
+		//by iterating in reverse we make sure that candidates appearing multiple times in the history get the weight of the latest one
+		for historyIndex := len(history) - 1; historyIndex >= 0; historyIndex-- {
+			if history[historyIndex] == candidate {
+				weights[index] = uint(len(history) - historyIndex)
+				break
+			}
+		}
+	}
+	return weights
+}
+
+// uniformStrategy chooses every candidate with the same probability, regardless of the history
+type uniformStrategy struct{}
+
+func (s *uniformStrategy) getWeights(candidates []string, history []string) []uint {
+	weights := make([]uint, len(candidates))
+	for index := range candidates {
+		weights[index] = 1
+	}
+	return weights
+}
+
+// roundRobinStrategy only chooses among the candidates that appear the fewest times in the history.
+// Given the current round of the channel rotation, everyone is chosen once before anyone is chosen twice
+type roundRobinStrategy struct{}
+
+func (s *roundRobinStrategy) getWeights(candidates []string, history []string) []uint {
+	counts := map[string]int{}
+	for _, key := range history {
+		counts[key]++
+	}
+	fewest := -1
+	for _, candidate := range candidates {
+		if fewest < 0 || counts[candidate] < fewest {
+			fewest = counts[candidate]
+		}
+	}
+
+	weights := make([]uint, len(candidates))
+	for index, candidate := range candidates {
+		if counts[candidate] == fewest {
+			weights[index] = 1
+		}
+	}
+	return weights
+}
+
+// leastRecentStrategy only chooses among the candidates that have been chosen the longest time ago.
+// Candidates that are not part of the history at all come first
+type leastRecentStrategy struct{}
+
+func (s *leastRecentStrategy) getWeights(candidates []string, history []string) []uint {
+	lastChosen := map[string]int{}
+	for index, key := range history {
+		lastChosen[key] = index
+	}
+	getLastChosen := func(candidate string) int {
+		if index, ok := lastChosen[candidate]; ok {
+			return index
+		}
+		return -1
+	}
+	oldest := len(history)
+	for _, candidate := range candidates {
+		if index := getLastChosen(candidate); index < oldest {
+			oldest = index
+		}
+	}
+
+	weights := make([]uint, len(candidates))
+	for index, candidate := range candidates {
+		if getLastChosen(candidate) == oldest {
+			weights[index] = 1
+		}
+	}
+	return weights
+}
+
+// ChannelRotation remembers the users or questions chosen in a channel for the round-robin and least-recent strategies.
+// Unlike the global history it is kept per channel and not limited in length, so it covers every candidate of the channel
+type ChannelRotation struct {
+	//Chosen holds every key that has been chosen, the least recently chosen first
+	Chosen []string `json:"Chosen"`
+	//Round is the number of keys at the end of Chosen that have been chosen in the current round
+	Round int `json:"Round"`
+}
+
+// getChosen returns every key that has been chosen, the least recently chosen first
+func (r *ChannelRotation) getChosen() []string {
+	if r == nil {
+		return []string{}
+	}
+	return r.Chosen
+}
+
+// getRound returns the keys that have been chosen in the current round
+func (r *ChannelRotation) getRound() []string {
+	chosen := r.getChosen()
+	if r == nil || r.Round >= len(chosen) {
+		return chosen
+	}
+	return chosen[len(chosen)-r.Round:]
+}
+
+// choose moves the key to the end of the rotation. Choosing a key again starts a new round
+func (r *ChannelRotation) choose(key string) {
+	r.Round = len(r.getRound())
+	if containsString(r.getRound(), key) {
+		r.Round = 0
+	}
+	r.Chosen = append(removeString(r.Chosen, key), key)
+	r.Round++
+}
+
+// addToRotation records the key chosen in the given channel, creating the rotations if needed
+func addToRotation(rotations map[string]*ChannelRotation, channelID string, key string) map[string]*ChannelRotation {
+	if rotations == nil {
+		rotations = map[string]*ChannelRotation{}
+	}
+	if rotations[channelID] == nil {
+		rotations[channelID] = &ChannelRotation{}
+	}
+	rotations[channelID].choose(key)
+	return rotations
+}
+
+// usesRotation returns whether the named strategy chooses by the channel rotation instead of the global history
+func usesRotation(name string) bool {
+	return name == strategyRoundRobin || name == strategyLeastRecent
+}
+
+// getStrategyHistory returns the keys the named strategy weights the candidates by: the current round of the
+// channel rotation for round-robin, the whole channel rotation for least-recent and the global history for the others
+func getStrategyHistory(name string, history []string, rotation *ChannelRotation) []string {
+	switch name {
+	case strategyRoundRobin:
+		return rotation.getRound()
+	case strategyLeastRecent:
+		return rotation.getChosen()
+	default:
+		return history
+	}
+}
+
+func isValidStrategy(name string) bool {
+	return name == strategyWeighted || name == strategyUniform || name == strategyRoundRobin || name == strategyLeastRecent
+}
+
+// getStrategyName returns the name of the strategy used in the given channel
+func (d *IceBreakerData) getStrategyName(channelID string, config *configuration) string {
+	if name, ok := d.ChannelStrategies[channelID]; ok && isValidStrategy(name) {
+		return name
+	}
+	return config.getSelectionStrategy()
+}
+
+// getSelectionStrategy returns the strategy with the given name, the weighted one for unknown names
+func getSelectionStrategy(name string, config *configuration) SelectionStrategy {
+	switch name {
+	case strategyUniform:
+		return &uniformStrategy{}
+	case strategyRoundRobin:
+		return &roundRobinStrategy{}
+	case strategyLeastRecent:
+		return &leastRecentStrategy{}
+	default:
+		return &weightedHistoryStrategy{newItemWeight: uint(config.getNewItemWeight())}
+	}
+}
+
+// pickWeighted randomly picks one of the items according to the given weights.
+// Returns false if there is no item with a weight larger than 0
+func pickWeighted(random *rand.Rand, items []interface{}, weights []uint) (interface{}, bool) {
+	choices := []weightedrand.Choice{}
+	for index, item := range items {
+		if weights[index] > 0 {
+			choices = append(choices, weightedrand.Choice{Item: item, Weight: weights[index]})
+		}
+	}
+	if len(choices) == 0 {
+		return nil, false
+	}
+	return weightedrand.NewChooser(choices...).PickSource(random), true
+}
+
+func (p *Plugin) executeCommandIcebreakerStrategy(args *model.CommandArgs) *model.CommandResponse {
+	name := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(args.Command, fmt.Sprintf("/%s", commandIcebreakerStrategy))))
+	if name == "" {
+		data, err := p.ReadFromStorage()
+		if err != nil {
+			return p.getStorageErrorResponse(err)
+		}
+		return &model.CommandResponse{
+			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+			Text:         fmt.Sprintf("This channel uses the %s strategy to choose users and questions.", data.getStrategyName(args.ChannelId, p.getConfiguration())),
+		}
+	}
+	if !isValidStrategy(name) {
+		return &model.CommandResponse{
+			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+			Text:         fmt.Sprintf("Error: Unknown strategy '%s', use one of: %s, %s, %s, %s", name, strategyWeighted, strategyUniform, strategyRoundRobin, strategyLeastRecent),
+		}
+	}
+
+	err := p.updateData(func(data *IceBreakerData) error {
+		if data.ChannelStrategies == nil {
+			data.ChannelStrategies = map[string]string{}
+		}
+		data.ChannelStrategies[args.ChannelId] = name
+		return nil
+	})
+	if err != nil {
+		return p.getStorageErrorResponse(err)
+	}
+	return &model.CommandResponse{
+		ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+		Text:         fmt.Sprintf("This channel uses the %s strategy to choose users and questions now.", name),
+	}
+}
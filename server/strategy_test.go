@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectionStrategies_weights(t *testing.T) {
+	candidates := []string{"a", "b", "c", "d"}
+	history := []string{"b", "a", "c", "b", "c"}
+
+	assert.Equal(t, []uint{4, 2, 1, 1000}, (&weightedHistoryStrategy{newItemWeight: 1000}).getWeights(candidates, history))
+	assert.Equal(t, []uint{1, 1, 1, 1}, (&uniformStrategy{}).getWeights(candidates, history))
+	assert.Equal(t, []uint{0, 0, 0, 1}, (&roundRobinStrategy{}).getWeights(candidates, history))
+	assert.Equal(t, []uint{0, 0, 0, 1}, (&leastRecentStrategy{}).getWeights(candidates, history))
+
+	//without a new candidate, round-robin prefers the least often chosen and least-recent the longest ago chosen one
+	candidates = []string{"a", "b", "c"}
+	assert.Equal(t, []uint{1, 0, 0}, (&roundRobinStrategy{}).getWeights(candidates, history))
+	assert.Equal(t, []uint{1, 0, 0}, (&leastRecentStrategy{}).getWeights(candidates, []string{"a", "c", "b"}))
+	assert.Equal(t, []uint{1, 1, 1}, (&roundRobinStrategy{}).getWeights(candidates, []string{"c", "b", "a"}))
+	assert.Equal(t, []uint{0, 1, 0}, (&leastRecentStrategy{}).getWeights(candidates, []string{"b", "c", "a"}))
+	assert.Equal(t, []uint{}, (&roundRobinStrategy{}).getWeights([]string{}, history))
+}
+
+func TestSelectionStrategies_roundRobin(t *testing.T) {
+	candidates := []string{"a", "b", "c"}
+	items := []interface{}{"a", "b", "c"}
+	strategy := &roundRobinStrategy{}
+	random := newRandom(1337)
+
+	history := []string{}
+	for round := 0; round < 3; round++ {
+		asked := map[string]bool{}
+		for i := 0; i < len(candidates); i++ {
+			picked, ok := pickWeighted(random, items, strategy.getWeights(candidates, history))
+			require.True(t, ok)
+			asked[picked.(string)] = true
+			history = append(history, picked.(string))
+		}
+		assert.Len(t, asked, len(candidates), "everyone is asked once per round")
+	}
+}
+
+func TestChannelRotation(t *testing.T) {
+	var rotation *ChannelRotation
+	assert.Empty(t, rotation.getChosen())
+	assert.Empty(t, rotation.getRound())
+
+	rotation = &ChannelRotation{}
+	for _, key := range []string{"a", "b", "c"} {
+		rotation.choose(key)
+	}
+	assert.Equal(t, &ChannelRotation{Chosen: []string{"a", "b", "c"}, Round: 3}, rotation)
+
+	//choosing a key again moves it to the end and starts a new round
+	rotation.choose("b")
+	assert.Equal(t, &ChannelRotation{Chosen: []string{"a", "c", "b"}, Round: 1}, rotation)
+	assert.Equal(t, []string{"b"}, rotation.getRound())
+	rotation.choose("a")
+	assert.Equal(t, &ChannelRotation{Chosen: []string{"c", "b", "a"}, Round: 2}, rotation)
+	assert.Equal(t, []string{"b", "a"}, rotation.getRound())
+}
+
+func TestPostIcebreaker_channelRotation(t *testing.T) {
+	//more candidates than the global history is long, so only the rotation of the channel can tell who has been asked
+	users := []*model.User{}
+	for i := 0; i < LenHistory+10; i++ {
+		users = append(users, &model.User{Id: fmt.Sprintf("User%d", i), Username: fmt.Sprintf("user%d", i)})
+	}
+	question := &Question{ID: "q1", Creator: "TestUser", Question: "How do you do?"}
+
+	for _, strategyName := range []string{strategyRoundRobin, strategyLeastRecent} {
+		t.Run(strategyName, func(t *testing.T) {
+			api, _ := newFakeKVStore(nil)
+			api.On("GetUsersInChannel", mock.AnythingOfType("string"), mock.AnythingOfType("string"), mock.AnythingOfType("int"), mock.AnythingOfType("int")).Return(users, nil)
+			api.On("GetUserStatus", mock.AnythingOfType("string")).Return(&model.Status{Status: "online"}, nil)
+			api.On("CreatePost", mock.AnythingOfType("*model.Post")).Return(&model.Post{Id: "IcebreakerPost"}, nil)
+			plugin := &Plugin{botID: "BotUser"}
+			plugin.SetAPI(api)
+			plugin.setConfiguration(&configuration{SelectionStrategy: strategyName})
+
+			ask := func(channelID string) string {
+				user, appErr := plugin.GetRandomUser(channelID, "")
+				require.Nil(t, appErr)
+				require.NoError(t, plugin.postIcebreaker("TestTeam", channelID, "", user, question, questionFilter{}))
+				return user.Id
+			}
+
+			for round := 0; round < 2; round++ {
+				asked := map[string]bool{}
+				for i := 0; i < len(users); i++ {
+					//icebreakers in another channel must not disturb the rotation of this one
+					ask("OtherChannel")
+					asked[ask("TestChannel")] = true
+				}
+				assert.Len(t, asked, len(users), "everyone is asked once per round")
+			}
+			assert.Len(t, readData(t, plugin).LastUsers, LenHistory)
+		})
+	}
+}
+
+func TestPickWeighted(t *testing.T) {
+	random := newRandom(1337)
+	_, ok := pickWeighted(random, []interface{}{"a", "b"}, []uint{0, 0})
+	assert.False(t, ok)
+
+	for i := 0; i < 20; i++ {
+		picked, ok := pickWeighted(random, []interface{}{"a", "b", "c"}, []uint{0, 5, 0})
+		require.True(t, ok)
+		assert.Equal(t, "b", picked)
+	}
+}
+
+func TestGetRandomUser_strategies(t *testing.T) {
+	users := []*model.User{
+		&model.User{Id: "A", Username: "a"},
+		&model.User{Id: "B", Username: "b"},
+		&model.User{Id: "C", Username: "c"},
+	}
+	setup := func(data *IceBreakerData, config *configuration) *Plugin {
+		dataBytes, err := json.Marshal(data)
+		require.NoError(t, err)
+
+		api, _ := newFakeKVStore(map[string][]byte{KVKEY: dataBytes})
+		api.On("GetUsersInChannel", "TestChannel", mock.AnythingOfType("string"), mock.AnythingOfType("int"), mock.AnythingOfType("int")).Return(users, nil)
+		api.On("GetUserStatus", mock.AnythingOfType("string")).Return(&model.Status{Status: "online"}, nil)
+		plugin := &Plugin{}
+		plugin.SetAPI(api)
+		plugin.setConfiguration(config)
+		return plugin
+	}
+	askedUsers := func(plugin *Plugin) map[string]bool {
+		asked := map[string]bool{}
+		for i := 0; i < 50; i++ {
+			user, err := plugin.GetRandomUser("TestChannel", "")
+			require.Nil(t, err)
+			asked[user.Id] = true
+		}
+		return asked
+	}
+
+	t.Run("Configured strategy", func(t *testing.T) {
+		data := &IceBreakerData{
+			LastUsers:     []string{"C", "B", "A"},
+			UserRotations: map[string]*ChannelRotation{"TestChannel": &ChannelRotation{Chosen: []string{"C", "A", "B"}, Round: 3}},
+		}
+		plugin := setup(data, &configuration{SelectionStrategy: strategyLeastRecent})
+		assert.Equal(t, map[string]bool{"C": true}, askedUsers(plugin))
+	})
+	t.Run("Channel strategy overrides the configured one", func(t *testing.T) {
+		data := &IceBreakerData{
+			UserRotations: map[string]*ChannelRotation{
+				"TestChannel":  &ChannelRotation{Chosen: []string{"B", "C", "A"}, Round: 1},
+				"OtherChannel": &ChannelRotation{Chosen: []string{"A", "B"}, Round: 2},
+			},
+			ChannelStrategies: map[string]string{"TestChannel": strategyRoundRobin, "OtherChannel": strategyUniform},
+		}
+		plugin := setup(data, &configuration{SelectionStrategy: strategyLeastRecent})
+		assert.Equal(t, map[string]bool{"B": true, "C": true}, askedUsers(plugin))
+	})
+	t.Run("Ignored user", func(t *testing.T) {
+		data := &IceBreakerData{UserRotations: map[string]*ChannelRotation{"TestChannel": &ChannelRotation{Chosen: []string{"A", "B"}, Round: 2}}}
+		plugin := setup(data, &configuration{SelectionStrategy: strategyRoundRobin})
+		for i := 0; i < 20; i++ {
+			user, err := plugin.GetRandomUser("TestChannel", "C")
+			require.Nil(t, err)
+			assert.Contains(t, []string{"A", "B"}, user.Id)
+		}
+	})
+}
+
+func TestGetRandomQuestion_strategies(t *testing.T) {
+	data := &IceBreakerData{
+		Questions: []Question{
+			Question{ID: "q1", Creator: "TestUser", Question: "Question 1?"},
+			Question{ID: "q2", Creator: "TestUser", Question: "Question 2?"},
+			Question{ID: "q3", Creator: "TestUser", Question: "Question 3?"},
+		},
+		//the global history does not matter for least-recent, only the rotation of the channel
+		LastQuestions:     []Question{Question{ID: "q1"}, Question{ID: "q3"}},
+		QuestionRotations: map[string]*ChannelRotation{"TestChannel": &ChannelRotation{Chosen: []string{"q2", "q1", "q3"}, Round: 3}},
+		ChannelStrategies: map[string]string{"TestChannel": strategyLeastRecent},
+	}
+	dataBytes, err := json.Marshal(data)
+	require.NoError(t, err)
+
+	api, _ := newFakeKVStore(map[string][]byte{KVKEY: dataBytes})
+	plugin := &Plugin{}
+	plugin.SetAPI(api)
+
+	for i := 0; i < 20; i++ {
+		question, err := plugin.GetRandomQuestion("TestTeam", "TestChannel", questionFilter{})
+		require.Nil(t, err)
+		assert.Equal(t, "q2", question.ID)
+	}
+}
+
+func TestStrategyCommand(t *testing.T) {
+	api, _ := newFakeKVStore(nil)
+	api.On("GetUser", "AdminUser").Return(&model.User{Id: "AdminUser", Roles: model.SYSTEM_ADMIN_ROLE_ID}, nil)
+	plugin := &Plugin{}
+	plugin.SetAPI(api)
+	plugin.setConfiguration(&configuration{SelectionStrategy: strategyUniform})
+
+	execute := func(command string) string {
+		response, _ := plugin.ExecuteCommand(nil, &model.CommandArgs{Command: command, UserId: "AdminUser", ChannelId: "TestChannel"})
+		return response.Text
+	}
+
+	assert.Equal(t, "This channel uses the uniform strategy to choose users and questions.", execute("/icebreaker admin strategy"))
+	assert.Equal(t, "Error: Unknown strategy 'random', use one of: weighted, uniform, roundrobin, leastrecent", execute("/icebreaker admin strategy random"))
+	assert.Equal(t, "This channel uses the roundrobin strategy to choose users and questions now.", execute("/icebreaker admin strategy RoundRobin"))
+	assert.Equal(t, map[string]string{"TestChannel": strategyRoundRobin}, readData(t, plugin).ChannelStrategies)
+	assert.Equal(t, "This channel uses the roundrobin strategy to choose users and questions.", execute("/icebreaker admin strategy"))
+}
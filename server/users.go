@@ -0,0 +1,75 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	//displayNameCacheExpiry sets how long display names are cached, so renamed users show up with their new name eventually
+	displayNameCacheExpiry = 10 * time.Minute
+
+	//maxCachedDisplayNames limits the size of the cache, it is cleared once the limit has been reached
+	maxCachedDisplayNames = 10000
+)
+
+// displayNameCache caches the display names of users, so listing questions does not look up the same creators over and over
+type displayNameCache struct {
+	sync.Mutex
+	entries map[string]cachedDisplayName
+}
+
+type cachedDisplayName struct {
+	name      string
+	expiresAt time.Time
+}
+
+func (c *displayNameCache) get(userID string, now time.Time) (string, bool) {
+	c.Lock()
+	defer c.Unlock()
+	entry, ok := c.entries[userID]
+	if !ok || now.After(entry.expiresAt) {
+		return "", false
+	}
+	return entry.name, true
+}
+
+func (c *displayNameCache) set(userID string, name string, now time.Time) {
+	c.Lock()
+	defer c.Unlock()
+	if c.entries == nil || len(c.entries) >= maxCachedDisplayNames {
+		c.entries = map[string]cachedDisplayName{}
+	}
+	c.entries[userID] = cachedDisplayName{name: name, expiresAt: now.Add(displayNameCacheExpiry)}
+}
+
+// getDisplayNames returns the display names of the given users, users that cannot be found are shown by their ID.
+// The plugin API has no way to get multiple users by their IDs, so every user is looked up once and cached
+func (p *Plugin) getDisplayNames(userIDs []string) map[string]string {
+	now := time.Now()
+	names := map[string]string{}
+	for _, userID := range userIDs {
+		if _, ok := names[userID]; ok {
+			continue
+		}
+		if name, ok := p.displayNames.get(userID, now); ok {
+			names[userID] = name
+			continue
+		}
+
+		user, appErr := p.API.GetUser(userID)
+		if appErr != nil {
+			//do not cache the failure, the user might be found next time
+			names[userID] = userID
+			continue
+		}
+		names[userID] = user.GetDisplayName("")
+		p.displayNames.set(userID, names[userID], now)
+	}
+	return names
+}
+
+// getDisplayName returns the display name of the given user, or the user ID if the user cannot be found
+func (p *Plugin) getDisplayName(userID string) string {
+	return p.getDisplayNames([]string{userID})[userID]
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/mattermost/mattermost-server/v5/plugin"
+)
+
+const (
+	//questionIDProp is the post property storing the ID of the question asked in an icebreaker post
+	questionIDProp = "icebreaker_question_id"
+
+	//voteWeightStep is how much every net vote changes the weight of a question, e.g. 0.1 for 10%
+	voteWeightStep = 0.1
+
+	//minVoteFactor and maxVoteFactor limit how much votes change the weight of a question
+	minVoteFactor = 0.5
+	maxVoteFactor = 2.0
+)
+
+// getVote returns 1 for a thumbs up, -1 for a thumbs down and 0 for any other emoji. Skin tones do not matter
+func getVote(emojiName string) int {
+	if index := strings.Index(emojiName, "_"); index > 0 && strings.HasSuffix(emojiName, "_skin_tone") {
+		emojiName = emojiName[:index]
+	}
+	switch emojiName {
+	case "+1", "thumbsup":
+		return 1
+	case "-1", "thumbsdown":
+		return -1
+	default:
+		return 0
+	}
+}
+
+// getVoteScore returns the number of up votes minus the number of down votes
+func (s *QuestionStats) getVoteScore() int {
+	if s == nil {
+		return 0
+	}
+	return s.Upvotes - s.Downvotes
+}
+
+// applyVotes changes the weight of a question according to its votes, well-liked questions are chosen more often
+func applyVotes(weight uint, stats *QuestionStats) uint {
+	factor := 1 + voteWeightStep*float64(stats.getVoteScore())
+	if factor < minVoteFactor {
+		factor = minVoteFactor
+	}
+	if factor > maxVoteFactor {
+		factor = maxVoteFactor
+	}
+	weight = uint(float64(weight) * factor)
+	if weight == 0 {
+		return 1
+	}
+	return weight
+}
+
+// ReactionHasBeenAdded counts thumbs up and thumbs down reactions to icebreaker posts as votes for the asked question
+func (p *Plugin) ReactionHasBeenAdded(c *plugin.Context, reaction *model.Reaction) {
+	p.countVote(reaction, 1)
+}
+
+// ReactionHasBeenRemoved takes back the vote of a removed reaction
+func (p *Plugin) ReactionHasBeenRemoved(c *plugin.Context, reaction *model.Reaction) {
+	p.countVote(reaction, -1)
+}
+
+func (p *Plugin) countVote(reaction *model.Reaction, change int) {
+	vote := getVote(reaction.EmojiName)
+	if vote == 0 || reaction.UserId == p.botID {
+		return
+	}
+	post, appErr := p.API.GetPost(reaction.PostId)
+	if appErr != nil || post.UserId != p.botID {
+		return
+	}
+	questionID, _ := post.GetProp(questionIDProp).(string)
+	if questionID == "" {
+		return
+	}
+
+	threshold := p.getConfiguration().getFlagThreshold()
+	flagged := false
+	p.countQuestion(questionID, func(stats *QuestionStats) {
+		flagged = false //the update might be retried
+		if vote > 0 {
+			stats.Upvotes = addVote(stats.Upvotes, change)
+		} else {
+			stats.Downvotes = addVote(stats.Downvotes, change)
+		}
+		if stats.FlaggedAt == 0 && -stats.getVoteScore() >= threshold {
+			stats.FlaggedAt = model.GetMillis()
+			flagged = true
+		}
+	})
+	if flagged {
+		p.notifyModeratorsAboutFlag(questionID, threshold)
+	}
+}
+
+func addVote(votes int, change int) int {
+	if votes+change < 0 {
+		return 0
+	}
+	return votes + change
+}
+
+// notifyModeratorsAboutFlag tells every moderator that a question has been flagged, so they can decide whether to keep it
+func (p *Plugin) notifyModeratorsAboutFlag(questionID string, threshold int) {
+	data, err := p.ReadFromStorage()
+	if err != nil {
+		p.API.LogError("Failed to read the flagged question", "question", questionID, "err", err.Error())
+		return
+	}
+	index := findQuestionByID(data.Questions, questionID)
+	if index < 0 {
+		return
+	}
+	question := data.Questions[index]
+
+	message := fmt.Sprintf("The icebreaker question `%s` got %d more down votes than up votes and has been flagged: %s%s\n"+
+		"Use `/icebreaker admin remove %s` to remove it or `/icebreaker admin unflag %s` to keep it.",
+		question.ID, threshold, question.Question, question.getLabels(), question.ID, question.ID)
+	for _, moderatorID := range p.getModeratorIDs() {
+		if err := p.sendDirectMessage(moderatorID, &model.Post{Message: message}); err != nil {
+			p.API.LogError("Failed to notify moderator", "user", moderatorID, "err", err.Error())
+		}
+	}
+}
+
+func (p *Plugin) executeCommandIcebreakerFlagged(args *model.CommandArgs) *model.CommandResponse {
+	data, err := p.ReadFromStorage()
+	if err != nil {
+		return p.getStorageErrorResponse(err)
+	}
+	allStats, err := p.getQuestionStats()
+	if err != nil {
+		return p.getStorageErrorResponse(err)
+	}
+
+	message := ""
+	for _, question := range data.Questions {
+		if stats := allStats[question.ID]; stats != nil && stats.FlaggedAt != 0 {
+			message = message + fmt.Sprintf("`%s`\t%s%s:\t%d up and %d down votes, flagged %s\n", question.ID, question.Question, question.getLabels(), stats.Upvotes, stats.Downvotes, formatTime(stats.FlaggedAt))
+		}
+	}
+	if message == "" {
+		return &model.CommandResponse{
+			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+			Text:         "There are no flagged questions...",
+		}
+	}
+
+	return &model.CommandResponse{
+		ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+		Username:     "icebreaker",
+		Text:         "Flagged questions, use `/icebreaker admin remove <id>` to remove them or `/icebreaker admin unflag <id>` to keep them:\n" + message,
+	}
+}
+
+// executeCommandIcebreakerUnflag keeps a flagged question. Its votes are reset, so it is not flagged again right away
+func (p *Plugin) executeCommandIcebreakerUnflag(args *model.CommandArgs) *model.CommandResponse {
+	data, err := p.ReadFromStorage()
+	if err != nil {
+		return p.getStorageErrorResponse(err)
+	}
+	index, errResponse := getQuestionIndex(strings.TrimPrefix(args.Command, fmt.Sprintf("/%s", commandIcebreakerUnflag)), data.Questions)
+	if errResponse != nil {
+		return errResponse
+	}
+	question := data.Questions[index]
+
+	allStats, err := p.getQuestionStats()
+	if err != nil {
+		return p.getStorageErrorResponse(err)
+	}
+	if stats := allStats[question.ID]; stats == nil || stats.FlaggedAt == 0 {
+		return &model.CommandResponse{
+			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+			Text:         fmt.Sprintf("Error: The question `%s` is not flagged", question.ID),
+		}
+	}
+
+	err = p.updateQuestionStats(question.ID, func(stats *QuestionStats) {
+		stats.Upvotes = 0
+		stats.Downvotes = 0
+		stats.FlaggedAt = 0
+	})
+	if err != nil {
+		return p.getStorageErrorResponse(err)
+	}
+	return &model.CommandResponse{
+		ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+		Text:         fmt.Sprintf("The question `%s` is not flagged anymore and its votes have been reset: %s", question.ID, question.Question),
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetVote(t *testing.T) {
+	assert.Equal(t, 1, getVote("+1"))
+	assert.Equal(t, 1, getVote("thumbsup"))
+	assert.Equal(t, 1, getVote("+1_medium_dark_skin_tone"))
+	assert.Equal(t, -1, getVote("-1"))
+	assert.Equal(t, -1, getVote("thumbsdown_light_skin_tone"))
+	assert.Equal(t, 0, getVote("smile"))
+	assert.Equal(t, 0, getVote("+1_extra"))
+}
+
+func TestApplyVotes(t *testing.T) {
+	assert.Equal(t, uint(1000), applyVotes(1000, nil))
+	assert.Equal(t, uint(1200), applyVotes(1000, &QuestionStats{Upvotes: 3, Downvotes: 1}))
+	assert.Equal(t, uint(2000), applyVotes(1000, &QuestionStats{Upvotes: 50}))
+	assert.Equal(t, uint(800), applyVotes(1000, &QuestionStats{Downvotes: 2}))
+	assert.Equal(t, uint(500), applyVotes(1000, &QuestionStats{Downvotes: 50}))
+
+	//even the most disliked question can still be chosen
+	assert.Equal(t, uint(1), applyVotes(1, &QuestionStats{Downvotes: 50}))
+}
+
+func TestReactionVotes(t *testing.T) {
+	icebreakerData := IceBreakerData{
+		Questions: []Question{Question{ID: "q1", Creator: "TestUser", Question: "How do you do?"}},
+	}
+	dataBytes, err := json.Marshal(icebreakerData)
+	require.NoError(t, err)
+
+	icebreakerPost := &model.Post{Id: "IcebreakerPost", UserId: "BotUser"}
+	icebreakerPost.AddProp(questionIDProp, "q1")
+	api, store := newFakeKVStore(map[string][]byte{KVKEY: dataBytes})
+	api.On("GetPost", "IcebreakerPost").Return(icebreakerPost, nil)
+	api.On("GetPost", "UserPost").Return(&model.Post{Id: "UserPost", UserId: "TestUser"}, nil)
+	api.On("GetUsers", mock.AnythingOfType("*model.UserGetOptions")).Return([]*model.User{&model.User{Id: "AdminUser"}}, nil)
+	api.On("GetDirectChannel", "BotUser", "AdminUser").Return(&model.Channel{Id: "DirectChannel"}, nil)
+	api.On("CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+		return post.ChannelId == "DirectChannel" && strings.HasPrefix(post.Message, "The icebreaker question `q1` got 2 more down votes than up votes and has been flagged: How do you do?\n")
+	})).Return(nil, nil)
+	plugin := &Plugin{botID: "BotUser"}
+	plugin.SetAPI(api)
+	plugin.setConfiguration(&configuration{FlagThreshold: 2})
+
+	react := func(userID string, postID string, emojiName string) {
+		plugin.ReactionHasBeenAdded(nil, &model.Reaction{UserId: userID, PostId: postID, EmojiName: emojiName})
+	}
+	unreact := func(userID string, postID string, emojiName string) {
+		plugin.ReactionHasBeenRemoved(nil, &model.Reaction{UserId: userID, PostId: postID, EmojiName: emojiName})
+	}
+
+	react("User1", "IcebreakerPost", "+1")
+	react("User2", "IcebreakerPost", "-1")
+	react("User3", "IcebreakerPost", "smile")
+	react("User3", "UserPost", "-1")
+	react("BotUser", "IcebreakerPost", "+1")
+	stats := readQuestionStats(t, store)["q1"]
+	assert.Equal(t, 1, stats.Upvotes)
+	assert.Equal(t, 1, stats.Downvotes)
+	assert.Zero(t, stats.FlaggedAt)
+
+	unreact("User1", "IcebreakerPost", "+1")
+	react("User3", "IcebreakerPost", "thumbsdown")
+	stats = readQuestionStats(t, store)["q1"]
+	assert.Equal(t, 0, stats.Upvotes)
+	assert.Equal(t, 2, stats.Downvotes)
+	assert.NotZero(t, stats.FlaggedAt)
+	api.AssertNumberOfCalls(t, "CreatePost", 1)
+
+	//the moderators are only notified once
+	react("User4", "IcebreakerPost", "-1")
+	api.AssertNumberOfCalls(t, "CreatePost", 1)
+}
+
+func TestFlaggedCommands(t *testing.T) {
+	icebreakerData := IceBreakerData{
+		Questions: []Question{
+			Question{ID: "q1", Creator: "TestUser", Question: "How do you do?"},
+			Question{ID: "q2", Creator: "TestUser", Question: "What do you do?"},
+		},
+	}
+	dataBytes, err := json.Marshal(icebreakerData)
+	require.NoError(t, err)
+	statsBytes, err := json.Marshal(map[string]*QuestionStats{
+		"q1": &QuestionStats{Asked: 2, Upvotes: 1, Downvotes: 4, FlaggedAt: 1614589200000},
+		"q2": &QuestionStats{Asked: 1, Downvotes: 1},
+	})
+	require.NoError(t, err)
+
+	api, store := newFakeKVStore(map[string][]byte{KVKEY: dataBytes, questionStatsKey: statsBytes})
+	api.On("GetUser", "AdminUser").Return(&model.User{Id: "AdminUser", Roles: model.SYSTEM_ADMIN_ROLE_ID}, nil)
+	plugin := &Plugin{}
+	plugin.SetAPI(api)
+	execute := func(command string) string {
+		response, _ := plugin.ExecuteCommand(nil, &model.CommandArgs{Command: command, UserId: "AdminUser"})
+		return response.Text
+	}
+
+	assert.Equal(t, "Flagged questions, use `/icebreaker admin remove <id>` to remove them or `/icebreaker admin unflag <id>` to keep them:\n"+
+		"`q1`\tHow do you do?:\t1 up and 4 down votes, flagged 2021-03-01 09:00 UTC\n", execute("/icebreaker admin flagged"))
+	assert.Equal(t, "`q1`\tHow do you do?\nasked 2 times, answered 0 times, passed 0 times, 1 up and 4 down votes, flagged for review", execute("/icebreaker stats q1"))
+
+	assert.Equal(t, "Error: The question `q2` is not flagged", execute("/icebreaker admin unflag q2"))
+	assert.Equal(t, "The question `q1` is not flagged anymore and its votes have been reset: How do you do?", execute("/icebreaker admin unflag q1"))
+	assert.Equal(t, &QuestionStats{Asked: 2}, readQuestionStats(t, store)["q1"])
+	assert.Equal(t, "There are no flagged questions...", execute("/icebreaker admin flagged"))
+}
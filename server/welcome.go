@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/mattermost/mattermost-server/v5/plugin"
+	"github.com/pkg/errors"
+)
+
+const (
+	//pendingWelcomesKey is the key of the users who joined a welcome channel and have not been asked yet
+	pendingWelcomesKey = "IceBreakerWelcomes"
+
+	//maxPendingWelcomes limits how many welcomes can wait at once, e.g. when a whole team is added to a channel
+	maxPendingWelcomes = 1000
+)
+
+// PendingWelcome is a user who joined a welcome channel and is asked an icebreaker once the welcome delay passed
+type PendingWelcome struct {
+	UserID    string `json:"user_id"`
+	TeamID    string `json:"team_id"`
+	ChannelID string `json:"channel_id"`
+	DueAt     int64  `json:"due_at"`
+}
+
+// UserHasJoinedChannel queues an icebreaker for users joining a channel that welcomes new members.
+// The question is asked by the scheduler after the configured delay, so the user has time to look around first
+func (p *Plugin) UserHasJoinedChannel(c *plugin.Context, channelMember *model.ChannelMember, actor *model.User) {
+	data, err := p.ReadFromStorage()
+	if err != nil || !containsString(data.WelcomeChannels, channelMember.ChannelId) {
+		return
+	}
+
+	config := p.getConfiguration()
+	user, appErr := p.API.GetUser(channelMember.UserId)
+	if appErr != nil {
+		p.API.LogError("Failed to get the user to welcome", "user", channelMember.UserId, "err", appErr.Error())
+		return
+	}
+	if user.IsBot || (config.WelcomeSkipGuests && user.IsGuest()) {
+		return
+	}
+	channel, appErr := p.API.GetChannel(channelMember.ChannelId)
+	if appErr != nil {
+		p.API.LogError("Failed to get the channel to welcome a user in", "channel", channelMember.ChannelId, "err", appErr.Error())
+		return
+	}
+
+	welcome := PendingWelcome{
+		UserID:    user.Id,
+		TeamID:    channel.TeamId,
+		ChannelID: channel.Id,
+		DueAt:     model.GetMillis() + int64(config.getWelcomeDelay()/time.Millisecond),
+	}
+	err = p.updateKey(pendingWelcomesKey, func(oldValue []byte) ([]byte, error) {
+		welcomes, err := decodePendingWelcomes(oldValue)
+		if err != nil {
+			return nil, err
+		}
+		if len(welcomes) >= maxPendingWelcomes {
+			return nil, errSkipUpdate
+		}
+		return json.Marshal(append(welcomes, welcome))
+	})
+	if err != nil {
+		p.API.LogError("Failed to queue the icebreaker welcome", "user", user.Id, "channel", channel.Id, "err", err.Error())
+	}
+}
+
+// runWelcomes asks an icebreaker to every queued user whose welcome is due. The due welcomes are removed
+// from the queue before they are asked, so only a single server node welcomes each user
+func (p *Plugin) runWelcomes(now time.Time) {
+	nowMillis := now.UnixNano() / int64(time.Millisecond)
+
+	//most of the time nobody is waiting, so check before doing any update
+	kvData, appErr := p.API.KVGet(pendingWelcomesKey)
+	if appErr != nil || len(kvData) == 0 {
+		return
+	}
+
+	var due []PendingWelcome
+	err := p.updateKey(pendingWelcomesKey, func(oldValue []byte) ([]byte, error) {
+		due = nil //the update might be retried
+		welcomes, err := decodePendingWelcomes(oldValue)
+		if err != nil {
+			return nil, err
+		}
+		waiting := []PendingWelcome{}
+		for _, welcome := range welcomes {
+			if welcome.DueAt <= nowMillis {
+				due = append(due, welcome)
+			} else {
+				waiting = append(waiting, welcome)
+			}
+		}
+		if len(due) == 0 {
+			return nil, errSkipUpdate
+		}
+		return json.Marshal(waiting)
+	})
+	if err != nil {
+		p.API.LogError("Failed to read the icebreaker welcomes", "err", err.Error())
+		return
+	}
+
+	for _, welcome := range due {
+		if err := p.welcomeUser(&welcome); err != nil {
+			p.API.LogWarn("Failed to welcome user with an icebreaker", "user", welcome.UserID, "channel", welcome.ChannelID, "err", err.Error())
+		}
+	}
+}
+
+// welcomeUser asks the user of the given welcome an icebreaker, unless they left the channel or cannot be asked anymore
+func (p *Plugin) welcomeUser(welcome *PendingWelcome) error {
+	data, err := p.ReadFromStorage()
+	if err != nil {
+		return err
+	}
+	if !containsString(data.WelcomeChannels, welcome.ChannelID) {
+		return nil
+	}
+	if _, appErr := p.API.GetChannelMember(welcome.ChannelID, welcome.UserID); appErr != nil {
+		return nil
+	}
+	user, appErr := p.API.GetUser(welcome.UserID)
+	if appErr != nil {
+		return errors.Wrap(appErr, "failed to get the user")
+	}
+	if reason := p.getUnaskableReason(user, welcome.ChannelID, &data, p.getConfiguration()); reason != "" {
+		p.API.LogDebug("Skipped icebreaker welcome", "user", user.Id, "channel", welcome.ChannelID, "reason", reason)
+		return nil
+	}
+
+	question, appErr := p.GetRandomQuestion(welcome.TeamID, welcome.ChannelID, questionFilter{})
+	if appErr != nil {
+		return errNoQuestions
+	}
+	return p.postIcebreaker(welcome.TeamID, welcome.ChannelID, "", user, question, questionFilter{})
+}
+
+func decodePendingWelcomes(kvData []byte) ([]PendingWelcome, error) {
+	welcomes := []PendingWelcome{}
+	if len(kvData) == 0 {
+		return welcomes, nil
+	}
+	if err := json.Unmarshal(kvData, &welcomes); err != nil {
+		return nil, errors.Wrap(err, "failed to decode the pending welcomes")
+	}
+	return welcomes, nil
+}
+
+func (p *Plugin) executeCommandIcebreakerWelcome(args *model.CommandArgs) *model.CommandResponse {
+	mode := strings.TrimSpace(strings.TrimPrefix(args.Command, fmt.Sprintf("/%s", commandIcebreakerWelcome)))
+	if mode != "on" && mode != "off" {
+		return &model.CommandResponse{
+			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+			Text:         "Error: Please use `/icebreaker welcome on` or `/icebreaker welcome off`",
+		}
+	}
+
+	err := p.updateData(func(data *IceBreakerData) error {
+		data.WelcomeChannels = removeString(data.WelcomeChannels, args.ChannelId)
+		if mode == "on" {
+			data.WelcomeChannels = append(data.WelcomeChannels, args.ChannelId)
+		}
+		return nil
+	})
+	if err != nil {
+		return p.getStorageErrorResponse(err)
+	}
+
+	text := "New members of this channel will be asked an icebreaker right after they joined."
+	if delay := p.getConfiguration().getWelcomeDelay(); delay > 0 {
+		text = fmt.Sprintf("New members of this channel will be asked an icebreaker %s after they joined.", formatDelay(delay))
+	}
+	if mode == "off" {
+		text = "New members of this channel will not be asked an icebreaker anymore."
+	}
+	return &model.CommandResponse{
+		ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+		Text:         text,
+	}
+}
+
+func formatDelay(delay time.Duration) string {
+	if delay >= time.Minute && delay%time.Minute == 0 {
+		if delay == time.Minute {
+			return "1 minute"
+		}
+		return fmt.Sprintf("%d minutes", delay/time.Minute)
+	}
+	if delay == time.Second {
+		return "1 second"
+	}
+	return fmt.Sprintf("%d seconds", delay/time.Second)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/mattermost/mattermost-server/v5/plugin/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWelcomeCommand(t *testing.T) {
+	api, _ := newFakeKVStore(nil)
+	api.On("GetUser", "AdminUser").Return(&model.User{Id: "AdminUser", Roles: model.SYSTEM_ADMIN_ROLE_ID}, nil)
+	api.On("GetUser", "TestUser").Return(&model.User{Id: "TestUser", Roles: model.SYSTEM_USER_ROLE_ID}, nil)
+	api.On("HasPermissionToChannel", "TestUser", "TestChannel", model.PERMISSION_MANAGE_CHANNEL_ROLES).Return(false)
+	plugin := &Plugin{}
+	plugin.SetAPI(api)
+	plugin.setConfiguration(&configuration{WelcomeDelay: 60})
+
+	execute := func(userID string, command string) string {
+		response, _ := plugin.ExecuteCommand(nil, &model.CommandArgs{Command: command, UserId: userID, ChannelId: "TestChannel"})
+		return response.Text
+	}
+
+	assert.Equal(t, "Error: Please use `/icebreaker welcome on` or `/icebreaker welcome off`", execute("AdminUser", "/icebreaker welcome"))
+	assert.Equal(t, "New members of this channel will be asked an icebreaker 1 minute after they joined.", execute("AdminUser", "/icebreaker welcome on"))
+	assert.Equal(t, []string{"TestChannel"}, readData(t, plugin).WelcomeChannels)
+
+	assert.Equal(t, "Error: You need to be Channel Admin in order to use this command", execute("TestUser", "/icebreaker welcome off"))
+	assert.Equal(t, []string{"TestChannel"}, readData(t, plugin).WelcomeChannels)
+
+	assert.Equal(t, "New members of this channel will not be asked an icebreaker anymore.", execute("AdminUser", "/icebreaker welcome off"))
+	assert.Empty(t, readData(t, plugin).WelcomeChannels)
+
+	plugin.setConfiguration(&configuration{WelcomeDelay: 0})
+	assert.Equal(t, "New members of this channel will be asked an icebreaker right after they joined.", execute("AdminUser", "/icebreaker welcome on"))
+}
+
+func TestWelcomeNewMembers(t *testing.T) {
+	setup := func(t *testing.T, config *configuration) (*Plugin, *plugintest.API, *fakeKVStore) {
+		icebreakerData := IceBreakerData{
+			Questions:       []Question{Question{ID: "q1", Creator: "TestUser", Question: "How do you do?"}},
+			WelcomeChannels: []string{"WelcomeChannel"},
+		}
+		dataBytes, err := json.Marshal(icebreakerData)
+		require.NoError(t, err)
+
+		api, store := newFakeKVStore(map[string][]byte{KVKEY: dataBytes})
+		api.On("GetUser", "NewUser").Return(&model.User{Id: "NewUser", Username: "new_user"}, nil)
+		api.On("GetUser", "GuestUser").Return(&model.User{Id: "GuestUser", Username: "guest_user", Roles: model.SYSTEM_GUEST_ROLE_ID}, nil)
+		api.On("GetUser", "BotUser").Return(&model.User{Id: "BotUser", Username: "bot_user", IsBot: true}, nil)
+		api.On("GetChannel", "WelcomeChannel").Return(&model.Channel{Id: "WelcomeChannel", TeamId: "TestTeam"}, nil)
+		api.On("GetChannelMember", "WelcomeChannel", "NewUser").Return(&model.ChannelMember{}, nil)
+		api.On("GetChannelMember", "WelcomeChannel", "GuestUser").Return(&model.ChannelMember{}, nil)
+		api.On("GetUserStatus", mock.AnythingOfType("string")).Return(&model.Status{Status: model.STATUS_ONLINE}, nil)
+		api.On("CreatePost", matchIcebreakerPost("WelcomeChannel", "", "Hey @new_user! How do you do?")).Return(&model.Post{Id: "WelcomePost", ChannelId: "WelcomeChannel"}, nil)
+		api.On("CreatePost", matchIcebreakerPost("WelcomeChannel", "", "Hey @guest_user! How do you do?")).Return(&model.Post{Id: "GuestPost", ChannelId: "WelcomeChannel"}, nil)
+		plugin := &Plugin{}
+		plugin.SetAPI(api)
+		plugin.setConfiguration(config)
+		return plugin, api, store
+	}
+	join := func(plugin *Plugin, userID string, channelID string) {
+		plugin.UserHasJoinedChannel(nil, &model.ChannelMember{UserId: userID, ChannelId: channelID}, nil)
+	}
+
+	t.Run("Ask new members after the delay", func(t *testing.T) {
+		plugin, api, store := setup(t, &configuration{WelcomeDelay: 30})
+
+		join(plugin, "NewUser", "WelcomeChannel")
+		join(plugin, "GuestUser", "WelcomeChannel")
+		join(plugin, "BotUser", "WelcomeChannel")
+		join(plugin, "NewUser", "OtherChannel")
+
+		welcomes, err := decodePendingWelcomes(store.get(pendingWelcomesKey))
+		require.NoError(t, err)
+		require.Len(t, welcomes, 2)
+		assert.Equal(t, "NewUser", welcomes[0].UserID)
+		assert.Equal(t, "TestTeam", welcomes[0].TeamID)
+
+		//nobody is due before the delay passed
+		plugin.runWelcomes(time.Now())
+		api.AssertNotCalled(t, "CreatePost", mock.Anything)
+
+		plugin.runWelcomes(time.Now().Add(31 * time.Second))
+		api.AssertNumberOfCalls(t, "CreatePost", 2)
+		welcomes, err = decodePendingWelcomes(store.get(pendingWelcomesKey))
+		require.NoError(t, err)
+		assert.Empty(t, welcomes)
+
+		data := readData(t, plugin)
+		assert.ElementsMatch(t, []string{"NewUser", "GuestUser"}, data.LastUsers)
+		require.Len(t, data.LastQuestions, 2)
+		assert.Equal(t, "q1", data.LastQuestions[0].ID)
+	})
+	t.Run("Skip guests", func(t *testing.T) {
+		plugin, _, store := setup(t, &configuration{WelcomeSkipGuests: true})
+
+		join(plugin, "GuestUser", "WelcomeChannel")
+		join(plugin, "NewUser", "WelcomeChannel")
+
+		welcomes, err := decodePendingWelcomes(store.get(pendingWelcomesKey))
+		require.NoError(t, err)
+		require.Len(t, welcomes, 1)
+		assert.Equal(t, "NewUser", welcomes[0].UserID)
+	})
+	t.Run("Skip members who opted out", func(t *testing.T) {
+		plugin, api, _ := setup(t, &configuration{})
+		require.NoError(t, plugin.updateData(func(data *IceBreakerData) error {
+			data.UserPreferences = map[string]*UserPreferences{"NewUser": &UserPreferences{OptedOut: true}}
+			return nil
+		}))
+		api.On("LogDebug", "Skipped icebreaker welcome", "user", "NewUser", "channel", "WelcomeChannel", "reason", mock.AnythingOfType("string")).Return()
+
+		join(plugin, "NewUser", "WelcomeChannel")
+		plugin.runWelcomes(time.Now().Add(time.Minute))
+		api.AssertNotCalled(t, "CreatePost", mock.Anything)
+	})
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	//defaults of the working window of the users, in their own timezone
+	defaultWorkingHours = "9-17"
+	defaultWorkingDays  = "1-5"
+)
+
+// workingWindow describes when users are working: from the start hour until the end hour on the given days of the week.
+// An end hour before the start hour describes a shift over midnight
+type workingWindow struct {
+	startHour int
+	endHour   int
+	days      cronField
+}
+
+// parseWorkingWindow parses working hours like `9-17` and working days like `1-5`, with 0 being Sunday
+func parseWorkingWindow(hours string, days string) (*workingWindow, error) {
+	bounds := strings.Split(strings.TrimSpace(hours), "-")
+	if len(bounds) != 2 {
+		return nil, errors.Errorf("invalid working hours '%s', expected a range like %s", hours, defaultWorkingHours)
+	}
+	startHour, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+	if err != nil || startHour < 0 || startHour > 23 {
+		return nil, errors.Errorf("invalid start of the working hours '%s', expected an hour between 0 and 23", bounds[0])
+	}
+	endHour, err := strconv.Atoi(strings.TrimSpace(bounds[1]))
+	if err != nil || endHour < 1 || endHour > 24 || endHour == startHour {
+		return nil, errors.Errorf("invalid end of the working hours '%s', expected an hour between 1 and 24 other than the start", bounds[1])
+	}
+
+	workingDays, err := parseCronField(strings.Replace(days, " ", "", -1), 0, 6)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid working days '%s'", days)
+	}
+	return &workingWindow{startHour: startHour, endHour: endHour, days: workingDays}, nil
+}
+
+// contains checks whether the given local time is part of the working window
+func (w *workingWindow) contains(localTime time.Time) bool {
+	if !w.days[int(localTime.Weekday())] {
+		return false
+	}
+	hour := localTime.Hour()
+	if w.startHour < w.endHour {
+		return hour >= w.startHour && hour < w.endHour
+	}
+	return hour >= w.startHour || hour < w.endHour
+}
+
+// isWithinWorkingHours checks whether it is a working hour for the user in their own timezone at the given time.
+// Users without a known timezone are treated as working, there is no way to tell otherwise
+func (w *workingWindow) isWithinWorkingHours(user *model.User, now time.Time) bool {
+	timezone := user.GetPreferredTimezone()
+	if timezone == "" {
+		return true
+	}
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return true
+	}
+	return w.contains(now.In(location))
+}
+
+// usesWorkingHours checks whether users are only asked during their working hours in the given channel
+func (d *IceBreakerData) usesWorkingHours(channelID string, config *configuration) bool {
+	return config.OnlyWorkingHours || containsString(d.WorkingHoursChannels, channelID)
+}
+
+func (p *Plugin) executeCommandIcebreakerWorkingHours(args *model.CommandArgs) *model.CommandResponse {
+	mode := strings.TrimSpace(strings.TrimPrefix(args.Command, fmt.Sprintf("/%s", commandIcebreakerWorkingHours)))
+	if mode != "on" && mode != "off" {
+		return &model.CommandResponse{
+			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+			Text:         "Error: Please use `/icebreaker admin workinghours on` or `/icebreaker admin workinghours off`",
+		}
+	}
+
+	err := p.updateData(func(data *IceBreakerData) error {
+		data.WorkingHoursChannels = removeString(data.WorkingHoursChannels, args.ChannelId)
+		if mode == "on" {
+			data.WorkingHoursChannels = append(data.WorkingHoursChannels, args.ChannelId)
+		}
+		return nil
+	})
+	if err != nil {
+		return p.getStorageErrorResponse(err)
+	}
+
+	config := p.getConfiguration()
+	text := fmt.Sprintf("Users of this channel are only asked questions during their working hours now (%s on the days %s, in their own timezone), scheduled icebreakers included.", config.getWorkingHours(), config.getWorkingDays())
+	if mode == "off" {
+		text = "Users of this channel are asked questions regardless of their working hours again."
+		if config.OnlyWorkingHours {
+			text = "Users of this channel are still only asked questions during their working hours, as this is configured for all channels."
+		}
+	}
+	return &model.CommandResponse{
+		ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+		Text:         text,
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseWorkingWindow(t *testing.T) {
+	for _, hours := range []string{"", "9", "9-", "a-17", "9-25", "24-5", "9-9", "9-12-17"} {
+		_, err := parseWorkingWindow(hours, "1-5")
+		assert.Error(t, err, hours)
+	}
+	for _, days := range []string{"", "1-7", "monday"} {
+		_, err := parseWorkingWindow("9-17", days)
+		assert.Error(t, err, days)
+	}
+
+	window, err := parseWorkingWindow(" 9 - 17 ", "1-5")
+	require.NoError(t, err)
+	assert.True(t, window.contains(time.Date(2021, time.March, 1, 9, 0, 0, 0, time.UTC)))                     //Monday
+	assert.True(t, window.contains(time.Date(2021, time.March, 5, 16, 59, 0, 0, time.UTC)))                   //Friday
+	assert.False(t, window.contains(time.Date(2021, time.March, 5, 17, 0, 0, 0, time.UTC)))                   //after work
+	assert.False(t, window.contains(time.Date(2021, time.March, 1, 8, 59, 0, 0, time.UTC)))                   //before work
+	assert.False(t, window.contains(time.Date(2021, time.March, 6, 12, 0, 0, 0, time.UTC)))                   //Saturday
+	assert.False(t, window.contains(time.Date(2021, time.March, 7, 12, 0, 0, 0, time.UTC)))                   //Sunday
+	assert.True(t, window.contains(time.Date(2021, time.March, 1, 12, 0, 0, 0, time.FixedZone("", 5*60*60)))) //local time counts
+
+	//a night shift spans midnight
+	window, err = parseWorkingWindow("22-6", "0,1,2,3,4,5,6")
+	require.NoError(t, err)
+	assert.True(t, window.contains(time.Date(2021, time.March, 1, 23, 0, 0, 0, time.UTC)))
+	assert.True(t, window.contains(time.Date(2021, time.March, 1, 5, 0, 0, 0, time.UTC)))
+	assert.False(t, window.contains(time.Date(2021, time.March, 1, 12, 0, 0, 0, time.UTC)))
+}
+
+func TestIsWithinWorkingHours(t *testing.T) {
+	window, err := parseWorkingWindow("9-17", "1-5")
+	require.NoError(t, err)
+	now := time.Date(2021, time.March, 1, 10, 0, 0, 0, time.UTC) //Monday
+
+	withTimezone := func(timezone model.StringMap) *model.User {
+		return &model.User{Id: "TestUser", Timezone: timezone}
+	}
+	assert.True(t, window.isWithinWorkingHours(withTimezone(model.StringMap{"useAutomaticTimezone": "true", "automaticTimezone": "Europe/Berlin"}), now))
+	assert.False(t, window.isWithinWorkingHours(withTimezone(model.StringMap{"useAutomaticTimezone": "true", "automaticTimezone": "America/New_York"}), now))
+	assert.False(t, window.isWithinWorkingHours(withTimezone(model.StringMap{"useAutomaticTimezone": "false", "automaticTimezone": "Europe/Berlin", "manualTimezone": "Asia/Tokyo"}), now))
+
+	//users without a known timezone are always working
+	assert.True(t, window.isWithinWorkingHours(withTimezone(nil), now))
+	assert.True(t, window.isWithinWorkingHours(withTimezone(model.StringMap{"manualTimezone": "Nowhere/Unknown"}), now))
+}
+
+// getTimezoneWithLocalHour returns a timezone in which it is the given hour right now
+func getTimezoneWithLocalHour(hour int) string {
+	offset := hour - time.Now().UTC().Hour()
+	if offset > 12 {
+		offset -= 24
+	}
+	if offset < -12 {
+		offset += 24
+	}
+	switch {
+	case offset > 0:
+		return fmt.Sprintf("Etc/GMT-%d", offset)
+	case offset < 0:
+		return fmt.Sprintf("Etc/GMT+%d", -offset)
+	default:
+		return "Etc/GMT"
+	}
+}
+
+func TestGetRandomUser_workingHours(t *testing.T) {
+	users := []*model.User{
+		&model.User{Id: "Working", Username: "working", Timezone: model.StringMap{"manualTimezone": getTimezoneWithLocalHour(12)}},
+		&model.User{Id: "Sleeping", Username: "sleeping", Timezone: model.StringMap{"manualTimezone": getTimezoneWithLocalHour(0)}},
+	}
+	setup := func(data *IceBreakerData, config *configuration) *Plugin {
+		dataBytes, err := json.Marshal(data)
+		require.NoError(t, err)
+
+		api, _ := newFakeKVStore(map[string][]byte{KVKEY: dataBytes})
+		api.On("GetUsersInChannel", "TestChannel", mock.AnythingOfType("string"), mock.AnythingOfType("int"), mock.AnythingOfType("int")).Return(users, nil)
+		api.On("GetUserStatus", mock.AnythingOfType("string")).Return(&model.Status{Status: "online"}, nil)
+		plugin := &Plugin{}
+		plugin.SetAPI(api)
+		plugin.setConfiguration(config)
+		return plugin
+	}
+	askedUsers := func(plugin *Plugin) map[string]bool {
+		asked := map[string]bool{}
+		for i := 0; i < 50; i++ {
+			user, err := plugin.GetRandomUser("TestChannel", "")
+			require.Nil(t, err)
+			asked[user.Id] = true
+		}
+		return asked
+	}
+
+	t.Run("Working hours are ignored by default", func(t *testing.T) {
+		plugin := setup(&IceBreakerData{}, &configuration{WorkingDays: "0-6"})
+		assert.Equal(t, map[string]bool{"Working": true, "Sleeping": true}, askedUsers(plugin))
+	})
+	t.Run("Channel policy", func(t *testing.T) {
+		plugin := setup(&IceBreakerData{WorkingHoursChannels: []string{"TestChannel"}}, &configuration{WorkingDays: "0-6"})
+		assert.Equal(t, map[string]bool{"Working": true}, askedUsers(plugin))
+	})
+	t.Run("All channels", func(t *testing.T) {
+		plugin := setup(&IceBreakerData{}, &configuration{WorkingDays: "0-6", OnlyWorkingHours: true})
+		assert.Equal(t, map[string]bool{"Working": true}, askedUsers(plugin))
+	})
+}
+
+func TestWorkingHoursCommand(t *testing.T) {
+	api, _ := newFakeKVStore(nil)
+	api.On("GetUser", "AdminUser").Return(&model.User{Id: "AdminUser", Roles: model.SYSTEM_ADMIN_ROLE_ID}, nil)
+	plugin := &Plugin{}
+	plugin.SetAPI(api)
+
+	execute := func(command string) string {
+		response, _ := plugin.ExecuteCommand(nil, &model.CommandArgs{Command: command, UserId: "AdminUser", ChannelId: "TestChannel"})
+		return response.Text
+	}
+
+	assert.Equal(t, "Error: Please use `/icebreaker admin workinghours on` or `/icebreaker admin workinghours off`", execute("/icebreaker admin workinghours"))
+	assert.Equal(t, "Users of this channel are only asked questions during their working hours now (9-17 on the days 1-5, in their own timezone), scheduled icebreakers included.", execute("/icebreaker admin workinghours on"))
+	assert.Equal(t, []string{"TestChannel"}, readData(t, plugin).WorkingHoursChannels)
+
+	assert.Equal(t, "Users of this channel are asked questions regardless of their working hours again.", execute("/icebreaker admin workinghours off"))
+	assert.Empty(t, readData(t, plugin).WorkingHoursChannels)
+}